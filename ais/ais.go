@@ -0,0 +1,265 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package ais receives the Automatic Identification System used by
+// ships, both channels at once from a capture centered on 162.0 MHz.
+//
+// Each channel is mixed to baseband, decimated to 48 kHz, five samples
+// per symbol, and FM demodulated by the phase change over a symbol. The
+// GMSK symbols are sliced and clocked by a loop tracking their
+// transitions, NRZI decoded, and searched for HDLC flags. The bits
+// between flags are unstuffed and the frame kept when its CRC-16
+// checks. Messages are written as !AIVDM NMEA 0183 sentences, and the
+// common types parsed.
+//
+// The slicer threshold is the frequency offset measured on each
+// message's training sequence. Until one is measured it follows the
+// mean frequency over the length of one, weighted by power so noise
+// counts for little, which finds the flag ending the training sequence
+// with offsets of a kHz or so.
+package ais
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+	"github.com/jpoirier/gortlsdr/internal/hdlc"
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// CenterFreq is the frequency to tune to, half way between the two AIS
+// channels.
+const CenterFreq = 162000000
+
+const (
+	channelOffset = 25e3 // channel A below, B above
+	baud          = 9600.0
+	rate          = 48e3 // demodulator rate
+	sps           = 5    // samples per symbol
+	passband      = 5e3
+	stopband      = 8e3
+
+	clockGain = 0.2            // share of the timing error corrected per transition
+	meanLen   = training * sps // samples in the running mean, a training sequence
+	training  = 24             // symbols of the training sequence before the start flag
+	maxFrame  = 1 << 11        // bits, longer than the 5 slot messages
+	minFrame  = 72 + 16        // bits of the shortest message and the CRC
+)
+
+// Config holds the receiver settings.
+type Config struct {
+	SampleRate float64 // of the capture, at least 96 kS/s
+
+	// OnMessage is called with each message received, see NMEALines.
+	OnMessage func(Message)
+}
+
+// Decoder receives both AIS channels.
+type Decoder struct {
+	cfg Config
+	ch  [2]*channel
+}
+
+// channel is the demodulator and deframer of one channel.
+type channel struct {
+	name byte // 'A' or 'B'
+	nco  *dsp.NCO
+	dec  *dsp.Decimator
+	rs   dsp.Resampler
+	lp   *dsp.FIRFilter
+
+	hist [sps]complex64    // the last symbol's samples, for the discriminator
+	k    int               // position in hist
+	freq [training]float64 // frequency at the last symbols
+	nsym int               // symbols so far
+	dc   float64           // frequency offset, the slicer threshold
+	lock bool              // dc was measured on a training sequence
+	clk  float64           // symbol clock phase, symbols are taken as it wraps
+	last bool              // last sample's slice
+	prev float64           // last sample's frequency
+	sym  bool              // last symbol, for NRZI
+
+	// the frequency weighted by power, and the power, over the last
+	// meanLen samples, whose ratio is the threshold until locked
+	wf, wa [meanLen]float64
+	sf, sa float64
+	j      int
+
+	hdlc  *hdlc.Deframer
+	power float64
+	n     int // samples since the last flag
+
+	tmp, bb []complex64
+}
+
+// NewDecoder returns a receiver for the given configuration.
+func NewDecoder(cfg Config) (*Decoder, error) {
+	if cfg.SampleRate < 2*rate {
+		return nil, errors.New("sample rate too low for both AIS channels")
+	}
+	d := &Decoder{cfg: cfg}
+	for i, name := range [...]byte{'A', 'B'} {
+		dec, err := dsp.NewDecimator(dsp.DecimatorConfig{
+			InputRate:  cfg.SampleRate,
+			OutputRate: rate,
+			Passband:   stopband,
+		})
+		if err != nil {
+			return nil, err
+		}
+		rs, err := dsp.NewResampler(dec.OutputRate(), rate)
+		if err != nil {
+			return nil, err
+		}
+		// channel A is below the center, so moves up
+		f := channelOffset
+		if name == 'B' {
+			f = -f
+		}
+		d.ch[i] = &channel{
+			name: name,
+			nco:  dsp.NewNCO(cfg.SampleRate, f),
+			dec:  dec,
+			rs:   rs,
+			lp:   dsp.NewFIRFilter(dsp.LowpassKaiser(passband/rate, stopband/rate, 50)),
+			hdlc: hdlc.NewDeframer(minFrame, maxFrame),
+		}
+	}
+	return d, nil
+}
+
+// Process receives the samples in x, t being the time of the first.
+func (d *Decoder) Process(x []complex64, t time.Time) {
+	for _, c := range d.ch {
+		c.tmp = c.nco.Mix(grow(c.tmp, len(x)), x)
+		c.bb = c.dec.Process(c.bb, c.tmp)
+		c.tmp = c.rs.Process(c.tmp, c.bb)
+		c.bb = c.lp.Process(c.bb, c.tmp)
+		for k, v := range c.bb {
+			if m, ok := c.sample(v); ok {
+				m.Time = t.Add(time.Duration(float64(k) / rate * 1e9))
+				if d.cfg.OnMessage != nil {
+					d.cfg.OnMessage(m)
+				}
+			}
+		}
+	}
+}
+
+// sample demodulates one sample, returning a message when it completes
+// one.
+func (c *channel) sample(v complex64) (Message, bool) {
+	re, im := float64(real(v)), float64(imag(v))
+	c.power += re*re + im*im
+	c.n++
+
+	// the phase change over a symbol, which averages the frequency
+	// over it
+	p := v * complex(real(c.hist[c.k]), -imag(c.hist[c.k]))
+	c.hist[c.k] = v
+	c.k = (c.k + 1) % sps
+	f := math.Atan2(float64(imag(p)), float64(real(p)))
+	// weighted so the noise between messages doesn't upset it
+	a := math.Hypot(float64(real(p)), float64(imag(p)))
+	c.sf += a*f - c.wf[c.j]
+	c.sa += a - c.wa[c.j]
+	c.wf[c.j], c.wa[c.j] = a*f, a
+	c.j = (c.j + 1) % meanLen
+	if !c.lock && c.sa > 0 {
+		c.dc = c.sf / c.sa
+	}
+	high := f > c.dc
+	if high != c.last {
+		// transitions belong half way between symbol samples, this
+		// one was the fraction of a sample ago the frequency crossed
+		// the threshold
+		x := (f - c.dc) / (f - c.prev) * baud / rate
+		c.clk -= clockGain * (c.clk - x - 0.5)
+		c.last = high
+	}
+	c.prev = f
+	if c.clk += baud / rate; c.clk < 1 {
+		return Message{}, false
+	}
+	c.clk--
+	c.freq[c.nsym%training] = f
+	c.nsym++
+
+	// NRZI, a change is a 0
+	b := byte(1)
+	if high != c.sym {
+		b = 0
+	}
+	c.sym = high
+	return c.bit(b)
+}
+
+// bit deframes one bit, returning a message when it completes one.
+func (c *channel) bit(b byte) (Message, bool) {
+	f, flag := c.hdlc.Bit(b)
+	if !flag {
+		return Message{}, false
+	}
+	var m Message
+	var ok bool
+	if f != nil {
+		m, ok = c.message(f), true
+	}
+	// a flag that ends neither a message nor a training sequence
+	// leaves the threshold to the mean
+	c.lock = c.offset() || ok
+	c.power, c.n = 0, 0
+	return m, ok
+}
+
+// offset estimates the frequency offset when a flag is seen, from the
+// symbols before it. If it's a start flag they're the training
+// sequence, alternating bits, which NRZI codes as alternate pairs of
+// symbols whose mean is the offset. The estimate is kept, and true
+// returned, only when the symbols decode as such around it, so flags in
+// noise don't upset it.
+func (c *channel) offset() bool {
+	const n = training - 8 // skip the flag's 8 symbols
+	var f [n]float64
+	var sum float64
+	for i := range f {
+		f[i] = c.freq[(c.nsym+i)%training]
+		sum += f[i]
+	}
+	dc := sum / n
+	errs := 0
+	for i := 1; i < n; i++ {
+		// a 0 is a change, alternating with 1s
+		change := f[i] > dc != (f[i-1] > dc)
+		if change == (i%2 == 0) {
+			errs++
+		}
+	}
+	if errs <= 1 || errs >= n-2 {
+		c.dc = dc
+		return true
+	}
+	return false
+}
+
+// message parses a frame whose CRC checked.
+func (c *channel) message(data []byte) Message {
+	for i, v := range data {
+		data[i] = bits.Reverse8(v)
+	}
+	m := parse(data)
+	m.Channel = c.name
+	m.SignalDb = iq.DB(c.power / float64(c.n))
+	return m
+}
+
+func grow(dst []complex64, n int) []complex64 {
+	if cap(dst) < n {
+		return make([]complex64, n)
+	}
+	return dst[:n]
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package ais
+
+import (
+	"bytes"
+	"math"
+	"math/bits"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+	"github.com/jpoirier/gortlsdr/internal/hdlc"
+)
+
+// unarmor returns the message bits an !AIVDM payload carries.
+func unarmor(p string, fill int) []byte {
+	var b []byte
+	for i := 0; i < len(p); i++ {
+		c := p[i] - 48
+		if c > 40 {
+			c -= 8
+		}
+		for k := 5; k >= 0; k-- {
+			b = append(b, c>>uint(k)&1)
+		}
+	}
+	b = b[:len(b)-fill]
+	data := make([]byte, (len(b)+7)/8)
+	for i, v := range b {
+		data[i/8] |= v << uint(7-i%8)
+	}
+	return data
+}
+
+var messages = []struct {
+	channel byte
+	payload string
+	fill    int
+	line    string
+}{
+	{'B', "177KQJ5000G?tO`K>RA1wUbN0TKH", 0, "!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C"},
+	{'A', "55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp888888888880", 2,
+		"!AIVDM,2,1,0,A,55?MbV02;H;s<HtKR20EHE:0@T4@Dn2222222216L961O5Gf0NSQEp6ClRp8,0*1D\r\n" +
+			"!AIVDM,2,2,0,A,88888888880,2*24"},
+}
+
+func TestSentences(t *testing.T) {
+	for _, s := range messages {
+		m := parse(unarmor(s.payload, s.fill))
+		m.Channel = s.channel
+		var b bytes.Buffer
+		NMEALines(&b)(m)
+		if got := b.String(); got != s.line+"\r\n" {
+			t.Errorf("got %q, want %q", got, s.line+"\r\n")
+		}
+	}
+}
+
+// symbols returns the symbols sent for a message: the training
+// sequence, the flags around the stuffed bits and CRC, NRZI coded.
+func symbols(data []byte) []byte {
+	buf := make([]byte, len(data))
+	for i, v := range data {
+		buf[i] = bits.Reverse8(v)
+	}
+	fcs := hdlc.FCS(buf)
+	buf = append(buf, byte(fcs), byte(fcs>>8))
+
+	var raw []byte
+	for i := 0; i < training; i++ {
+		raw = append(raw, byte(i&1))
+	}
+	flag := []byte{0, 1, 1, 1, 1, 1, 1, 0}
+	raw = append(raw, flag...)
+	ones := 0
+	for _, v := range buf {
+		for k := 0; k < 8; k++ {
+			b := v >> uint(k) & 1
+			raw = append(raw, b)
+			if b == 0 {
+				ones = 0
+			} else if ones++; ones == 5 {
+				raw = append(raw, 0)
+				ones = 0
+			}
+		}
+	}
+	raw = append(raw, flag...)
+	raw = append(raw, 0, 0, 0, 0)
+
+	sym := make([]byte, len(raw))
+	level := byte(0)
+	for i, b := range raw {
+		if b == 0 {
+			level ^= 1
+		}
+		sym[i] = level
+	}
+	return sym
+}
+
+// modulate adds the symbols as GMSK, BT 0.4, at f Hz to x at fs,
+// starting at sample offset start, which may be fractional.
+func modulate(x []complex64, fs, f, start float64, sym []byte) {
+	sps := fs / baud
+	g := dsp.Gaussian(sps, 0.4, 4)
+	nrz := make([]float64, int(float64(len(sym))*sps))
+	for i := range nrz {
+		nrz[i] = float64(2*int(sym[int(float64(i)/sps)]) - 1)
+	}
+	var phase float64
+	i0 := int(math.Ceil(start))
+	for i := 0; i < len(nrz)+len(g) && i0+i < len(x); i++ {
+		var v float64
+		for j, h := range g {
+			if k := i - j; k >= 0 && k < len(nrz) {
+				v += nrz[k] * h
+			}
+		}
+		// modulation index 0.5, a quarter cycle per symbol
+		phase += math.Pi / 2 * v / sps
+		t := float64(i0+i) - start
+		p := phase + 2*math.Pi*f*t/fs
+		x[i0+i] += complex64(complex(0.3*math.Cos(p), 0.3*math.Sin(p)))
+	}
+}
+
+// TestReceive sends each message on both channels to a new decoder
+// across frequency offsets and symbol timing phases.
+func TestReceive(t *testing.T) {
+	const fs = 288000.0
+	r := rand.New(rand.NewSource(1))
+	for _, off := range []float64{-1000, -500, -300, 0, 300, 500, 1000} {
+		for _, phase := range []float64{0, 0.25, 0.5, 0.75} {
+			for i, s := range messages {
+				data := unarmor(s.payload, s.fill)
+				sym := symbols(data)
+				x := make([]complex64, int(float64(2*len(sym)+200)*fs/baud))
+				for k := range x {
+					x[k] = complex(float32(0.02*r.NormFloat64()), float32(0.02*r.NormFloat64()))
+				}
+				start := 1000 + phase*fs/baud
+				modulate(x, fs, -channelOffset+off, start, sym)
+				modulate(x, fs, channelOffset+off, start+float64(len(sym)+100)*fs/baud, sym)
+
+				var got []Message
+				d, err := NewDecoder(Config{SampleRate: fs, OnMessage: func(m Message) { got = append(got, m) }})
+				if err != nil {
+					t.Fatal(err)
+				}
+				for k := 0; k < len(x); k += 16384 {
+					e := k + 16384
+					if e > len(x) {
+						e = len(x)
+					}
+					d.Process(x[k:e], time.Time{})
+				}
+				if len(got) != 2 {
+					t.Errorf("offset %v Hz, phase %v, message %d: got %d messages, want 2", off, phase, i, len(got))
+					continue
+				}
+				for k, m := range got {
+					if !bytes.Equal(m.Data, data) || m.Channel != "AB"[k] {
+						t.Errorf("offset %v Hz, phase %v, message %d: got %c %x", off, phase, i, m.Channel, m.Data)
+					}
+				}
+			}
+		}
+	}
+}
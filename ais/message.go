@@ -0,0 +1,228 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package ais
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Message is a received AIS message and the fields decoded from it,
+// for types 1 to 5, 18, 19 and 24. The fields are only meaningful for
+// the types sending them and, where there is one, when their Has flag
+// is set.
+type Message struct {
+	Time     time.Time
+	Channel  byte   // 'A' or 'B'
+	Data     []byte // the message bits, most significant first
+	SignalDb float64
+
+	Type   int
+	Repeat int
+	MMSI   uint32
+
+	NavStatus int // types 1 to 3, 15 when not defined
+	HasTurn   bool
+	Turn      float64 // degrees per minute, positive to starboard
+
+	HasSpeed    bool
+	Speed       float64 // knots over ground
+	HasPosition bool
+	Lat, Lon    float64
+	Accurate    bool // position within 10 m
+	HasCourse   bool
+	Course      float64 // degrees over ground
+	HasHeading  bool
+	Heading     int // degrees
+	Second      int // UTC second of the report, 60 or more when not available
+
+	UTC time.Time // type 4, zero when not available
+
+	IMO         uint32
+	Callsign    string
+	Name        string
+	ShipType    int
+	Dimension   [4]int  // metres from the position reference to bow, stern, port and starboard
+	Draught     float64 // metres
+	ETA         string  // "MM-DD HH:MM" UTC, empty when not available
+	Destination string
+
+	Part int // type 24, 0 for part A with the name, 1 for part B
+}
+
+// String returns a one line summary.
+func (m *Message) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%c type %d %09d", m.Channel, m.Type, m.MMSI)
+	if m.Name != "" {
+		fmt.Fprintf(&b, " %q", m.Name)
+	}
+	if m.Callsign != "" {
+		fmt.Fprintf(&b, " %s", m.Callsign)
+	}
+	if m.HasPosition {
+		fmt.Fprintf(&b, " %.5f,%.5f", m.Lat, m.Lon)
+	}
+	if m.HasSpeed {
+		fmt.Fprintf(&b, " %.1fkn", m.Speed)
+	}
+	if m.HasCourse {
+		fmt.Fprintf(&b, " %.1f°", m.Course)
+	}
+	if m.Destination != "" {
+		fmt.Fprintf(&b, " to %s", m.Destination)
+	}
+	if !m.UTC.IsZero() {
+		fmt.Fprintf(&b, " %s", m.UTC.Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+// field returns the n bits of data starting at bit i, 0 past the end as
+// some stations send short messages.
+func field(data []byte, i, n int) uint32 {
+	var v uint32
+	for k := i; k < i+n; k++ {
+		v <<= 1
+		if k/8 < len(data) {
+			v |= uint32(data[k/8] >> uint(7-k%8) & 1)
+		}
+	}
+	return v
+}
+
+// signed returns the n bit two's complement field at bit i.
+func signed(data []byte, i, n int) int32 {
+	return int32(field(data, i, n)<<uint(32-n)) >> uint(32-n)
+}
+
+const sixBitChars = "@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_ !\"#$%&'()*+,-./0123456789:;<=>?"
+
+// text decodes n six bit characters at bit i, dropping the trailing
+// padding.
+func text(data []byte, i, n int) string {
+	s := make([]byte, n)
+	for k := range s {
+		s[k] = sixBitChars[field(data, i+6*k, 6)]
+	}
+	return strings.TrimRight(string(s), "@ ")
+}
+
+// parse decodes the fields of the common message types.
+func parse(data []byte) Message {
+	m := Message{
+		Data:   data,
+		Type:   int(field(data, 0, 6)),
+		Repeat: int(field(data, 6, 2)),
+		MMSI:   field(data, 8, 30),
+	}
+	switch m.Type {
+	case 1, 2, 3:
+		m.NavStatus = int(field(data, 38, 4))
+		m.turn(signed(data, 42, 8))
+		m.speed(field(data, 50, 10))
+		m.position(data, 60)
+		m.course(field(data, 116, 12), field(data, 128, 9))
+		m.Second = int(field(data, 137, 6))
+
+	case 4:
+		year, month, day := field(data, 38, 14), field(data, 52, 4), field(data, 56, 5)
+		hour, min, sec := field(data, 61, 5), field(data, 66, 6), field(data, 72, 6)
+		if year != 0 && month != 0 && day != 0 && hour < 24 && min < 60 && sec < 60 {
+			m.UTC = time.Date(int(year), time.Month(month), int(day), int(hour), int(min), int(sec), 0, time.UTC)
+		}
+		m.position(data, 78)
+
+	case 5:
+		m.IMO = field(data, 40, 30)
+		m.Callsign = text(data, 70, 7)
+		m.Name = text(data, 112, 20)
+		m.ShipType = int(field(data, 232, 8))
+		m.dimension(data, 240)
+		month, day := field(data, 274, 4), field(data, 278, 5)
+		hour, min := field(data, 283, 5), field(data, 288, 6)
+		if month != 0 && day != 0 && hour < 24 && min < 60 {
+			m.ETA = fmt.Sprintf("%02d-%02d %02d:%02d", month, day, hour, min)
+		}
+		m.Draught = float64(field(data, 294, 8)) / 10
+		m.Destination = text(data, 302, 20)
+
+	case 18, 19:
+		m.speed(field(data, 46, 10))
+		m.position(data, 56)
+		m.course(field(data, 112, 12), field(data, 124, 9))
+		m.Second = int(field(data, 133, 6))
+		if m.Type == 19 {
+			m.Name = text(data, 143, 20)
+			m.ShipType = int(field(data, 263, 8))
+			m.dimension(data, 271)
+		}
+
+	case 24:
+		m.Part = int(field(data, 38, 2))
+		switch m.Part {
+		case 0:
+			m.Name = text(data, 40, 20)
+		case 1:
+			m.ShipType = int(field(data, 40, 8))
+			m.Callsign = text(data, 90, 7)
+			// auxiliary craft send their mothership's MMSI instead
+			if m.MMSI/10000000 != 98 {
+				m.dimension(data, 132)
+			}
+		}
+	}
+	return m
+}
+
+// turn decodes the rate of turn indicator, which is 4.733 times the
+// square root of the rate.
+func (m *Message) turn(v int32) {
+	if v < -126 || v > 126 {
+		// not available, or turning faster than 5° in 30 s without a
+		// turn indicator
+		return
+	}
+	r := float64(v) / 4.733
+	m.Turn = math.Copysign(r*r, r)
+	m.HasTurn = true
+}
+
+func (m *Message) speed(v uint32) {
+	if v != 1023 {
+		m.Speed, m.HasSpeed = float64(v)/10, true
+	}
+}
+
+// position decodes the accuracy flag and the longitude and latitude, in
+// 1/10000 minutes, at bit i.
+func (m *Message) position(data []byte, i int) {
+	m.Accurate = field(data, i, 1) != 0
+	lon := float64(signed(data, i+1, 28)) / 600000
+	lat := float64(signed(data, i+29, 27)) / 600000
+	if math.Abs(lon) <= 180 && math.Abs(lat) <= 90 {
+		m.Lat, m.Lon, m.HasPosition = lat, lon, true
+	}
+}
+
+func (m *Message) course(cog, heading uint32) {
+	if cog < 3600 {
+		m.Course, m.HasCourse = float64(cog)/10, true
+	}
+	if heading < 360 {
+		m.Heading, m.HasHeading = int(heading), true
+	}
+}
+
+func (m *Message) dimension(data []byte, i int) {
+	m.Dimension = [4]int{
+		int(field(data, i, 9)),
+		int(field(data, i+9, 9)),
+		int(field(data, i+18, 6)),
+		int(field(data, i+24, 6)),
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package ais
+
+import (
+	"fmt"
+	"io"
+)
+
+// NMEAPort is the UDP port AIS NMEA feeds are commonly sent to, as
+// OpenCPN listens on.
+const NMEAPort = 10110
+
+// maxPayload is the most payload characters put in one sentence,
+// keeping it within the 82 character NMEA limit.
+const maxPayload = 60
+
+// payload armours the message bits as six bit ASCII, returning the
+// characters and the count of fill bits padding the last.
+func payload(data []byte) (string, int) {
+	n := len(data) * 8
+	s := make([]byte, (n+5)/6)
+	for k := range s {
+		c := byte(field(data, 6*k, 6)) + 48
+		if c > 87 {
+			c += 8
+		}
+		s[k] = c
+	}
+	return string(s), len(s)*6 - n
+}
+
+// Sentences returns the !AIVDM sentences carrying m, without line
+// endings, split over several when long. seq is the sequential message
+// id, 0 to 9, identifying the parts of a multi-sentence message.
+func Sentences(m *Message, seq int) []string {
+	p, fill := payload(m.Data)
+	count := (len(p) + maxPayload - 1) / maxPayload
+	id := ""
+	if count > 1 {
+		id = fmt.Sprint(seq % 10)
+	}
+	var out []string
+	for i := 0; i < count; i++ {
+		part := p[i*maxPayload:]
+		if len(part) > maxPayload {
+			part = part[:maxPayload]
+		}
+		f := 0
+		if i == count-1 {
+			f = fill
+		}
+		body := fmt.Sprintf("AIVDM,%d,%d,%s,%c,%s,%d", count, i+1, id, m.Channel, part, f)
+		out = append(out, fmt.Sprintf("!%s*%02X", body, checksum(body)))
+	}
+	return out
+}
+
+// checksum is the XOR of the characters between '!' and '*'.
+func checksum(s string) byte {
+	var c byte
+	for i := 0; i < len(s); i++ {
+		c ^= s[i]
+	}
+	return c
+}
+
+// NMEALines returns a message handler writing each message to w as
+// !AIVDM sentences, one per write, numbering the multi-sentence
+// messages.
+func NMEALines(w io.Writer) func(Message) {
+	seq := 0
+	return func(m Message) {
+		s := Sentences(&m, seq)
+		if len(s) > 1 {
+			seq = (seq + 1) % 10
+		}
+		for _, l := range s {
+			io.WriteString(w, l+"\r\n")
+		}
+	}
+}
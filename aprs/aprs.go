@@ -0,0 +1,317 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package aprs parses the position and status reports of APRS packets
+// received as AX.25 frames: uncompressed, compressed and Mic-E
+// positions, with their symbol, course and speed, altitude and comment,
+// and status text.
+package aprs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jpoirier/gortlsdr/ax25"
+)
+
+// Packet is a parsed APRS packet. The position fields are only
+// meaningful when HasPosition is set.
+type Packet struct {
+	Frame ax25.Frame
+	Type  byte // the data type identifier, the first character of the information field
+
+	Timestamp string // as sent, e.g. "092345z", empty when there's none
+	Messaging bool   // the station can receive messages
+
+	HasPosition bool
+	Lat, Lon    float64
+	Ambiguity   int // digits of the position left out
+	SymbolTable byte
+	SymbolCode  byte
+
+	HasCourse   bool
+	Course      int     // degrees, 0 when not known
+	Speed       float64 // knots
+	HasAltitude bool
+	Altitude    float64 // metres
+
+	Status  string
+	Comment string
+}
+
+// String returns a one line summary.
+func (p *Packet) String() string {
+	var b strings.Builder
+	b.WriteString(p.Frame.Source.String())
+	if p.HasPosition {
+		fmt.Fprintf(&b, " %.5f,%.5f %c%c", p.Lat, p.Lon, p.SymbolTable, p.SymbolCode)
+	}
+	if p.HasCourse {
+		fmt.Fprintf(&b, " %d° %.0fkn", p.Course, p.Speed)
+	}
+	if p.HasAltitude {
+		fmt.Fprintf(&b, " %.0fm", p.Altitude)
+	}
+	if p.Status != "" {
+		fmt.Fprintf(&b, " status %q", p.Status)
+	}
+	if p.Comment != "" {
+		fmt.Fprintf(&b, " %q", p.Comment)
+	}
+	return b.String()
+}
+
+// Parse parses the APRS packet in f. Packet types other than positions
+// and status reports are returned with only Type set.
+func Parse(f ax25.Frame) (Packet, error) {
+	p := Packet{Frame: f}
+	if !f.IsUI() || len(f.Info) == 0 {
+		return p, errors.New("not an APRS packet")
+	}
+	info := string(f.Info)
+	p.Type = info[0]
+	var err error
+	switch p.Type {
+	case '!', '=':
+		p.Messaging = p.Type == '='
+		err = p.position(info[1:])
+	case '/', '@':
+		p.Messaging = p.Type == '@'
+		if len(info) < 8 {
+			return p, errors.New("truncated timestamp")
+		}
+		p.Timestamp = info[1:8]
+		err = p.position(info[8:])
+	case '`', '\'':
+		err = p.micE(f.Dest.Call, info[1:])
+	case '>':
+		s := info[1:]
+		if len(s) >= 7 && s[6] == 'z' && digits(s[:6]) {
+			p.Timestamp, s = s[:7], s[7:]
+		}
+		p.Status = s
+	}
+	return p, err
+}
+
+// position parses an uncompressed or compressed position and what
+// follows it.
+func (p *Packet) position(s string) error {
+	if len(s) > 0 && (s[0] == '/' || s[0] == '\\' || s[0] >= 'A' && s[0] <= 'Z' || s[0] >= 'a' && s[0] <= 'j') {
+		return p.compressed(s)
+	}
+	// DDMM.hhN/DDDMM.hhW$ with spaces for the digits left out
+	if len(s) < 19 {
+		return errors.New("truncated position")
+	}
+	lat, amb, ok := degrees(s[:7], 2)
+	if !ok || (s[7] != 'N' && s[7] != 'S') {
+		return errors.New("invalid latitude")
+	}
+	lon, _, ok := degrees(s[9:17], 3)
+	if !ok || (s[17] != 'E' && s[17] != 'W') {
+		return errors.New("invalid longitude")
+	}
+	if s[7] == 'S' {
+		lat = -lat
+	}
+	if s[17] == 'W' {
+		lon = -lon
+	}
+	if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return errors.New("position out of range")
+	}
+	p.Lat, p.Lon, p.Ambiguity, p.HasPosition = lat, lon, amb, true
+	p.SymbolTable, p.SymbolCode = s[8], s[18]
+	s = s[19:]
+
+	// course and speed, CSE/SPD
+	if len(s) >= 7 && s[3] == '/' && digits(s[:3]) && digits(s[4:7]) {
+		c, _ := strconv.Atoi(s[:3])
+		v, _ := strconv.Atoi(s[4:7])
+		p.Course, p.Speed, p.HasCourse = c, float64(v), true
+		s = s[7:]
+	}
+	p.comment(s)
+	return nil
+}
+
+// degrees parses DDMM.hh, with n digits of degrees, returning the
+// number of digits replaced by spaces.
+func degrees(s string, n int) (float64, int, bool) {
+	if len(s) != n+5 || s[n+2] != '.' {
+		return 0, 0, false
+	}
+	b := []byte(s)
+	amb := 0
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] == ' ' {
+			b[i] = '0'
+			amb++
+		}
+	}
+	t := string(b)
+	if !digits(t[:n+2]) || !digits(t[n+3:]) {
+		return 0, 0, false
+	}
+	d, _ := strconv.Atoi(t[:n])
+	m, _ := strconv.ParseFloat(t[n:], 64)
+	if m >= 60 {
+		return 0, 0, false
+	}
+	return float64(d) + m/60, amb, true
+}
+
+// compressed parses a compressed position: the symbol table, base 91
+// latitude and longitude, the symbol code, course and speed or altitude,
+// and the compression type.
+func (p *Packet) compressed(s string) error {
+	if len(s) < 13 {
+		return errors.New("truncated compressed position")
+	}
+	for _, c := range []byte(s[1:9]) {
+		if c < '!' || c > '{' {
+			return errors.New("invalid compressed position")
+		}
+	}
+	p.Lat = 90 - float64(base91(s[1:5]))/380926
+	p.Lon = -180 + float64(base91(s[5:9]))/190463
+	p.HasPosition = true
+	p.SymbolTable, p.SymbolCode = s[0], s[9]
+	c, v, t := s[10], s[11], s[12]
+	switch {
+	case c == ' ':
+	case (t-33)>>3&3 == 2:
+		// altitude from a GGA sentence, in feet
+		p.Altitude = math.Pow(1.002, float64(base91(s[10:12]))) * 0.3048
+		p.HasAltitude = true
+	case c >= '!' && c <= 'z':
+		p.Course = int(c-33) * 4
+		p.Speed = math.Pow(1.08, float64(v-33)) - 1
+		p.HasCourse = true
+	}
+	p.comment(s[13:])
+	return nil
+}
+
+// micE parses a Mic-E position. The latitude and the longitude's
+// offsets are coded in the destination address, the longitude, course
+// and speed in the information field as characters offset by 28.
+func (p *Packet) micE(dest, s string) error {
+	if len(dest) != 6 || len(s) < 8 {
+		return errors.New("truncated Mic-E position")
+	}
+	var lat [6]byte
+	for i := range lat {
+		c := dest[i]
+		switch {
+		case c >= '0' && c <= '9':
+			lat[i] = c
+		case c >= 'A' && c <= 'J':
+			lat[i] = c - 'A' + '0'
+		case c >= 'P' && c <= 'Y':
+			lat[i] = c - 'P' + '0'
+		case c == 'K' || c == 'L' || c == 'Z':
+			// a digit left out
+			lat[i] = '0'
+			p.Ambiguity++
+		default:
+			return errors.New("invalid Mic-E destination")
+		}
+	}
+	d, _ := strconv.Atoi(string(lat[:2]))
+	m, _ := strconv.Atoi(string(lat[2:]))
+	p.Lat = float64(d) + float64(m)/6000
+	if dest[3] < 'P' {
+		p.Lat = -p.Lat
+	}
+
+	for _, c := range []byte(s[:6]) {
+		if c < 28 || c > 127 {
+			return errors.New("invalid Mic-E information")
+		}
+	}
+	lon := int(s[0]) - 28
+	if dest[4] >= 'P' {
+		lon += 100
+	}
+	switch {
+	case lon >= 180 && lon <= 189:
+		lon -= 80
+	case lon >= 190 && lon <= 199:
+		lon -= 190
+	}
+	min := int(s[1]) - 28
+	if min >= 60 {
+		min -= 60
+	}
+	hun := int(s[2]) - 28
+	p.Lon = float64(lon) + (float64(min)+float64(hun)/100)/60
+	if dest[5] >= 'P' {
+		p.Lon = -p.Lon
+	}
+	if p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+		return errors.New("Mic-E position out of range")
+	}
+	p.HasPosition = true
+
+	sp, dc, se := int(s[3])-28, int(s[4])-28, int(s[5])-28
+	speed := sp*10 + dc/10
+	if speed >= 800 {
+		speed -= 800
+	}
+	course := dc%10*100 + se
+	if course >= 400 {
+		course -= 400
+	}
+	p.Course, p.Speed, p.HasCourse = course, float64(speed), true
+	p.SymbolCode, p.SymbolTable = s[6], s[7]
+
+	// the altitude, 3 base 91 digits of metres above -10 km and a '}',
+	// may follow a radio type character
+	s = s[8:]
+	for _, k := range []int{0, 1} {
+		if len(s) >= k+4 && s[k+3] == '}' {
+			p.Altitude = float64(base91(s[k:k+3])) - 10000
+			p.HasAltitude = true
+			s = s[:k] + s[k+4:]
+			break
+		}
+	}
+	p.Comment = s
+	return nil
+}
+
+// comment takes the altitude, /A= and 6 digits of feet, from the
+// comment.
+func (p *Packet) comment(s string) {
+	if i := strings.Index(s, "/A="); i >= 0 && len(s) >= i+9 {
+		a := s[i+3 : i+9]
+		if v, err := strconv.Atoi(a); err == nil {
+			p.Altitude, p.HasAltitude = float64(v)*0.3048, true
+			s = s[:i] + s[i+9:]
+		}
+	}
+	p.Comment = s
+}
+
+func base91(s string) int {
+	v := 0
+	for _, c := range []byte(s) {
+		v = v*91 + int(c) - 33
+	}
+	return v
+}
+
+func digits(s string) bool {
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
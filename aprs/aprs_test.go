@@ -0,0 +1,119 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package aprs
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/jpoirier/gortlsdr/ax25"
+)
+
+func uiFrame(dest, info string) ax25.Frame {
+	return ax25.Frame{
+		Dest:    ax25.Address{Call: dest},
+		Source:  ax25.Address{Call: "N0CALL", SSID: 9},
+		Control: 0x03,
+		PID:     0xf0,
+		Info:    []byte(info),
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		dest, info string
+		want       Packet
+	}{
+		{"APRS", "!4903.50N/07201.75W-Test 001234", Packet{
+			Type: '!', HasPosition: true, Lat: 49.05833, Lon: -72.02917, SymbolTable: '/', SymbolCode: '-',
+			Comment: "Test 001234",
+		}},
+		{"APRS", "@092345z4903.50N/07201.75W>088/036/A=001234 moving", Packet{
+			Type: '@', Timestamp: "092345z", Messaging: true,
+			HasPosition: true, Lat: 49.05833, Lon: -72.02917, SymbolTable: '/', SymbolCode: '>',
+			HasCourse: true, Course: 88, Speed: 36, HasAltitude: true, Altitude: 376.1232, Comment: " moving",
+		}},
+		{"APRS", "!49  .  S\\072  .  E#", Packet{
+			Type: '!', HasPosition: true, Lat: -49, Lon: 72, Ambiguity: 4, SymbolTable: '\\', SymbolCode: '#',
+		}},
+		// the compressed examples of the APRS 1.01 specification
+		{"APRS", "=/5L!!<*e7>7P[", Packet{
+			Type: '=', Messaging: true, HasPosition: true, Lat: 49.5, Lon: -72.75, SymbolTable: '/', SymbolCode: '>',
+			HasCourse: true, Course: 88, Speed: 36.23201,
+		}},
+		{"APRS", "!/5L!!<*e7OS]S", Packet{
+			Type: '!', HasPosition: true, Lat: 49.5, Lon: -72.75, SymbolTable: '/', SymbolCode: 'O',
+			HasAltitude: true, Altitude: 3049.37771, // 10004 ft
+		}},
+		{"APRS", "!/5L!!<*e7>  !comment", Packet{
+			Type: '!', HasPosition: true, Lat: 49.5, Lon: -72.75, SymbolTable: '/', SymbolCode: '>',
+			Comment: "comment",
+		}},
+		// latitude 42 30.07 N, no longitude offset, west; longitude 71
+		// 07.58, speed 12 kn, course 276, the altitude 34 m
+		{"T2SP0W", "`c_Vm6hk/\"49}Test", Packet{
+			Type: '`', HasPosition: true, Lat: 42.50117, Lon: -71.12633, SymbolTable: '/', SymbolCode: 'k',
+			HasCourse: true, Course: 276, Speed: 12, HasAltitude: true, Altitude: 34, Comment: "Test",
+		}},
+		// latitude 34 42 S with the minutes' digits left out, a
+		// longitude offset of 100, east; longitude 150 30.50, speed 25
+		// kn, course 190
+		{"S4T2ZL", "'N:NnOv>/", Packet{
+			Type: '\'', HasPosition: true, Lat: -34.7, Lon: 150.50833, Ambiguity: 2, SymbolTable: '/', SymbolCode: '>',
+			HasCourse: true, Course: 190, Speed: 25,
+		}},
+		{"APRS", ">092345zNet Control Center", Packet{
+			Type: '>', Timestamp: "092345z", Status: "Net Control Center",
+		}},
+		{"APRS", ">Just status", Packet{Type: '>', Status: "Just status"}},
+		{"APRS", ":N0CALL   :hello{1", Packet{Type: ':'}},
+	} {
+		p, err := Parse(uiFrame(c.dest, c.info))
+		if err != nil {
+			t.Errorf("%s: %v", c.info, err)
+			continue
+		}
+		for _, v := range []struct{ got, want *float64 }{
+			{&p.Lat, &c.want.Lat},
+			{&p.Lon, &c.want.Lon},
+			{&p.Speed, &c.want.Speed},
+			{&p.Altitude, &c.want.Altitude},
+		} {
+			if math.Abs(*v.got-*v.want) < 1e-5 {
+				*v.got = *v.want
+			}
+		}
+		p.Frame = ax25.Frame{}
+		if !reflect.DeepEqual(p, c.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", c.info, p, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, c := range []struct{ dest, info string }{
+		{"APRS", ""},
+		{"APRS", "!4903.50X/07201.75W-"},
+		{"APRS", "!4903.50N/07201.75Q-"},
+		{"APRS", "!4963.50N/07201.75W-"},
+		{"APRS", "!4903.50N/07201.75"},
+		{"APRS", "/0923"},
+		{"APRS", "!/5L!!<*e7>"},
+		{"APRS", "!/5L!!<*e\x7f>7P["},
+		{"T2SP0W", "`c_V"},
+		{"T2SP0", "`c_Vm6hk/"},
+		{"T2SPMW", "`c_Vm6hk/"},
+	} {
+		if p, err := Parse(uiFrame(c.dest, c.info)); err == nil {
+			t.Errorf("%s>%s: got %s, want an error", c.dest, c.info, p.String())
+		}
+	}
+	f := uiFrame("APRS", ">status")
+	f.Control = 0x01
+	if _, err := Parse(f); err == nil {
+		t.Error("parsed an RR frame")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package ax25 demodulates AFSK1200, the Bell 202 tones packet radio and
+// APRS use on VHF, and decodes the AX.25 frames, from FM discriminator
+// audio such as demod.NBFM's or rtl_fm's.
+//
+// The audio is correlated with the 1200 Hz mark and 2200 Hz space tones
+// over a bit and the bit sliced by the larger magnitude, which tolerates
+// the tones' levels differing by 10 dB or more with pre-emphasis. The
+// bits are clocked by a loop tracking their transitions, NRZI decoded
+// and searched for HDLC flags. The bits between flags are unstuffed and
+// the frame kept when its FCS checks and its addresses are valid.
+// Frames can be written in the TNC2 monitor format, or KISS encoded for
+// APRS clients expecting a TNC.
+package ax25
+
+import (
+	"errors"
+	"math"
+
+	"github.com/jpoirier/gortlsdr/internal/hdlc"
+)
+
+// Bell 202 modem parameters.
+const (
+	baud      = 1200.0
+	markFreq  = 1200.0
+	spaceFreq = 2200.0
+
+	clockGain = 0.2 // share of the timing error corrected per transition
+	maxFrame  = (330 + 2) * 8
+	minFrame  = (2*7 + 1 + 2) * 8 // two addresses, control and FCS
+)
+
+// Config holds the decoder settings.
+type Config struct {
+	SampleRate float64 // of the audio, at least 8 kHz
+
+	// OnFrame is called with each frame received, see Lines.
+	OnFrame func(Frame)
+}
+
+// Decoder demodulates AFSK1200 and decodes the AX.25 frames.
+type Decoder struct {
+	cfg   Config
+	mark  tone
+	space tone
+
+	clk  float64 // bit clock phase, bits are taken as it wraps
+	step float64 // bits per sample
+	last bool    // last sample's slice
+	sym  bool    // last bit's tone, for NRZI
+
+	hdlc *hdlc.Deframer
+}
+
+// tone correlates the audio with a tone over the last bit.
+type tone struct {
+	w     float64 // radians per sample
+	phase float64
+	hist  []complex128 // the last bit's products
+	k     int
+	sum   complex128
+}
+
+// sample correlates one sample, returning the magnitude over the bit.
+func (t *tone) sample(v float64) float64 {
+	s, c := math.Sincos(t.phase)
+	if t.phase += t.w; t.phase > math.Pi {
+		t.phase -= 2 * math.Pi
+	}
+	p := complex(v*c, -v*s)
+	t.sum += p - t.hist[t.k]
+	t.hist[t.k] = p
+	t.k = (t.k + 1) % len(t.hist)
+	return math.Hypot(real(t.sum), imag(t.sum))
+}
+
+// NewDecoder returns a decoder for the given configuration.
+func NewDecoder(cfg Config) (*Decoder, error) {
+	fs := cfg.SampleRate
+	if fs < 8e3 {
+		return nil, errors.New("sample rate too low for AFSK1200")
+	}
+	n := int(math.Floor(fs/baud + 0.5))
+	d := &Decoder{
+		cfg:   cfg,
+		mark:  tone{w: 2 * math.Pi * markFreq / fs, hist: make([]complex128, n)},
+		space: tone{w: 2 * math.Pi * spaceFreq / fs, hist: make([]complex128, n)},
+		step:  baud / fs,
+		hdlc:  hdlc.NewDeframer(minFrame, maxFrame),
+	}
+	return d, nil
+}
+
+// Process decodes the audio in x.
+func (d *Decoder) Process(x []float32) {
+	for _, v := range x {
+		d.sample(float64(v))
+	}
+}
+
+func (d *Decoder) sample(v float64) {
+	high := d.mark.sample(v) > d.space.sample(v)
+	if high != d.last {
+		// the correlations cross half way through
+		d.clk -= clockGain * (d.clk - 0.5)
+		d.last = high
+	}
+	if d.clk += d.step; d.clk < 1 {
+		return
+	}
+	d.clk--
+
+	// NRZI, a change is a 0
+	b := byte(1)
+	if high != d.sym {
+		b = 0
+	}
+	d.sym = high
+	if f, _ := d.hdlc.Bit(b); f != nil {
+		d.frame(f)
+	}
+}
+
+// frame decodes a frame whose FCS checked.
+func (d *Decoder) frame(b []byte) {
+	f, err := Decode(b)
+	if err != nil {
+		return
+	}
+	if d.cfg.OnFrame != nil {
+		d.cfg.OnFrame(f)
+	}
+}
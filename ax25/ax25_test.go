@@ -0,0 +1,191 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package ax25
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/jpoirier/gortlsdr/internal/hdlc"
+)
+
+// addr encodes an address field.
+func addr(call string, ssid int, last, h bool) []byte {
+	b := make([]byte, 7)
+	for k := range b[:6] {
+		c := byte(' ')
+		if k < len(call) {
+			c = call[k]
+		}
+		b[k] = c << 1
+	}
+	b[6] = 0x60 | byte(ssid)<<1
+	if last {
+		b[6] |= 1
+	}
+	if h {
+		b[6] |= 0x80
+	}
+	return b
+}
+
+// testFrame returns a UI frame with the info field, from N0CALL-9 to
+// APRS via WIDE1-1, repeated, and WIDE2-1.
+func testFrame(info string) []byte {
+	var b []byte
+	b = append(b, addr("APRS", 0, false, true)...)
+	b = append(b, addr("N0CALL", 9, false, false)...)
+	b = append(b, addr("WIDE1", 1, false, true)...)
+	b = append(b, addr("WIDE2", 1, true, false)...)
+	b = append(b, 0x03, 0xf0)
+	return append(b, info...)
+}
+
+// bitstream returns the bits sent for the frame: flags, the frame and its
+// FCS bit stuffed, and closing flags.
+func bitstream(frame []byte) []byte {
+	fcs := hdlc.FCS(frame)
+	b := append(append([]byte(nil), frame...), byte(fcs), byte(fcs>>8))
+	var bits []byte
+	flags := func(n int) {
+		for i := 0; i < n*8; i++ {
+			bits = append(bits, 0x7e>>uint(i%8)&1)
+		}
+	}
+	flags(30)
+	ones := 0
+	for _, v := range b {
+		for k := uint(0); k < 8; k++ {
+			bit := v >> k & 1
+			bits = append(bits, bit)
+			if ones++; bit == 0 {
+				ones = 0
+			} else if ones == 5 {
+				bits = append(bits, 0)
+				ones = 0
+			}
+		}
+	}
+	flags(3)
+	return bits
+}
+
+// afsk returns the bits NRZI coded as Bell 202 tones at fs, the space
+// tone twist dB louder than the mark, between silences, with noise
+// relative to the weaker tone.
+// The transmitter's clock is 200 ppm slow.
+func afsk(bits []byte, fs, twist, noise float64, r *rand.Rand) []float32 {
+	sps := fs / baud * 1.0002
+	gap := int(fs * 0.05)
+	n := int(float64(len(bits)) * sps)
+	out := make([]float32, 2*gap+n)
+	for i := range out {
+		out[i] = float32(noise * r.NormFloat64())
+	}
+	// the weaker tone at 0.5
+	ma, sa := 0.5, 0.5*math.Pow(10, twist/20)
+	if sa < ma {
+		ma, sa = 0.5*0.5/sa, 0.5
+	}
+	mark := true
+	var phase float64
+	for i := 0; i < n; i++ {
+		k := int(float64(i) / sps)
+		if int(float64(i-1)/sps) != k || i == 0 {
+			// NRZI, a 0 changes the tone
+			if bits[k] == 0 {
+				mark = !mark
+			}
+		}
+		f, a := markFreq, ma
+		if !mark {
+			f, a = spaceFreq, sa
+		}
+		phase += 2 * math.Pi * f / fs
+		out[gap+i] += float32(a * math.Sin(phase))
+	}
+	return out
+}
+
+func TestDecode(t *testing.T) {
+	// the info field has KISS's special bytes and runs of 1s to stuff
+	raw := testFrame("!4903.50N/07201.75W-Test 001234 ~~\xc0\xdb\xff")
+	const want = "N0CALL-9>APRS,WIDE1-1*,WIDE2-1:!4903.50N/07201.75W-Test 001234 ~~\xc0\xdb\xff"
+	r := rand.New(rand.NewSource(1))
+	for _, fs := range []float64{8000, 11025, 22050, 48000} {
+		for _, twist := range []float64{-10, 0, 10} {
+			var got []Frame
+			d, err := NewDecoder(Config{SampleRate: fs, OnFrame: func(f Frame) { got = append(got, f) }})
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.Process(afsk(bitstream(raw), fs, twist, 0.1, r))
+			if len(got) != 1 {
+				t.Errorf("%v Hz, %v dB twist: got %d frames, want 1", fs, twist, len(got))
+				continue
+			}
+			f := got[0]
+			if !bytes.Equal(f.Raw, raw) || f.String() != want || !f.IsUI() {
+				t.Errorf("%v Hz, %v dB twist: got %q", fs, twist, f.String())
+			}
+		}
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		b    []byte
+		err  bool
+	}{
+		{"truncated address", testFrame("")[:10], true},
+		{"one address", append(addr("APRS", 0, true, false), 0x03, 0xf0), true},
+		{"invalid call", append(append(addr("AP-RS", 0, false, false), addr("N0CALL", 0, true, false)...), 0x03, 0xf0), true},
+		{"no control", append(addr("APRS", 0, false, false), addr("N0CALL", 0, true, false)...), true},
+		{"no PID", append(append(addr("APRS", 0, false, false), addr("N0CALL", 0, true, false)...), 0x03), true},
+		// a supervisory frame has no PID
+		{"RR", append(append(addr("APRS", 0, false, false), addr("N0CALL", 0, true, false)...), 0x01), false},
+		{"UI", testFrame("x"), false},
+	} {
+		_, err := Decode(c.b)
+		if (err != nil) != c.err {
+			t.Errorf("%s: got error %v", c.name, err)
+		}
+	}
+}
+
+func TestKISS(t *testing.T) {
+	for _, c := range []struct {
+		in, want []byte
+	}{
+		{[]byte{}, []byte{0xc0, 0x00, 0xc0}},
+		{[]byte("AB"), []byte{0xc0, 0x00, 'A', 'B', 0xc0}},
+		{[]byte{0xc0}, []byte{0xc0, 0x00, 0xdb, 0xdc, 0xc0}},
+		{[]byte{0xdb}, []byte{0xc0, 0x00, 0xdb, 0xdd, 0xc0}},
+		// the transposed bytes alone aren't escaped
+		{[]byte{0xdc, 0xdd}, []byte{0xc0, 0x00, 0xdc, 0xdd, 0xc0}},
+		{[]byte{0x01, 0xc0, 0xdb, 0xc0, 0x02}, []byte{0xc0, 0x00, 0x01, 0xdb, 0xdc, 0xdb, 0xdd, 0xdb, 0xdc, 0x02, 0xc0}},
+	} {
+		if got := KISS([]byte{0xff}, c.in); !bytes.Equal(got, append([]byte{0xff}, c.want...)) {
+			t.Errorf("KISS(%x): got %x, want ff%x", c.in, got, c.want)
+		}
+	}
+
+	var w bytes.Buffer
+	out := KISSFrames(&w)
+	for _, info := range []string{"a\xc0", "b\xdb"} {
+		f, err := Decode(testFrame(info))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out(f)
+	}
+	want := append(KISS(nil, testFrame("a\xc0")), KISS(nil, testFrame("b\xdb"))...)
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("got %x, want %x", w.Bytes(), want)
+	}
+}
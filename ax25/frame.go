@@ -0,0 +1,150 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package ax25
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Frame is a decoded AX.25 frame.
+type Frame struct {
+	Dest    Address
+	Source  Address
+	Path    []Address // digipeaters
+	Control byte
+	PID     byte // protocol identifier, 0xf0 for none, as APRS uses
+	Info    []byte
+
+	Raw []byte // the frame as received without the FCS, as KISS sends it
+}
+
+// Address is a station address.
+type Address struct {
+	Call     string
+	SSID     int
+	Repeated bool // a digipeater has repeated the frame
+}
+
+// String returns the address in the CALL-SSID form, without the SSID
+// when it's 0.
+func (a Address) String() string {
+	if a.SSID == 0 {
+		return a.Call
+	}
+	return a.Call + "-" + strconv.Itoa(a.SSID)
+}
+
+// IsUI reports whether f is an unnumbered information frame, as APRS
+// sends, with no layer 3 protocol.
+func (f *Frame) IsUI() bool {
+	return f.Control&^0x10 == 0x03 && f.PID == 0xf0
+}
+
+// String returns the frame in the TNC2 monitor format, e.g.
+// "N0CALL-9>APRS,WIDE1-1*:info", the last digipeater to repeat it
+// marked.
+func (f *Frame) String() string {
+	var b strings.Builder
+	b.WriteString(f.Source.String())
+	b.WriteByte('>')
+	b.WriteString(f.Dest.String())
+	last := -1
+	for i, a := range f.Path {
+		if a.Repeated {
+			last = i
+		}
+	}
+	for i, a := range f.Path {
+		b.WriteByte(',')
+		b.WriteString(a.String())
+		if i == last {
+			b.WriteByte('*')
+		}
+	}
+	b.WriteByte(':')
+	b.Write(f.Info)
+	return b.String()
+}
+
+// Lines returns a frame handler writing each frame to w in the TNC2
+// monitor format.
+func Lines(w io.Writer) func(Frame) {
+	return func(f Frame) {
+		io.WriteString(w, f.String()+"\n")
+	}
+}
+
+// Decode decodes the frame in b, without its FCS.
+func Decode(b []byte) (Frame, error) {
+	var addrs []Address
+	i := 0
+	for {
+		if i+7 > len(b) {
+			return Frame{}, errors.New("truncated address field")
+		}
+		a, ok := address(b[i : i+7])
+		if !ok {
+			return Frame{}, errors.New("invalid address")
+		}
+		addrs = append(addrs, a)
+		i += 7
+		// the last address is marked by its low bit
+		if b[i-1]&1 != 0 {
+			break
+		}
+	}
+	if len(addrs) < 2 || len(addrs) > 10 {
+		return Frame{}, errors.New("invalid number of addresses")
+	}
+	if i >= len(b) {
+		return Frame{}, errors.New("missing control field")
+	}
+	f := Frame{
+		Dest:    addrs[0],
+		Source:  addrs[1],
+		Path:    addrs[2:],
+		Control: b[i],
+		Raw:     b,
+	}
+	// the destination and source repeated bits are command and response
+	f.Dest.Repeated, f.Source.Repeated = false, false
+	i++
+	// information and unnumbered information frames carry a PID
+	if f.Control&1 == 0 || f.Control&^0x10 == 0x03 {
+		if i >= len(b) {
+			return Frame{}, errors.New("missing PID field")
+		}
+		f.PID = b[i]
+		i++
+	}
+	f.Info = b[i:]
+	return f, nil
+}
+
+// address decodes a 7 byte address field: six characters shifted up a
+// bit and space padded, then the SSID byte.
+func address(b []byte) (Address, bool) {
+	var call [6]byte
+	for k, v := range b[:6] {
+		call[k] = v >> 1
+	}
+	c := strings.TrimRight(string(call[:]), " ")
+	if c == "" {
+		return Address{}, false
+	}
+	for _, v := range []byte(c) {
+		if (v < 'A' || v > 'Z') && (v < '0' || v > '9') {
+			return Address{}, false
+		}
+	}
+	return Address{
+		Call:     c,
+		SSID:     int(b[6] >> 1 & 0xf),
+		Repeated: b[6]&0x80 != 0,
+	}, true
+}
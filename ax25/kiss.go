@@ -0,0 +1,45 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package ax25
+
+import "io"
+
+// KISSPort is the TCP port KISS TNCs are usually served on.
+const KISSPort = 8001
+
+// KISS framing bytes.
+const (
+	fend  = 0xc0
+	fesc  = 0xdb
+	tfend = 0xdc
+	tfesc = 0xdd
+)
+
+// KISS appends the KISS data frame carrying the frame b, without its
+// FCS, on TNC port 0 to dst.
+func KISS(dst, b []byte) []byte {
+	dst = append(dst, fend, 0)
+	for _, v := range b {
+		switch v {
+		case fend:
+			dst = append(dst, fesc, tfend)
+		case fesc:
+			dst = append(dst, fesc, tfesc)
+		default:
+			dst = append(dst, v)
+		}
+	}
+	return append(dst, fend)
+}
+
+// KISSFrames returns a frame handler writing each frame to w as a KISS
+// data frame, one write per frame.
+func KISSFrames(w io.Writer) func(Frame) {
+	var buf []byte
+	return func(f Frame) {
+		buf = KISS(buf[:0], f.Raw)
+		w.Write(buf)
+	}
+}
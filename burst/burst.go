@@ -0,0 +1,289 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package burst detects bursts of energy in an IQ stream and describes
+// them as SigMF annotations.
+package burst
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/iq"
+	"github.com/jpoirier/gortlsdr/sigmf"
+	"github.com/jpoirier/gortlsdr/spectrum"
+)
+
+// Config holds the detector settings. Zero values select the defaults.
+type Config struct {
+	SampleRate int
+
+	// FFTSize is the analysis block length in samples, default 256.
+	// It sets the time and frequency resolution.
+	FFTSize int
+
+	ThresholdDb float64       // detection level above the noise floor, default 10 dB
+	EdgeDb      float64       // bandwidth edges below the spectral peak, default 10 dB
+	NoiseTime   time.Duration // noise floor time constant, default 1s
+	Gap         int           // quiet samples that end a burst, default 4 blocks
+	MinLength   int           // shorter bursts are dropped, default 1 block
+
+	// OnBurst, when set, is called as each burst ends.
+	OnBurst func(Burst)
+}
+
+// Burst describes a detected emission. Start and Length are in samples
+// from the first sample written to the detector.
+type Burst struct {
+	Start      int64
+	Length     int64
+	FreqOffset float64 // center frequency offset from the tuned frequency, Hz
+	Bandwidth  float64 // Hz
+	PowerDb    float64 // mean power, dBFS
+	SNRDb      float64
+}
+
+// Annotation returns the burst as a SigMF annotation for a capture
+// tuned to centerFreq.
+func (b Burst) Annotation(centerFreq float64) sigmf.Annotation {
+	f := centerFreq + b.FreqOffset
+	return sigmf.Annotation{
+		SampleStart:   b.Start,
+		SampleCount:   b.Length,
+		FreqLowerEdge: f - b.Bandwidth/2,
+		FreqUpperEdge: f + b.Bandwidth/2,
+		Label:         "burst",
+		Comment:       fmt.Sprintf("%.1f dBFS, SNR %.1f dB", b.PowerDb, b.SNRDb),
+		Generator:     "gortlsdr/burst",
+	}
+}
+
+// Detector finds bursts block by block. It implements io.Writer for
+// cu8 samples, so it can be fed a recording or ReadAsync buffers.
+type Detector struct {
+	cfg Config
+	fft *spectrum.FFT
+	win []float64
+
+	block []complex128
+	fill  int
+	tmp   []complex64
+
+	noise     float64
+	noiseSpec []float64
+	noiseA    float64
+	haveNoise bool
+
+	n         int64 // blocks processed
+	active    bool
+	start     int64
+	lastAbove int64
+	spec      []float64
+	pow       float64
+	count     int
+
+	bursts []Burst
+}
+
+// NewDetector returns a detector for the given configuration.
+func NewDetector(cfg Config) (*Detector, error) {
+	if cfg.SampleRate <= 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+	if cfg.FFTSize == 0 {
+		cfg.FFTSize = 256
+	}
+	if cfg.ThresholdDb == 0 {
+		cfg.ThresholdDb = 10
+	}
+	if cfg.EdgeDb == 0 {
+		cfg.EdgeDb = 10
+	}
+	if cfg.NoiseTime <= 0 {
+		cfg.NoiseTime = time.Second
+	}
+	if cfg.Gap <= 0 {
+		cfg.Gap = 4 * cfg.FFTSize
+	}
+	fft, err := spectrum.NewFFT(cfg.FFTSize)
+	if err != nil {
+		return nil, err
+	}
+	n := cfg.FFTSize
+	d := &Detector{
+		cfg:       cfg,
+		fft:       fft,
+		win:       make([]float64, n),
+		block:     make([]complex128, n),
+		noiseSpec: make([]float64, n),
+		spec:      make([]float64, n),
+	}
+	for i := range d.win {
+		d.win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+	blockTime := float64(n) / float64(cfg.SampleRate)
+	d.noiseA = 1 - math.Exp(-blockTime/cfg.NoiseTime.Seconds())
+	return d, nil
+}
+
+// Write processes interleaved cu8 samples.
+func (d *Detector) Write(buf []byte) (int, error) {
+	d.tmp = iq.FromCU8(d.tmp, buf)
+	d.WriteSamples(d.tmp)
+	return len(buf), nil
+}
+
+// WriteSamples processes complex samples.
+func (d *Detector) WriteSamples(x []complex64) error {
+	for _, v := range x {
+		d.block[d.fill] = complex128(v)
+		d.fill++
+		if d.fill == len(d.block) {
+			d.process()
+			d.fill = 0
+		}
+	}
+	return nil
+}
+
+// Flush ends a burst in progress.
+func (d *Detector) Flush() {
+	if d.active {
+		d.finish()
+	}
+}
+
+// Bursts returns the bursts found so far.
+func (d *Detector) Bursts() []Burst {
+	return d.bursts
+}
+
+// process analyses one complete block.
+func (d *Detector) process() {
+	p := 0.0
+	for i, v := range d.block {
+		p += real(v)*real(v) + imag(v)*imag(v)
+		d.block[i] = v * complex(d.win[i], 0)
+	}
+	p /= float64(len(d.block))
+	d.fft.Transform(d.block, d.block)
+
+	idx := d.n
+	d.n++
+	if !d.haveNoise {
+		d.haveNoise = true
+		d.noise = p
+		for i, v := range d.block {
+			d.noiseSpec[i] = binPower(v)
+		}
+		return
+	}
+	above := p > d.noise*iq.FromDB(d.cfg.ThresholdDb)
+	switch {
+	case above:
+		if !d.active {
+			d.active = true
+			d.start = idx
+			d.pow = 0
+			d.count = 0
+			for i := range d.spec {
+				d.spec[i] = 0
+			}
+		}
+		d.lastAbove = idx
+		d.pow += p
+		d.count++
+		for i, v := range d.block {
+			d.spec[i] += binPower(v)
+		}
+	case d.active:
+		if (idx-d.lastAbove)*int64(len(d.block)) >= int64(d.cfg.Gap) {
+			d.finish()
+		}
+	default:
+		d.noise += d.noiseA * (p - d.noise)
+		for i, v := range d.block {
+			d.noiseSpec[i] += d.noiseA * (binPower(v) - d.noiseSpec[i])
+		}
+	}
+}
+
+func binPower(v complex128) float64 {
+	return real(v)*real(v) + imag(v)*imag(v)
+}
+
+// finish measures and records the burst in progress.
+func (d *Detector) finish() {
+	d.active = false
+	n := len(d.block)
+	length := (d.lastAbove + 1 - d.start) * int64(n)
+	if length < int64(d.cfg.MinLength) {
+		return
+	}
+
+	// excess power over the noise floor, in natural bin order
+	peak, pk := 0.0, 0
+	excess := make([]float64, n)
+	for i := range excess {
+		k := (i + n/2) % n
+		excess[i] = d.spec[k]/float64(d.count) - d.noiseSpec[k]
+		if excess[i] > peak {
+			peak, pk = excess[i], i
+		}
+	}
+	edge := peak * iq.FromDB(-d.cfg.EdgeDb)
+	lo, hi := pk, pk
+	for lo > 0 && excess[lo-1] >= edge {
+		lo--
+	}
+	for hi < n-1 && excess[hi+1] >= edge {
+		hi++
+	}
+	res := float64(d.cfg.SampleRate) / float64(n)
+	fLo := (float64(lo-n/2) - 0.5) * res
+	fHi := (float64(hi-n/2) + 0.5) * res
+
+	pow := d.pow / float64(d.count)
+	b := Burst{
+		Start:      d.start * int64(n),
+		Length:     length,
+		FreqOffset: (fLo + fHi) / 2,
+		Bandwidth:  fHi - fLo,
+		PowerDb:    iq.DB(pow),
+		SNRDb:      iq.DB(pow) - iq.DB(d.noise),
+	}
+	d.bursts = append(d.bursts, b)
+	if d.cfg.OnBurst != nil {
+		d.cfg.OnBurst(b)
+	}
+}
+
+// AnnotateFile scans a SigMF recording and appends an annotation for
+// each burst found to its metadata file. The sample rate is taken from
+// the metadata when cfg.SampleRate is zero.
+func AnnotateFile(path string, cfg Config) ([]Burst, error) {
+	r, err := iq.OpenFile(sigmf.MetaPath(path), iq.Info{})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = int(r.SampleRate)
+	}
+	d, err := NewDetector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = iq.Copy(d, r); err != nil {
+		return nil, err
+	}
+	d.Flush()
+	a := make([]sigmf.Annotation, len(d.bursts))
+	for i, b := range d.bursts {
+		a[i] = b.Annotation(r.CenterFreq)
+	}
+	return d.bursts, sigmf.AddAnnotations(path, a)
+}
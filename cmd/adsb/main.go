@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// adsb receives Mode S and ADS-B on 1090 MHz and serves the messages in
+// SBS-1 BaseStation format on TCP port 30003, as dump1090 does, for
+// Virtual Radar Server and similar clients.
+//
+//	adsb -lat 51.47 -lon -0.46 -v
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	rtl "github.com/jpoirier/gortlsdr"
+	"github.com/jpoirier/gortlsdr/internal/cmdutil"
+	"github.com/jpoirier/gortlsdr/modes"
+)
+
+func main() {
+	index := flag.Int("d", 0, "device index")
+	rate := flag.Int("s", 2000000, "sample rate in Hz, 2000000 or 2400000")
+	gain := flag.Int("g", -1, "tuner gain in tenths of a dB, negative for auto")
+	ppm := flag.Int("p", 0, "frequency correction in ppm")
+	fix := flag.Int("fix", 1, "bit errors to correct, 0 to 2")
+	lat := flag.Float64("lat", 0, "receiver latitude, for single frame and surface positions")
+	lon := flag.Float64("lon", 0, "receiver longitude")
+	addr := flag.String("sbs", ":"+strconv.Itoa(modes.SBSPort), "SBS-1 listen address, empty disables")
+	verbose := flag.Bool("v", false, "log the messages")
+	flag.Parse()
+
+	out := &cmdutil.Feed{Name: "SBS"}
+	if *addr != "" {
+		ln, err := net.Listen("tcp", *addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go out.Serve(ln)
+	}
+	sbs := modes.SBSLines(out)
+	if *fix == 0 {
+		*fix = -1
+	}
+	dec, err := modes.NewDecoder(modes.Config{
+		SampleRate: *rate,
+		FixBits:    *fix,
+		Lat:        *lat,
+		Lon:        *lon,
+		OnMessage: func(m modes.Message) {
+			if *verbose {
+				log.Println(m.String())
+			}
+			sbs(m)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dev, err := cmdutil.Configure(*index, 1090000000, *rate, *gain, *ppm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		<-ch
+		dev.CancelAsync()
+	}()
+
+	var mag []float32
+	cb := func(buf []byte) {
+		t := cmdutil.BufferTime(buf, float64(*rate))
+		mag = modes.MagnitudeCU8(mag, buf)
+		dec.Process(mag, t)
+	}
+	if err := dev.ReadAsync(cb, nil, rtl.DefaultAsyncBufNumber, rtl.DefaultBufLength); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// ais receives both AIS channels, tuned between them at 162.0 MHz, and
+// writes the messages as !AIVDM NMEA sentences to stdout, or sends them
+// by UDP or serves them on TCP for chart plotters such as OpenCPN:
+//
+//	ais -p 52 -udp 127.0.0.1:10110
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	rtl "github.com/jpoirier/gortlsdr"
+	"github.com/jpoirier/gortlsdr/ais"
+	"github.com/jpoirier/gortlsdr/internal/cmdutil"
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+func main() {
+	index := flag.Int("d", 0, "device index")
+	rate := flag.Int("s", 288000, "sample rate in Hz")
+	gain := flag.Int("g", -1, "tuner gain in tenths of a dB, negative for auto")
+	ppm := flag.Int("p", 0, "frequency correction in ppm")
+	port := strconv.Itoa(ais.NMEAPort)
+	udp := flag.String("udp", "", "send the sentences to this UDP address, e.g. 127.0.0.1:"+port)
+	tcp := flag.String("tcp", "", "serve the sentences on this TCP address, e.g. :"+port)
+	verbose := flag.Bool("v", false, "log the decoded messages")
+	flag.Parse()
+
+	out := &cmdutil.Feed{Name: "NMEA"}
+	if *udp != "" {
+		c, err := net.Dial("udp", *udp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out.Add(*udp, c)
+	}
+	if *tcp != "" {
+		ln, err := net.Listen("tcp", *tcp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go out.Serve(ln)
+	}
+	if *udp == "" && *tcp == "" {
+		out.Add("stdout", os.Stdout)
+	}
+	nmea := ais.NMEALines(out)
+	dec, err := ais.NewDecoder(ais.Config{
+		SampleRate: float64(*rate),
+		OnMessage: func(m ais.Message) {
+			if *verbose {
+				log.Println(m.String())
+			}
+			nmea(m)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dev, err := cmdutil.Configure(*index, ais.CenterFreq, *rate, *gain, *ppm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		<-ch
+		dev.CancelAsync()
+	}()
+
+	var x []complex64
+	cb := func(buf []byte) {
+		t := cmdutil.BufferTime(buf, float64(*rate))
+		x = iq.FromCU8(x, buf)
+		dec.Process(x, t)
+	}
+	if err := dev.ReadAsync(cb, nil, rtl.DefaultAsyncBufNumber, rtl.DefaultBufLength); err != nil {
+		log.Println(err)
+	}
+}
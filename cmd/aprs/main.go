@@ -0,0 +1,122 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// aprs receives APRS on 144.39 MHz, or another packet channel, and
+// writes the frames to stdout in the TNC2 monitor format. It also serves
+// them on TCP port 8001 as a KISS TNC, for APRS clients such as Xastir,
+// YAAC or APRSIS32:
+//
+//	aprs -f 144.8e6 -v
+//
+// With -a it instead decodes 16-bit discriminator audio from stdin, such
+// as rtl_fm's:
+//
+//	rtl_fm -f 144.39e6 -s 22050 | aprs -a 22050
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	rtl "github.com/jpoirier/gortlsdr"
+	"github.com/jpoirier/gortlsdr/aprs"
+	"github.com/jpoirier/gortlsdr/ax25"
+	"github.com/jpoirier/gortlsdr/demod"
+	"github.com/jpoirier/gortlsdr/internal/cmdutil"
+	"github.com/jpoirier/gortlsdr/tune"
+)
+
+const audioRate = 24000
+
+func main() {
+	index := flag.Int("d", 0, "device index")
+	freq := flag.Float64("f", 144.39e6, "channel frequency in Hz")
+	rate := flag.Int("s", 240000, "device sample rate in Hz")
+	gain := flag.Int("g", -1, "tuner gain in tenths of a dB, negative for auto")
+	ppm := flag.Int("p", 0, "frequency correction in ppm")
+	stdinRate := flag.Int("a", 0, "decode s16le audio at this rate from stdin instead")
+	addr := flag.String("kiss", ":"+strconv.Itoa(ax25.KISSPort), "KISS TNC listen address, empty disables")
+	verbose := flag.Bool("v", false, "log the parsed position and status reports")
+	flag.Parse()
+
+	// only receiving, the frames clients send to be transmitted are
+	// discarded
+	clients := &cmdutil.Feed{Name: "KISS"}
+	if *addr != "" {
+		ln, err := net.Listen("tcp", *addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go clients.Serve(ln)
+	}
+	lines := ax25.Lines(os.Stdout)
+	kiss := ax25.KISSFrames(clients)
+	onFrame := func(f ax25.Frame) {
+		lines(f)
+		kiss(f)
+		if !*verbose {
+			return
+		}
+		p, err := aprs.Parse(f)
+		if err != nil {
+			log.Printf("%s: %v\n", f.Source, err)
+		} else if p.HasPosition || p.Status != "" {
+			log.Println(p.String())
+		}
+	}
+
+	if *stdinRate > 0 {
+		dec, err := ax25.NewDecoder(ax25.Config{SampleRate: float64(*stdinRate), OnFrame: onFrame})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := cmdutil.Audio(os.Stdin, dec.Process); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	dec, err := ax25.NewDecoder(ax25.Config{SampleRate: audioRate, OnFrame: onFrame})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fm, err := demod.NewNBFM(demod.NBFMConfig{SampleRate: float64(*rate), AudioRate: audioRate})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dev, err := cmdutil.Configure(*index, 0, *rate, *gain, *ppm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+	// offset tuned, keeping the channel clear of the DC spike
+	tuner, err := tune.New(dev, tune.Config{})
+	if err == nil {
+		err = tuner.SetCenterFreq(int(*freq))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		<-ch
+		dev.CancelAsync()
+	}()
+
+	var af []float32
+	cb := tuner.Callback(func(x []complex64) {
+		af = fm.Process(af, x)
+		dec.Process(af)
+	})
+	if err := dev.ReadAsync(cb, nil, rtl.DefaultAsyncBufNumber, rtl.DefaultBufLength); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// iqconvert converts I/Q recordings between the cu8, cs8, cs16 and cf32
+// raw formats, WAV and SigMF.
+//
+//	iqconvert [flags] input output
+//
+// Containers are chosen by file extension: .cu8, .cs8, .cs16 and .cf32
+// for raw files, .wav, and .sigmf-meta, .sigmf-data or .sigmf for SigMF.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jpoirier/gortlsdr/convert"
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+func main() {
+	inFmt := flag.String("if", "", "input sample format for raw files without a format extension")
+	outFmt := flag.String("of", "", "output sample format (cu8, cs8, cs16, cf32)")
+	rate := flag.Float64("rate", 0, "input sample rate in Hz, when the input doesn't carry it")
+	freq := flag.Float64("freq", 0, "input center frequency in Hz, when the input doesn't carry it")
+	shift := flag.Float64("shift", 0, "move the signal at +shift Hz down to 0 Hz")
+	decim := flag.Int("decim", 1, "integer decimation factor")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] input output\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	hint := iq.Info{SampleRate: *rate, CenterFreq: *freq}
+	var err error
+	if *inFmt != "" {
+		if hint.Format, err = iq.ParseFormat(*inFmt); err != nil {
+			log.Fatal(err)
+		}
+	}
+	of := iq.FormatUnknown
+	if *outFmt != "" {
+		if of, err = iq.ParseFormat(*outFmt); err != nil {
+			log.Fatal(err)
+		}
+	}
+	opt := convert.Options{Shift: *shift, Decimation: *decim}
+	n, err := convert.File(flag.Arg(1), of, flag.Arg(0), hint, opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("converted %d samples\n", n)
+}
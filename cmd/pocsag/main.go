@@ -0,0 +1,102 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// pocsag receives a POCSAG paging channel and writes the pages as JSON
+// lines:
+//
+//	pocsag -f 153.35e6 -o pages.json
+//
+// With -a it instead decodes 16-bit discriminator audio from stdin, such
+// as rtl_fm's:
+//
+//	rtl_fm -f 153.35e6 -s 22050 | pocsag -a 22050
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	rtl "github.com/jpoirier/gortlsdr"
+	"github.com/jpoirier/gortlsdr/internal/cmdutil"
+	"github.com/jpoirier/gortlsdr/pocsag"
+	"github.com/jpoirier/gortlsdr/tune"
+)
+
+func main() {
+	index := flag.Int("d", 0, "device index")
+	freq := flag.Float64("f", 0, "channel frequency in Hz")
+	rate := flag.Int("s", 240000, "device sample rate in Hz")
+	gain := flag.Int("g", -1, "tuner gain in tenths of a dB, negative for auto")
+	ppm := flag.Int("p", 0, "frequency correction in ppm")
+	baud := flag.Int("b", 0, "baud rate, 512, 1200 or 2400, 0 for all")
+	audioRate := flag.Int("a", 0, "decode s16le audio at this rate from stdin instead")
+	out := flag.String("o", "-", "output file, - for stdout")
+	flag.Parse()
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	cfg := pocsag.Config{
+		SampleRate: float64(*rate),
+		IQ:         true,
+		Baud:       *baud,
+		OnMessage: pocsag.JSONLines(w, func(err error) {
+			log.Println(err)
+		}),
+	}
+	if *audioRate > 0 {
+		cfg.SampleRate, cfg.IQ = float64(*audioRate), false
+		dec, err := pocsag.NewDecoder(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := cmdutil.Audio(os.Stdin, dec.Process); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *freq <= 0 {
+		log.Fatal("a channel frequency is required")
+	}
+	dec, err := pocsag.NewDecoder(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dev, err := cmdutil.Configure(*index, 0, *rate, *gain, *ppm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+	// offset tuned, keeping the channel clear of the DC spike
+	tuner, err := tune.New(dev, tune.Config{})
+	if err == nil {
+		err = tuner.SetCenterFreq(int(*freq))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		<-ch
+		dev.CancelAsync()
+	}()
+
+	cb := tuner.Callback(dec.ProcessIQ)
+	if err := dev.ReadAsync(cb, nil, rtl.DefaultAsyncBufNumber, rtl.DefaultBufLength); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// uat receives UAT on 978 MHz and writes the ADS-B and uplink frames to
+// stdout in dump978's format, for its tools to decode:
+//
+//	uat | uat2text
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	rtl "github.com/jpoirier/gortlsdr"
+	"github.com/jpoirier/gortlsdr/internal/cmdutil"
+	"github.com/jpoirier/gortlsdr/uat"
+)
+
+func main() {
+	index := flag.Int("d", 0, "device index")
+	gain := flag.Int("g", -1, "tuner gain in tenths of a dB, negative for auto")
+	ppm := flag.Int("p", 0, "frequency correction in ppm")
+	flag.Parse()
+
+	dec := uat.NewDecoder(uat.Config{OnFrame: uat.Lines(os.Stdout)})
+
+	dev, err := cmdutil.Configure(*index, 978000000, uat.SampleRate, *gain, *ppm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		<-ch
+		dev.CancelAsync()
+	}()
+
+	var phase []float32
+	cb := func(buf []byte) {
+		t := cmdutil.BufferTime(buf, uat.SampleRate)
+		phase = uat.PhaseCU8(phase, buf)
+		dec.Process(phase, t)
+	}
+	if err := dev.ReadAsync(cb, nil, rtl.DefaultAsyncBufNumber, rtl.DefaultBufLength); err != nil {
+		log.Println(err)
+	}
+}
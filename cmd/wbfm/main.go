@@ -0,0 +1,140 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// wbfm tunes a broadcast FM station and writes the demodulated audio as
+// 16-bit PCM, to a WAV file or raw to stdout.
+//
+//	wbfm -f 94.9e6 -o station.wav
+//	wbfm -f 94.9e6 -stereo -o - | aplay -r 48000 -c 2 -f S16_LE
+//
+// In stereo mode changes of the stereo indicator are logged. With -rds the
+// decoded RDS groups are written as JSON lines:
+//
+//	wbfm -f 94.9e6 -rds - -o station.wav
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	rtl "github.com/jpoirier/gortlsdr"
+	"github.com/jpoirier/gortlsdr/demod"
+	"github.com/jpoirier/gortlsdr/internal/cmdutil"
+	"github.com/jpoirier/gortlsdr/rds"
+	"github.com/jpoirier/gortlsdr/tune"
+	"github.com/jpoirier/gortlsdr/wav"
+)
+
+func main() {
+	index := flag.Int("d", 0, "device index")
+	freq := flag.Float64("f", 0, "station frequency in Hz")
+	rate := flag.Int("s", 1200000, "device sample rate in Hz")
+	gain := flag.Int("g", -1, "tuner gain in tenths of a dB, negative for auto")
+	ppm := flag.Int("p", 0, "frequency correction in ppm")
+	deemph := flag.Int("de", 50, "de-emphasis time constant in us, 50 or 75, 0 disables")
+	audioRate := flag.Int("r", 48000, "audio sample rate in Hz")
+	out := flag.String("o", "-", "output WAV file, - for raw PCM on stdout")
+	stereo := flag.Bool("stereo", false, "decode stereo, the output is interleaved left and right")
+	rdsOut := flag.String("rds", "", "write decoded RDS as JSON lines to this file, - for stderr")
+	flag.Parse()
+	if *freq <= 0 {
+		log.Fatal("a station frequency is required")
+	}
+
+	cfg := demod.WBFMConfig{
+		SampleRate: float64(*rate),
+		Deemphasis: time.Duration(*deemph) * time.Microsecond,
+		AudioRate:  *audioRate,
+		Stereo:     *stereo,
+	}
+	if *deemph == 0 {
+		cfg.Deemphasis = -1
+	}
+	fm, err := demod.NewWBFM(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rd *rds.Decoder
+	if *rdsOut != "" {
+		var rw io.Writer = os.Stderr
+		if *rdsOut != "-" {
+			f, err := os.Create(*rdsOut)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			rw = f
+		}
+		rd, err = rds.NewDecoder(rds.Config{MPXRate: fm.MPXRate(), OnEvent: rds.JSONLines(rw, func(err error) {
+			log.Printf("rds: %v\n", err)
+		})})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		ww, err := wav.NewWriter(f, wav.Format{Channels: fm.Channels(), SampleRate: *audioRate, BitsPerSample: 16})
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer ww.Close()
+		w = ww
+	}
+
+	dev, err := cmdutil.Configure(*index, 0, *rate, *gain, *ppm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dev.Close()
+	// offset tuned, keeping the station clear of the DC spike
+	tuner, err := tune.New(dev, tune.Config{})
+	if err == nil {
+		err = tuner.SetCenterFreq(int(*freq))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+		<-ch
+		dev.CancelAsync()
+	}()
+
+	var audio []float32
+	var pcm []byte
+	var indicator bool
+	cb := tuner.Callback(func(x []complex64) {
+		audio = fm.Process(audio, x)
+		if rd != nil {
+			rd.Process(fm.MPX())
+		}
+		if st := fm.Stereo(); st != nil && st.IsStereo() != indicator {
+			indicator = st.IsStereo()
+			log.Printf("stereo %v, pilot SNR %.1f dB\n", indicator, st.PilotSNRDb())
+		}
+		pcm = wav.PCM16(pcm[:0], audio)
+		if _, err := w.Write(pcm); err != nil {
+			log.Println(err)
+			dev.CancelAsync()
+		}
+	})
+	if err := dev.ReadAsync(cb, nil, rtl.DefaultAsyncBufNumber, rtl.DefaultBufLength); err != nil {
+		log.Println(err)
+	}
+}
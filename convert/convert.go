@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package convert streams recordings between the sample formats and
+// containers supported by package iq, optionally shifting and
+// decimating the signal on the way.
+package convert
+
+import (
+	"errors"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// Options holds the processing applied during conversion.
+type Options struct {
+	// Shift moves the signal at +Shift Hz from the center frequency
+	// down to 0 Hz. The output center frequency is adjusted to match.
+	Shift float64
+
+	// Decimation is the integer decimation factor, 0 or 1 disables
+	// decimation. A 60 dB lowpass filter keeping the central 80% of
+	// the output band is applied first.
+	Decimation int
+}
+
+// Converter applies Options to samples and writes them to a sink.
+type Converter struct {
+	dst iq.SampleWriter
+
+	nco *dsp.NCO
+	dec *dsp.FIRDecimator
+	out []complex64
+}
+
+// New returns a converter writing to dst. sampleRate is the input rate
+// and is only needed when shifting.
+func New(dst iq.SampleWriter, sampleRate float64, opt Options) (*Converter, error) {
+	if opt.Decimation < 0 {
+		return nil, errors.New("invalid decimation factor")
+	}
+	c := &Converter{dst: dst}
+	if opt.Shift != 0 {
+		if sampleRate <= 0 {
+			return nil, errors.New("shifting requires the sample rate")
+		}
+		c.nco = dsp.NewNCO(sampleRate, -opt.Shift)
+	}
+	if m := opt.Decimation; m > 1 {
+		taps := dsp.LowpassKaiser(0.4/float64(m), 0.5/float64(m), 60)
+		c.dec = dsp.NewFIRDecimator(taps, m)
+	}
+	return c, nil
+}
+
+// OutputInfo returns the metadata describing the converted stream.
+func OutputInfo(in iq.Info, opt Options) iq.Info {
+	out := in
+	out.CenterFreq += opt.Shift
+	if opt.Decimation > 1 {
+		out.SampleRate /= float64(opt.Decimation)
+	}
+	return out
+}
+
+// WriteSamples processes x and writes the result. x is modified when
+// shifting.
+func (c *Converter) WriteSamples(x []complex64) error {
+	if c.nco != nil {
+		x = c.nco.Mix(x, x)
+	}
+	if c.dec == nil {
+		return c.dst.WriteSamples(x)
+	}
+	c.out = c.dec.Process(c.out, x)
+	if len(c.out) == 0 {
+		return nil
+	}
+	return c.dst.WriteSamples(c.out)
+}
+
+// File converts the recording at src to dst. srcHint supplies metadata
+// the source file can't carry; dstFormat selects the output sample
+// format, iq.FormatUnknown picks the container default. It returns the
+// number of input samples converted.
+func File(dst string, dstFormat iq.Format, src string, srcHint iq.Info, opt Options) (int64, error) {
+	r, err := iq.OpenFile(src, srcHint)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	info := OutputInfo(r.Info, opt)
+	info.Format = dstFormat
+	w, err := iq.CreateFile(dst, info)
+	if err != nil {
+		return 0, err
+	}
+	c, err := New(w, r.SampleRate, opt)
+	if err != nil {
+		w.Close()
+		return 0, err
+	}
+	n, err := iq.Copy(c, r)
+	if err != nil {
+		w.Close()
+		return n, err
+	}
+	return n, w.Close()
+}
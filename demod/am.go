@@ -0,0 +1,178 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package demod
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+)
+
+// AGC settings shared by the AM and SSB demodulators. The AGC works on
+// the channel filtered signal, so noise and signals outside the channel
+// don't pump the gain.
+const (
+	agcTargetDb  = -6
+	agcMaxGainDb = 80
+	agcAttack    = 5 * time.Millisecond
+	agcDecay     = 500 * time.Millisecond
+)
+
+// newAGC returns an AGC with the given decay, default agcDecay, or nil
+// when it's negative.
+func newAGC(fs float64, decay time.Duration) *dsp.AGC {
+	if decay < 0 {
+		return nil
+	}
+	if decay == 0 {
+		decay = agcDecay
+	}
+	return dsp.NewAGC(fs, agcTargetDb, agcMaxGainDb, agcAttack, decay)
+}
+
+// AMMode selects the AM detector.
+type AMMode int
+
+// AM detectors.
+const (
+	// Envelope detects the magnitude, like a diode detector.
+	Envelope AMMode = iota
+
+	// Synchronous locks a PLL to the carrier and takes the in phase
+	// component, which holds up better under selective fading and
+	// doesn't distort on overmodulation.
+	Synchronous
+)
+
+// AM parameters.
+const (
+	amBandwidth = 5e3 // default audio bandwidth
+	amCarrierTC = 100 * time.Millisecond
+)
+
+// AMConfig holds the AM demodulator settings.
+type AMConfig struct {
+	SampleRate float64 // input rate
+
+	Mode AMMode
+
+	// Bandwidth is the audio bandwidth, half the channel filter
+	// bandwidth, default 5 kHz.
+	Bandwidth float64
+
+	AudioRate int // default 16000
+
+	// AGCDecay is the AGC decay time constant, default 500ms,
+	// negative disables the AGC.
+	AGCDecay time.Duration
+}
+
+// AM demodulates amplitude modulation, e.g. airband or HF broadcast.
+// The carrier is expected at 0 Hz. The input is decimated and channel
+// filtered, levelled by the AGC, detected, and the carrier removed
+// before resampling to the audio rate.
+type AM struct {
+	mode AMMode
+	dec  *dsp.Decimator
+	ch   *dsp.FIRFilter
+	agc  *dsp.AGC
+	rs   dsp.Resampler
+	lp   *dsp.FIRFilter
+
+	// carrier removal
+	dc, da float64
+
+	// carrier PLL for synchronous detection
+	theta, omega float64
+	kp, ki       float64
+
+	bb, tmp []complex64
+	det     []float32
+}
+
+// NewAM returns a demodulator for the given configuration.
+func NewAM(cfg AMConfig) (*AM, error) {
+	if cfg.Bandwidth == 0 {
+		cfg.Bandwidth = amBandwidth
+	}
+	if cfg.AudioRate == 0 {
+		cfg.AudioRate = 16000
+	}
+	ar := float64(cfg.AudioRate)
+	if ar/2 <= cfg.Bandwidth {
+		return nil, errors.New("audio rate too low for the bandwidth")
+	}
+	dec, err := dsp.NewDecimator(dsp.DecimatorConfig{
+		InputRate:  cfg.SampleRate,
+		OutputRate: 4 * cfg.Bandwidth,
+		Passband:   cfg.Bandwidth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fs := dec.OutputRate()
+	rs, err := dsp.NewResampler(fs, ar)
+	if err != nil {
+		return nil, err
+	}
+	bw := cfg.Bandwidth
+
+	// second order loop, 50 Hz noise bandwidth, critically damped
+	const bn, zeta = 50.0, 0.707
+	wn := 2 * bn / (zeta + 1/(4*zeta)) / fs
+	return &AM{
+		mode: cfg.Mode,
+		dec:  dec,
+		ch:   dsp.NewFIRFilter(dsp.LowpassKaiser(bw/fs, 1.25*bw/fs, 60)),
+		agc:  newAGC(fs, cfg.AGCDecay),
+		rs:   rs,
+		lp:   dsp.NewFIRFilter(dsp.LowpassKaiser(bw/ar, math.Min(1.25*bw, ar/2)/ar, 50)),
+		da:   alpha(fs, amCarrierTC),
+		kp:   2 * zeta * wn,
+		ki:   wn * wn,
+	}, nil
+}
+
+// CarrierOffset returns the carrier frequency tracked by the synchronous
+// detector in Hz, relative to 0 Hz.
+func (a *AM) CarrierOffset() float64 {
+	return a.omega * a.dec.OutputRate() / (2 * math.Pi)
+}
+
+// Process demodulates src into audio in dst.
+func (a *AM) Process(dst []float32, src []complex64) []float32 {
+	a.tmp = a.dec.Process(a.tmp, src)
+	a.bb = a.ch.Process(a.bb, a.tmp)
+	if a.agc != nil {
+		a.bb = a.agc.Process(a.bb, a.bb)
+	}
+	a.det = grow(a.det, len(a.bb))
+	for i, v := range a.bb {
+		re, im := float64(real(v)), float64(imag(v))
+		var y float64
+		if a.mode == Synchronous {
+			s, c := math.Sincos(a.theta)
+			// rotate by -theta, the carrier then lies on the real axis
+			in, qu := re*c+im*s, im*c-re*s
+			e := math.Atan2(qu, math.Abs(in))
+			a.omega += a.ki * e
+			a.theta += a.omega + a.kp*e
+			if a.theta > math.Pi {
+				a.theta -= 2 * math.Pi
+			} else if a.theta < -math.Pi {
+				a.theta += 2 * math.Pi
+			}
+			y = in
+		} else {
+			y = math.Hypot(re, im)
+		}
+		a.dc += a.da * (y - a.dc)
+		a.det[i] = float32(y - a.dc)
+	}
+	dst = a.rs.ProcessReal(dst, a.det)
+	return a.lp.ProcessReal(dst, dst)
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package demod turns complex baseband samples into audio. Like the dsp
+// blocks, demodulators keep their state between calls and take an
+// output slice that's grown as needed and returned.
+package demod
+
+// grow returns dst resized to n elements, reallocating when needed.
+func grow(dst []float32, n int) []float32 {
+	if cap(dst) < n {
+		return make([]float32, n)
+	}
+	return dst[:n]
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package demod
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+)
+
+// Quadrature is an FM discriminator, the phase difference between
+// successive samples scaled so the given deviation reads 1.
+type Quadrature struct {
+	gain float64
+	prev complex64
+}
+
+// NewQuadrature returns a discriminator for the given sample rate and
+// peak deviation, both in Hz.
+func NewQuadrature(sampleRate, deviation float64) *Quadrature {
+	return &Quadrature{gain: sampleRate / (2 * math.Pi * deviation)}
+}
+
+// Process demodulates src into dst.
+func (q *Quadrature) Process(dst []float32, src []complex64) []float32 {
+	dst = grow(dst, len(src))
+	p := q.prev
+	for i, v := range src {
+		// v * conj(p)
+		re := float64(real(v)*real(p) + imag(v)*imag(p))
+		im := float64(imag(v)*real(p) - real(v)*imag(p))
+		dst[i] = float32(q.gain * math.Atan2(im, re))
+		p = v
+	}
+	q.prev = p
+	return dst
+}
+
+// Deemphasis is the single pole lowpass undoing broadcast FM
+// pre-emphasis.
+type Deemphasis struct {
+	a float32
+	y float32
+}
+
+// Standard de-emphasis time constants.
+const (
+	Deemphasis50us = 50 * time.Microsecond // Europe and most of the world
+	Deemphasis75us = 75 * time.Microsecond // the Americas and South Korea
+)
+
+// NewDeemphasis returns a de-emphasis filter with time constant tau.
+func NewDeemphasis(sampleRate float64, tau time.Duration) *Deemphasis {
+	return &Deemphasis{a: float32(math.Exp(-1 / (sampleRate * tau.Seconds())))}
+}
+
+// Process filters src into dst, which may be src.
+func (d *Deemphasis) Process(dst, src []float32) []float32 {
+	dst = grow(dst, len(src))
+	y := d.y
+	for i, v := range src {
+		y = v + d.a*(y-v)
+		dst[i] = y
+	}
+	d.y = y
+	return dst
+}
+
+// Broadcast FM parameters.
+const (
+	wbfmDeviation = 75e3
+	wbfmBandwidth = 100e3 // one sided, keeps the RDS subcarrier
+	wbfmAudio     = 15e3
+	wbfmPilot     = 19e3
+	mpxMinRate    = 220e3
+)
+
+// WBFMConfig holds the broadcast FM demodulator settings.
+type WBFMConfig struct {
+	SampleRate float64 // input rate, at least 220 kHz
+
+	// Deemphasis is the de-emphasis time constant, default 50us,
+	// negative disables it.
+	Deemphasis time.Duration
+
+	AudioRate int // default 48000
+
+	// Stereo decodes the stereo multiplex, the output is then
+	// interleaved left and right.
+	Stereo bool
+}
+
+// audioChain takes the multiplex down to the audio rate, removes
+// everything above 15 kHz, including the stereo pilot, and applies
+// de-emphasis.
+type audioChain struct {
+	rs  dsp.Resampler
+	lp  *dsp.FIRFilter
+	de  *Deemphasis
+	tmp []float32
+}
+
+func newAudioChain(mpxRate float64, audioRate int, deemph time.Duration) (*audioChain, error) {
+	ar := float64(audioRate)
+	if ar/2 <= wbfmPilot {
+		return nil, errors.New("audio rate too low")
+	}
+	rs, err := dsp.NewResampler(mpxRate, ar)
+	if err != nil {
+		return nil, err
+	}
+	a := &audioChain{
+		rs: rs,
+		lp: dsp.NewFIRFilter(dsp.LowpassKaiser(wbfmAudio/ar, (wbfmPilot-500)/ar, 60)),
+	}
+	if deemph > 0 {
+		a.de = NewDeemphasis(ar, deemph)
+	}
+	return a, nil
+}
+
+func (a *audioChain) process(dst, src []float32) []float32 {
+	a.tmp = a.rs.ProcessReal(a.tmp, src)
+	dst = a.lp.ProcessReal(dst, a.tmp)
+	if a.de != nil {
+		dst = a.de.Process(dst, dst)
+	}
+	return dst
+}
+
+// WBFM demodulates wideband broadcast FM to audio. The station is
+// expected at 0 Hz. The input is decimated to the multiplex rate, at
+// least 220 kHz, demodulated, resampled to the audio rate and low pass
+// filtered to 15 kHz, then de-emphasised.
+type WBFM struct {
+	dec    *dsp.Decimator
+	quad   *Quadrature
+	mono   *audioChain
+	stereo *Stereo
+
+	bb  []complex64
+	mpx []float32
+}
+
+// NewWBFM returns a demodulator for the given configuration.
+func NewWBFM(cfg WBFMConfig) (*WBFM, error) {
+	if cfg.SampleRate < mpxMinRate {
+		return nil, errors.New("sample rate too low for broadcast FM")
+	}
+	if cfg.Deemphasis == 0 {
+		cfg.Deemphasis = Deemphasis50us
+	}
+	if cfg.AudioRate == 0 {
+		cfg.AudioRate = 48000
+	}
+	dec, err := dsp.NewDecimator(dsp.DecimatorConfig{
+		InputRate:  cfg.SampleRate,
+		OutputRate: mpxMinRate,
+		Passband:   wbfmBandwidth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	w := &WBFM{
+		dec:  dec,
+		quad: NewQuadrature(dec.OutputRate(), wbfmDeviation),
+	}
+	if cfg.Stereo {
+		w.stereo, err = NewStereo(dec.OutputRate(), cfg.AudioRate, cfg.Deemphasis)
+	} else {
+		w.mono, err = newAudioChain(dec.OutputRate(), cfg.AudioRate, cfg.Deemphasis)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Channels returns 2 when decoding stereo, otherwise 1.
+func (w *WBFM) Channels() int {
+	if w.stereo != nil {
+		return 2
+	}
+	return 1
+}
+
+// Stereo returns the stereo decoder, nil for a mono demodulator.
+func (w *WBFM) Stereo() *Stereo {
+	return w.stereo
+}
+
+// MPXRate returns the sample rate of the multiplex signal.
+func (w *WBFM) MPXRate() float64 {
+	return w.dec.OutputRate()
+}
+
+// MPX returns the multiplex signal demodulated by the last Process call,
+// for stereo or RDS decoding. It's overwritten by the next call.
+func (w *WBFM) MPX() []float32 {
+	return w.mpx
+}
+
+// Process demodulates src into audio in dst, interleaved left and right
+// when decoding stereo.
+func (w *WBFM) Process(dst []float32, src []complex64) []float32 {
+	w.bb = w.dec.Process(w.bb, src)
+	w.mpx = w.quad.Process(w.mpx, w.bb)
+	if w.stereo != nil {
+		return w.stereo.Process(dst, w.mpx)
+	}
+	return w.mono.process(dst, w.mpx)
+}
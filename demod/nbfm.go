@@ -0,0 +1,179 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package demod
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+)
+
+// Narrowband FM parameters.
+const (
+	Deviation25k  = 5e3   // peak deviation on 25 kHz channels
+	Deviation12k5 = 2.5e3 // peak deviation on 12.5 kHz channels
+
+	// Deemphasis750us is the time constant usually used to model the
+	// 6 dB per octave pre-emphasis of land mobile radio.
+	Deemphasis750us = 750 * time.Microsecond
+
+	nbfmVoiceLow  = 300 // Hz
+	nbfmVoiceHigh = 3e3
+	nbfmMinRate   = 32e3
+)
+
+// NBFMConfig holds the narrowband FM demodulator settings.
+type NBFMConfig struct {
+	SampleRate float64 // input rate
+
+	Deviation float64 // peak deviation, default Deviation25k
+
+	// Deemphasis is the de-emphasis time constant, default 750us,
+	// negative disables it.
+	Deemphasis time.Duration
+
+	AudioRate int // default 8000
+
+	// Tone and DCS gate the audio, it's muted unless the CTCSS tone in
+	// Hz, one of CTCSSTones, or the DCS code is detected. Zero values
+	// disable them.
+	Tone float64
+	DCS  DCSCode
+}
+
+// NBFM demodulates narrowband FM voice. The channel is expected at 0 Hz.
+// The input is decimated and channel filtered, demodulated, resampled to
+// the audio rate, then the voice band is filtered out of it and
+// de-emphasised. The sub-audible band is passed to a CTCSS and DCS
+// detector, whose results tag the transmission and can gate the audio.
+type NBFM struct {
+	cfg  NBFMConfig
+	dec  *dsp.Decimator
+	ch   *dsp.FIRFilter
+	quad *Quadrature
+	rs   dsp.Resampler
+	hp   dsp.Cascade
+	lp   *dsp.FIRFilter
+	de   *Deemphasis
+	sub  *SubAudible
+
+	bb, tmp []complex64
+	fm      []float32
+}
+
+// NewNBFM returns a demodulator for the given configuration.
+func NewNBFM(cfg NBFMConfig) (*NBFM, error) {
+	if cfg.Deviation == 0 {
+		cfg.Deviation = Deviation25k
+	}
+	if cfg.Deemphasis == 0 {
+		cfg.Deemphasis = Deemphasis750us
+	}
+	if cfg.AudioRate == 0 {
+		cfg.AudioRate = 8000
+	}
+	ar := float64(cfg.AudioRate)
+	if ar/2 <= nbfmVoiceHigh {
+		return nil, errors.New("audio rate too low")
+	}
+	if cfg.Tone != 0 && !validTone(cfg.Tone) {
+		return nil, errors.New("not a standard CTCSS tone")
+	}
+	if cfg.DCS.Code != 0 && !validDCS(cfg.DCS.Code) {
+		return nil, errors.New("not a standard DCS code")
+	}
+
+	// Carson's rule bandwidth, one sided
+	bw := cfg.Deviation + nbfmVoiceHigh
+	dec, err := dsp.NewDecimator(dsp.DecimatorConfig{
+		InputRate:  cfg.SampleRate,
+		OutputRate: nbfmMinRate,
+		Passband:   bw,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fs := dec.OutputRate()
+	rs, err := dsp.NewResampler(fs, ar)
+	if err != nil {
+		return nil, err
+	}
+	n := &NBFM{
+		cfg:  cfg,
+		dec:  dec,
+		ch:   dsp.NewFIRFilter(dsp.LowpassKaiser(bw/fs, (bw+2e3)/fs, 50)),
+		quad: NewQuadrature(fs, cfg.Deviation),
+		rs:   rs,
+		hp:   dsp.ButterworthHighpass(6, nbfmVoiceLow/ar),
+		lp:   dsp.NewFIRFilter(dsp.LowpassKaiser(nbfmVoiceHigh/ar, (nbfmVoiceHigh+500)/ar, 50)),
+		sub:  NewSubAudible(ar),
+	}
+	if cfg.Deemphasis > 0 {
+		n.de = NewDeemphasis(ar, cfg.Deemphasis)
+	}
+	return n, nil
+}
+
+func validTone(t float64) bool {
+	for _, v := range CTCSSTones {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+func validDCS(c int) bool {
+	for _, v := range DCSCodes {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Tone returns the CTCSS tone detected, 0 for none.
+func (n *NBFM) Tone() float64 {
+	return n.sub.Tone()
+}
+
+// DCS returns the DCS code detected, if any.
+func (n *NBFM) DCS() (DCSCode, bool) {
+	return n.sub.DCS()
+}
+
+// Open reports whether audio is passing the tone and code gates.
+func (n *NBFM) Open() bool {
+	if n.cfg.Tone != 0 && n.sub.Tone() != n.cfg.Tone {
+		return false
+	}
+	if n.cfg.DCS.Code != 0 {
+		c, ok := n.sub.DCS()
+		return ok && sameDCS(c, n.cfg.DCS)
+	}
+	return true
+}
+
+// Process demodulates src into audio in dst.
+func (n *NBFM) Process(dst []float32, src []complex64) []float32 {
+	n.tmp = n.dec.Process(n.tmp, src)
+	n.bb = n.ch.Process(n.bb, n.tmp)
+	n.fm = n.quad.Process(n.fm, n.bb)
+	dst = n.rs.ProcessReal(dst, n.fm)
+	n.sub.Process(dst)
+
+	dst = n.hp.ProcessReal(dst, dst)
+	dst = n.lp.ProcessReal(dst, dst)
+	if n.de != nil {
+		dst = n.de.Process(dst, dst)
+	}
+	if !n.Open() {
+		for i := range dst {
+			dst[i] = 0
+		}
+	}
+	return dst
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package demod
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+)
+
+// Sideband selects the SSB sideband.
+type Sideband int
+
+// Sidebands.
+const (
+	USB Sideband = iota
+	LSB
+)
+
+// SSB parameters, the default passband suits voice.
+const (
+	ssbLow  = 300 // Hz
+	ssbHigh = 2700
+)
+
+// SSBConfig holds the SSB demodulator settings.
+type SSBConfig struct {
+	SampleRate float64 // input rate
+
+	Sideband Sideband
+
+	// Low and High are the audio passband edges, default 300 and 2700
+	// Hz. Narrow them for CW or data, e.g. 400 and 900 Hz.
+	Low, High float64
+
+	AudioRate int // default 8000
+
+	// AGCDecay is the AGC decay time constant, default 500ms,
+	// negative disables the AGC.
+	AGCDecay time.Duration
+}
+
+// SSB demodulates single sideband using the Weaver method. The
+// suppressed carrier is expected at 0 Hz. The input is decimated and
+// resampled to the audio rate, the middle of the passband is shifted
+// to 0 Hz where a low pass filter half the passband wide selects the
+// sideband, then it's shifted back, levelled by the AGC, and the real
+// part taken as the audio.
+type SSB struct {
+	dec      *dsp.Decimator
+	rs       dsp.Resampler
+	down, up *dsp.NCO
+	lp       *dsp.FIRFilter
+	agc      *dsp.AGC
+
+	bb, tmp []complex64
+}
+
+// NewSSB returns a demodulator for the given configuration.
+func NewSSB(cfg SSBConfig) (*SSB, error) {
+	if cfg.Low == 0 && cfg.High == 0 {
+		cfg.Low, cfg.High = ssbLow, ssbHigh
+	}
+	if cfg.AudioRate == 0 {
+		cfg.AudioRate = 8000
+	}
+	ar := float64(cfg.AudioRate)
+	if cfg.Low < 0 || cfg.High <= cfg.Low {
+		return nil, errors.New("bad SSB passband")
+	}
+	if ar/2 <= cfg.High {
+		return nil, errors.New("audio rate too low for the passband")
+	}
+	dec, err := dsp.NewDecimator(dsp.DecimatorConfig{
+		InputRate:  cfg.SampleRate,
+		OutputRate: ar,
+		Passband:   cfg.High,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rs, err := dsp.NewResampler(dec.OutputRate(), ar)
+	if err != nil {
+		return nil, err
+	}
+	mid, half := (cfg.Low+cfg.High)/2, (cfg.High-cfg.Low)/2
+	if cfg.Sideband == LSB {
+		mid = -mid
+	}
+	// a transition band of 10% of the passband, at least 50 Hz
+	tb := half / 5
+	if tb < 50 {
+		tb = 50
+	}
+	return &SSB{
+		dec:  dec,
+		rs:   rs,
+		down: dsp.NewNCO(ar, -mid),
+		up:   dsp.NewNCO(ar, mid),
+		lp:   dsp.NewFIRFilter(dsp.LowpassKaiser(half/ar, (half+tb)/ar, 60)),
+		agc:  newAGC(ar, cfg.AGCDecay),
+	}, nil
+}
+
+// Process demodulates src into audio in dst.
+func (s *SSB) Process(dst []float32, src []complex64) []float32 {
+	s.tmp = s.dec.Process(s.tmp, src)
+	s.bb = s.rs.Process(s.bb, s.tmp)
+	s.bb = s.down.Mix(s.bb, s.bb)
+	s.tmp = s.lp.Process(s.tmp, s.bb)
+	s.tmp = s.up.Mix(s.tmp, s.tmp)
+	if s.agc != nil {
+		s.tmp = s.agc.Process(s.tmp, s.tmp)
+	}
+	dst = grow(dst, len(s.tmp))
+	for i, v := range s.tmp {
+		dst[i] = real(v)
+	}
+	return dst
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package demod
+
+import (
+	"math"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// Stereo blend limits on the pilot SNR, measured in the pilot filter's
+// roughly 600 Hz bandwidth. Below blendLowDb the output is mono, above
+// blendHighDb full stereo.
+const (
+	blendLowDb  = 15
+	blendHighDb = 30
+)
+
+// Stereo decodes the FM stereo multiplex. A PLL locks to the 19 kHz
+// pilot, the doubled pilot phase regenerates the 38 kHz subcarrier
+// which brings L-R down to baseband, and the stereo separation is
+// blended in as the pilot SNR rises, since L-R carries most of the
+// noise on weak stations.
+type Stereo struct {
+	fs float64
+
+	bp dsp.Cascade // pilot filter
+
+	// PLL, sin(theta) follows the pilot
+	theta, omega float64
+	kp, ki       float64
+
+	dgain      float64 // L-R gain
+	a          float64 // level time constant
+	amp, quad  float64 // pilot in phase and quadrature levels
+	power      float64 // pilot filter output power
+	blend      float32
+	locked     bool
+	sum, diff  *audioChain
+	bpo        []float32
+	dmpx       []float32
+	mono, side []float32
+}
+
+// NewStereo returns a stereo decoder for a multiplex at mpxRate.
+// deemph is the de-emphasis time constant, negative disables it.
+func NewStereo(mpxRate float64, audioRate int, deemph time.Duration) (*Stereo, error) {
+	sum, err := newAudioChain(mpxRate, audioRate, deemph)
+	if err != nil {
+		return nil, err
+	}
+	diff, _ := newAudioChain(mpxRate, audioRate, deemph)
+
+	// second order loop, 10 Hz noise bandwidth, critically damped
+	const bn, zeta = 10.0, 0.707
+	wn := 2 * bn / (zeta + 1/(4*zeta)) / mpxRate
+
+	// The discriminator averages the frequency over each sample, a
+	// sinc response that costs L-R a few percent at 38 kHz, enough to
+	// spoil the separation. Make that up along with the factor of 2
+	// for L-R being carried at half amplitude and the 2 in
+	// sin(2t) = 2 sin(t) cos(t).
+	x := math.Pi * 2 * wbfmPilot / mpxRate
+	return &Stereo{
+		dgain: 4 * x / math.Sin(x),
+		fs:    mpxRate,
+		bp: dsp.Cascade{
+			dsp.BandpassBiquad(wbfmPilot/mpxRate, 20),
+			dsp.BandpassBiquad(wbfmPilot/mpxRate, 20),
+		},
+		omega: 2 * math.Pi * wbfmPilot / mpxRate,
+		kp:    2 * zeta * wn,
+		ki:    wn * wn,
+		a:     alpha(mpxRate, 20*time.Millisecond),
+		sum:   sum,
+		diff:  diff,
+	}, nil
+}
+
+// alpha returns the one-pole smoothing factor for time constant tc.
+func alpha(fs float64, tc time.Duration) float64 {
+	return 1 - math.Exp(-1/(fs*tc.Seconds()))
+}
+
+// Locked reports whether the PLL has locked to a pilot.
+func (s *Stereo) Locked() bool {
+	return s.locked
+}
+
+// IsStereo is the stereo indicator: the pilot is locked and strong
+// enough for some stereo separation to be blended in.
+func (s *Stereo) IsStereo() bool {
+	return s.locked && s.blend > 0
+}
+
+// Blend returns the stereo separation in use, from 0 for mono to 1 for
+// full stereo.
+func (s *Stereo) Blend() float64 {
+	return float64(s.blend)
+}
+
+// PilotFreq returns the tracked pilot frequency in Hz.
+func (s *Stereo) PilotFreq() float64 {
+	return s.omega * s.fs / (2 * math.Pi)
+}
+
+// PilotSNRDb returns the pilot SNR within the pilot filter bandwidth.
+func (s *Stereo) PilotSNRDb() float64 {
+	// a pilot of amplitude A has power A^2/2 and in phase level A/2
+	p := 2 * s.amp * s.amp
+	return iq.DB(p) - iq.DB(math.Max(s.power-p, 1e-20))
+}
+
+// Process decodes the multiplex in mpx into interleaved left and right
+// audio in dst.
+func (s *Stereo) Process(dst, mpx []float32) []float32 {
+	s.bpo = s.bp.ProcessReal(s.bpo, mpx)
+	s.dmpx = grow(s.dmpx, len(mpx))
+	theta, omega := s.theta, s.omega
+	amp, quad, power := s.amp, s.quad, s.power
+	for i, x := range s.bpo {
+		sn, cs := math.Sincos(theta)
+		v := float64(x)
+		amp += s.a * (v*sn - amp)
+		quad += s.a * (v*cs - quad)
+		power += s.a * (v*v - power)
+
+		// phase detector, normalised by the pilot level
+		e := v * cs / math.Max(amp, 1e-3)
+		omega += s.ki * e
+		theta += omega + s.kp*e
+		if theta > 2*math.Pi {
+			theta -= 2 * math.Pi
+		}
+		// sin(2 theta) is the 38 kHz subcarrier
+		s.dmpx[i] = mpx[i] * float32(s.dgain*sn*cs)
+	}
+	s.theta, s.omega = theta, omega
+	s.amp, s.quad, s.power = amp, quad, power
+	s.locked = amp > 0 && math.Abs(quad) < 0.3*amp
+
+	s.mono = s.sum.process(s.mono, mpx)
+	s.side = s.diff.process(s.side, s.dmpx)
+
+	target := float32(0)
+	if s.locked {
+		snr := s.PilotSNRDb()
+		target = float32(math.Max(0, math.Min(1, (snr-blendLowDb)/(blendHighDb-blendLowDb))))
+	}
+	dst = grow(dst, 2*len(s.mono))
+	n := float32(len(s.mono))
+	for i, m := range s.mono {
+		// ramp the blend across the block to avoid clicks
+		b := s.blend + (target-s.blend)*float32(i+1)/n
+		d := b * s.side[i]
+		dst[2*i] = m + d
+		dst[2*i+1] = m - d
+	}
+	s.blend = target
+	return dst
+}
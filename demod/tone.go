@@ -0,0 +1,310 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package demod
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+)
+
+// CTCSSTones is the standard set of CTCSS tones in Hz, the EIA tones
+// and the later additions.
+var CTCSSTones = []float64{
+	67.0, 69.3, 71.9, 74.4, 77.0, 79.7, 82.5, 85.4, 88.5, 91.5,
+	94.8, 97.4, 100.0, 103.5, 107.2, 110.9, 114.8, 118.8, 123.0, 127.3,
+	131.8, 136.5, 141.3, 146.2, 150.0, 151.4, 156.7, 159.8, 162.2, 165.5,
+	167.9, 171.3, 173.8, 177.3, 179.9, 183.5, 186.2, 189.9, 192.8, 196.6,
+	199.5, 203.5, 206.5, 210.7, 218.1, 225.7, 229.1, 233.6, 241.8, 250.3,
+	254.1,
+}
+
+// DCSCodes is the standard set of DCS codes, written in octal.
+var DCSCodes = []int{
+	0023, 0025, 0026, 0031, 0032, 0036, 0043, 0047, 0051, 0053,
+	0054, 0065, 0071, 0072, 0073, 0074, 0114, 0115, 0116, 0122,
+	0125, 0131, 0132, 0134, 0143, 0145, 0152, 0155, 0156, 0162,
+	0165, 0172, 0174, 0205, 0212, 0223, 0225, 0226, 0243, 0244,
+	0245, 0246, 0251, 0252, 0255, 0261, 0263, 0265, 0266, 0271,
+	0274, 0306, 0311, 0315, 0325, 0331, 0332, 0343, 0346, 0351,
+	0356, 0364, 0365, 0371, 0411, 0412, 0413, 0423, 0431, 0432,
+	0445, 0446, 0452, 0454, 0455, 0462, 0464, 0465, 0466, 0503,
+	0506, 0516, 0523, 0526, 0532, 0546, 0565, 0606, 0612, 0624,
+	0627, 0631, 0632, 0654, 0662, 0664, 0703, 0712, 0723, 0731,
+	0732, 0734, 0743, 0754,
+}
+
+// DCSCode is a DCS code and its polarity.
+type DCSCode struct {
+	Code     int // octal, e.g. 0754
+	Inverted bool
+}
+
+// String returns the code in the usual form, e.g. "D754N".
+func (c DCSCode) String() string {
+	p := 'N'
+	if c.Inverted {
+		p = 'I'
+	}
+	return fmt.Sprintf("D%03o%c", c.Code, p)
+}
+
+// DCS sends a 23-bit Golay (23,12) code word continuously at 134.4 bit/s,
+// first the 9 code bits least significant first, then the bits 0, 0, 1
+// and the 11 check bits. Bit 0 of a word here is the first sent.
+const (
+	dcsRate   = 134.4
+	dcsBits   = 23
+	dcsGolay  = 0xc75 // x^11+x^10+x^6+x^5+x^4+x^2+1
+	dcsMarker = 0x800
+)
+
+// dcsWords maps each standard code's word to the code.
+var dcsWords = map[uint32]int{}
+
+func init() {
+	for _, c := range DCSCodes {
+		dcsWords[dcsWord(c)] = c
+	}
+}
+
+// dcsWord returns the word sent for code.
+func dcsWord(code int) uint32 {
+	d := uint32(code | dcsMarker)
+	// the code is cyclic so any 12 consecutive bits determine the rest,
+	// find the check bits making the word a multiple of the generator
+	for p := uint32(0); p < 1<<11; p++ {
+		w := d | p<<12
+		if gf2Mod(w) == 0 {
+			return w
+		}
+	}
+	panic("demod: no DCS check bits")
+}
+
+// sameDCS reports whether codes a and b are sent as the same bit stream.
+func sameDCS(a, b DCSCode) bool {
+	const mask = 1<<dcsBits - 1
+	wa, wb := dcsWord(a.Code), dcsWord(b.Code)
+	if a.Inverted != b.Inverted {
+		wb = ^wb & mask
+	}
+	for r := uint(0); r < dcsBits; r++ {
+		if (wa>>r|wa<<(dcsBits-r))&mask == wb {
+			return true
+		}
+	}
+	return false
+}
+
+// gf2Mod returns w modulo the Golay generator, both as polynomials over
+// GF(2) with bit i the coefficient of x^i.
+func gf2Mod(w uint32) uint32 {
+	for i := dcsBits - 1; i >= 11; i-- {
+		if w&(1<<uint(i)) != 0 {
+			w ^= dcsGolay << uint(i-11)
+		}
+	}
+	return w
+}
+
+// Sub-audible detection parameters.
+const (
+	subAudibleCorner = 300 // Hz, top of the sub-audible band
+	subAudibleRate   = 800 // Hz, minimum detection rate
+	toneWindow       = 500 * time.Millisecond
+	toneHops         = 5   // detections per window
+	toneRatio        = 0.3 // minimum share of the band's power
+)
+
+// SubAudible detects CTCSS tones and DCS codes in demodulated FM audio.
+// The audio is low pass filtered to the sub-audible band and decimated.
+// Tones are found with a bank of Goertzel filters over a sliding half
+// second window, a tone being detected when it holds a good share of
+// the band's power twice running. DCS is sliced and clocked by a
+// transition tracking loop, a code being detected when its word is seen
+// twice a word apart. Some codes are cyclic shifts of each other, or of
+// another's inverse such as D023N and D047I, and can't be told apart;
+// the first seen is reported.
+type SubAudible struct {
+	lp dsp.Cascade
+	m  int // decimation
+	n  int // decimation phase
+	fs float64
+
+	// CTCSS
+	coef      []float64 // Goertzel coefficients
+	win       []float32 // sliding window, oldest first after unrolling
+	pos, fill int
+	hop       int
+	cand      float64 // candidate from the last detection
+	tone      float64
+	misses    int
+
+	// DCS
+	dc       float64
+	da       float64
+	clk      float64 // bit clock phase, bits are taken as it wraps
+	last     bool
+	reg      uint32
+	bits     int
+	seen     map[DCSCode]int // bit count when each code was last seen
+	dcs      DCSCode
+	dcsValid bool
+	dcsAt    int
+
+	tmp []float32
+}
+
+// NewSubAudible returns a detector for audio at sampleRate, scaled so
+// the peak deviation reads 1.
+func NewSubAudible(sampleRate float64) *SubAudible {
+	m := int(sampleRate / subAudibleRate)
+	if m < 1 {
+		m = 1
+	}
+	fs := sampleRate / float64(m)
+	s := &SubAudible{
+		lp:   dsp.ButterworthLowpass(6, subAudibleCorner/sampleRate),
+		m:    m,
+		fs:   fs,
+		win:  make([]float32, int(fs*toneWindow.Seconds())),
+		da:   alpha(fs, time.Second),
+		seen: map[DCSCode]int{},
+	}
+	for _, t := range CTCSSTones {
+		s.coef = append(s.coef, 2*math.Cos(2*math.Pi*t/fs))
+	}
+	return s
+}
+
+// Tone returns the CTCSS tone detected, 0 for none.
+func (s *SubAudible) Tone() float64 {
+	return s.tone
+}
+
+// DCS returns the DCS code detected, if any.
+func (s *SubAudible) DCS() (DCSCode, bool) {
+	return s.dcs, s.dcsValid
+}
+
+// Reset clears the detections, e.g. when the channel is retuned.
+func (s *SubAudible) Reset() {
+	s.tone, s.cand, s.misses, s.fill = 0, 0, 0, 0
+	s.dcsValid = false
+	s.seen = map[DCSCode]int{}
+}
+
+// Process runs the detectors over audio samples x.
+func (s *SubAudible) Process(x []float32) {
+	s.tmp = s.lp.ProcessReal(s.tmp, x)
+	for _, v := range s.tmp {
+		if s.n++; s.n < s.m {
+			continue
+		}
+		s.n = 0
+		s.ctcss(v)
+		s.dcsSample(float64(v))
+	}
+}
+
+func (s *SubAudible) ctcss(v float32) {
+	s.win[s.pos] = v
+	s.pos = (s.pos + 1) % len(s.win)
+	if s.fill < len(s.win) {
+		s.fill++
+		return
+	}
+	if s.hop++; s.hop < len(s.win)/toneHops {
+		return
+	}
+	s.hop = 0
+
+	var total float64
+	for _, x := range s.win {
+		total += float64(x) * float64(x)
+	}
+	n := float64(len(s.win))
+	total /= n
+	best, bestPow := -1, 0.0
+	for k, c := range s.coef {
+		var s1, s2 float64
+		for i := range s.win {
+			x := float64(s.win[(s.pos+i)%len(s.win)])
+			s1, s2 = x+c*s1-s2, s1
+		}
+		// power of a sinusoid is A^2/2 and |X| = A*N/2
+		p := 2 * (s1*s1 + s2*s2 - c*s1*s2) / (n * n)
+		if p > bestPow {
+			best, bestPow = k, p
+		}
+	}
+
+	t := 0.0
+	if best >= 0 && bestPow > toneRatio*total {
+		t = CTCSSTones[best]
+	}
+	switch {
+	case t != 0 && t == s.cand:
+		s.tone, s.misses = t, 0
+	case t != s.tone:
+		// drop the tone after two misses
+		if s.misses++; s.misses >= 2 {
+			s.tone = 0
+		}
+	}
+	s.cand = t
+}
+
+func (s *SubAudible) dcsSample(v float64) {
+	s.dc += s.da * (v - s.dc)
+	high := v > s.dc
+	if high != s.last {
+		// transitions belong half way between bit samples
+		s.clk -= 0.2 * (s.clk - 0.5)
+		s.last = high
+	}
+	if s.clk += dcsRate / s.fs; s.clk < 1 {
+		return
+	}
+	s.clk--
+
+	var b uint32
+	if high {
+		b = 1
+	}
+	s.reg = (s.reg>>1 | b<<(dcsBits-1)) & (1<<dcsBits - 1)
+	s.bits++
+
+	for _, w := range [...]struct {
+		word uint32
+		inv  bool
+	}{{s.reg, false}, {^s.reg & (1<<dcsBits - 1), true}} {
+		c, ok := dcsWords[w.word]
+		if !ok {
+			continue
+		}
+		code := DCSCode{Code: c, Inverted: w.inv}
+		if at, ok := s.seen[code]; ok && s.bits-at == dcsBits {
+			if !s.dcsValid || code == s.dcs {
+				s.dcs, s.dcsValid, s.dcsAt = code, true, s.bits
+			}
+		}
+		s.seen[code] = s.bits
+	}
+	// drop the code after two missing words
+	if s.dcsValid && s.bits-s.dcsAt > 2*dcsBits {
+		s.dcsValid = false
+	}
+	if len(s.seen) > 16 {
+		for c, at := range s.seen {
+			if s.bits-at > dcsBits {
+				delete(s.seen, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"errors"
+	"math"
+
+	"github.com/jpoirier/gortlsdr/spectrum"
+)
+
+// ChannelizerConfig holds the settings for a Channelizer.
+type ChannelizerConfig struct {
+	SampleRate float64
+
+	// NumChannels is the number of channels spanning the sample rate,
+	// so the channel spacing is SampleRate/NumChannels.
+	NumChannels int
+
+	// Oversample outputs each channel at twice the channel spacing
+	// instead of at the spacing, which keeps signals straddling a
+	// channel edge intact. NumChannels must then be even.
+	Oversample bool
+
+	// Channels selects the channels to output, default all. Channel k
+	// is centered k spacings above the tuned frequency, negative
+	// indexes count down from it.
+	Channels []int
+
+	// Passband is the alias free part of each channel as a fraction of
+	// the spacing, default 0.8. The stopband starts at the channel edge
+	// when critically sampled and at the adjacent channel's passband
+	// when oversampled.
+	Passband    float64
+	Attenuation float64 // stopband attenuation, default 60 dB
+}
+
+// Channelizer is a polyphase filterbank splitting a complex stream into
+// equally spaced channels. The input is run through one polyphase
+// filter shared by every channel, then an M point inverse DFT yields
+// all M channels at once. When only a few channels are selected they're
+// computed directly instead of by FFT, so unused channels cost nothing
+// beyond the shared filter.
+type Channelizer struct {
+	m, d    int
+	spacing float64
+	sel     []int
+
+	taps []float32 // prototype lowpass, time reversed
+	buf  []complex64
+	pos  int
+	odd  bool // oversampled frame parity
+
+	v   []complex128
+	fft *spectrum.FFT
+	out []complex128
+	tw  [][]complex128 // per selected channel, for the direct DFT
+}
+
+// NewChannelizer designs a channelizer for the given configuration.
+func NewChannelizer(cfg ChannelizerConfig) (*Channelizer, error) {
+	m := cfg.NumChannels
+	if cfg.SampleRate <= 0 || m < 2 {
+		return nil, errors.New("invalid channelizer configuration")
+	}
+	d := m
+	if cfg.Oversample {
+		if m%2 != 0 {
+			return nil, errors.New("oversampled channelizer needs an even number of channels")
+		}
+		d = m / 2
+	}
+	if cfg.Passband == 0 {
+		cfg.Passband = 0.8
+	}
+	if cfg.Passband <= 0 || cfg.Passband >= 1 {
+		return nil, errors.New("invalid channelizer passband")
+	}
+	if cfg.Attenuation == 0 {
+		cfg.Attenuation = 60
+	}
+
+	c := &Channelizer{m: m, d: d, spacing: cfg.SampleRate / float64(m)}
+	if len(cfg.Channels) == 0 {
+		for k := 0; k < m; k++ {
+			c.sel = append(c.sel, k)
+		}
+	}
+	for _, k := range cfg.Channels {
+		if k <= -m || k >= m {
+			return nil, errors.New("channel out of range")
+		}
+		c.sel = append(c.sel, (k+m)%m)
+	}
+
+	pass := cfg.Passband / 2 / float64(m)
+	stop := 0.5 / float64(m)
+	if cfg.Oversample {
+		stop = (1 - cfg.Passband/2) / float64(m)
+	}
+	h := LowpassKaiser(pass, stop, cfg.Attenuation)
+	for len(h)%m != 0 {
+		h = append(h, 0)
+	}
+	c.taps = make([]float32, len(h))
+	for i, v := range h {
+		c.taps[len(h)-1-i] = float32(v)
+	}
+	c.buf = make([]complex64, len(h)-1, 4096)
+	c.v = make([]complex128, m)
+
+	// a direct DFT costs M per channel, the FFT roughly M log2 M
+	if float64(len(c.sel)) < math.Log2(float64(m)) {
+		c.tw = make([][]complex128, len(c.sel))
+		for i, k := range c.sel {
+			c.tw[i] = make([]complex128, m)
+			for p := range c.tw[i] {
+				s, co := math.Sincos(2 * math.Pi * float64(k*p%m) / float64(m))
+				c.tw[i][p] = complex(co, s)
+			}
+		}
+	} else {
+		fft, err := spectrum.NewFFT(m)
+		if err != nil {
+			return nil, err
+		}
+		c.fft = fft
+		c.out = make([]complex128, m)
+	}
+	return c, nil
+}
+
+// Channels returns the selected channel indexes, in output order, in
+// the range [0, NumChannels).
+func (c *Channelizer) Channels() []int {
+	return c.sel
+}
+
+// ChannelOffset returns the center frequency offset, in Hz, of channel
+// k from the tuned frequency. Channels above NumChannels/2 wrap around
+// to negative offsets.
+func (c *Channelizer) ChannelOffset(k int) float64 {
+	k = (k%c.m + c.m) % c.m
+	if k > c.m/2 {
+		k -= c.m
+	}
+	return float64(k) * c.spacing
+}
+
+// Spacing returns the channel spacing in Hz.
+func (c *Channelizer) Spacing() float64 {
+	return c.spacing
+}
+
+// OutputRate returns the sample rate of each channel.
+func (c *Channelizer) OutputRate() float64 {
+	return c.spacing * float64(c.m) / float64(c.d)
+}
+
+// Reset clears the filter history.
+func (c *Channelizer) Reset() {
+	c.buf = c.buf[:len(c.taps)-1]
+	for i := range c.buf {
+		c.buf[i] = 0
+	}
+	c.pos = 0
+	c.odd = false
+}
+
+// Process channelizes src. dst holds one output slice per selected
+// channel, in the order returned by Channels, and is grown as needed.
+func (c *Channelizer) Process(dst [][]complex64, src []complex64) [][]complex64 {
+	nt := len(c.taps)
+	c.buf = append(c.buf, src...)
+	n := 0
+	if avail := len(c.buf) - c.pos - nt; avail >= 0 {
+		n = avail/c.d + 1
+	}
+	if cap(dst) < len(c.sel) {
+		dst = make([][]complex64, len(c.sel))
+	}
+	dst = dst[:len(c.sel)]
+	for i := range dst {
+		dst[i] = grow(dst[i], n)
+	}
+
+	m := c.m
+	for j := 0; j < n; j++ {
+		// v[p] is polyphase branch p, the sum of taps p, p+M, ...
+		// applied to the matching delayed inputs
+		x := c.buf[c.pos : c.pos+nt]
+		for p := range c.v {
+			c.v[p] = 0
+		}
+		p := m - 1
+		for k, t := range c.taps {
+			c.v[p] += complex(float64(t*real(x[k])), float64(t*imag(x[k])))
+			if p--; p < 0 {
+				p = m - 1
+			}
+		}
+
+		// oversampled frames advance by M/2, rotating odd channels
+		// by pi on odd frames
+		flip := c.odd
+		if c.fft != nil {
+			c.fft.Inverse(c.out, c.v)
+			for i, k := range c.sel {
+				y := c.out[k]
+				if flip && k%2 != 0 {
+					y = -y
+				}
+				dst[i][j] = complex64(y)
+			}
+		} else {
+			for i, k := range c.sel {
+				var y complex128
+				for p, w := range c.tw[i] {
+					y += c.v[p] * w
+				}
+				if flip && k%2 != 0 {
+					y = -y
+				}
+				dst[i][j] = complex64(y)
+			}
+		}
+		if c.d != m {
+			c.odd = !c.odd
+		}
+		c.pos += c.d
+	}
+	keep := c.pos
+	if keep > len(c.buf) {
+		keep = len(c.buf)
+	}
+	c.buf = c.buf[:copy(c.buf, c.buf[keep:])]
+	c.pos -= keep
+	return dst
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+)
+
+// alpha returns the one-pole smoothing factor for n samples at rate fs
+// with time constant tc.
+func alpha(n int, fs float64, tc time.Duration) float64 {
+	if tc <= 0 || fs <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-float64(n)/(fs*tc.Seconds()))
+}
+
+// DCBlocker tracks and removes the DC offset that shows up as a spike
+// at the center of RTL2832 spectra.
+type DCBlocker struct {
+	a  float32
+	dc complex64
+}
+
+// NewDCBlocker returns a DC blocker averaging over time constant tc.
+// Longer time constants notch out less of the signal around 0 Hz.
+func NewDCBlocker(sampleRate float64, tc time.Duration) *DCBlocker {
+	return &DCBlocker{a: float32(alpha(1, sampleRate, tc))}
+}
+
+// Offset returns the current DC estimate.
+func (d *DCBlocker) Offset() complex64 {
+	return d.dc
+}
+
+// SetOffset seeds the DC estimate, e.g. from a saved Calibration.
+func (d *DCBlocker) SetOffset(dc complex64) {
+	d.dc = dc
+}
+
+// Process removes the DC offset from src into dst.
+func (d *DCBlocker) Process(dst, src []complex64) []complex64 {
+	dst = grow(dst, len(src))
+	a := complex(d.a, 0)
+	dc := d.dc
+	for i, v := range src {
+		dc += a * (v - dc)
+		dst[i] = v - dc
+	}
+	d.dc = dc
+	return dst
+}
+
+// IQBalancer blindly estimates and corrects I/Q gain and phase
+// imbalance. For a proper signal I and Q have equal power and are
+// uncorrelated; the balancer tracks E[I^2], E[Q^2] and E[IQ] and
+// rescales and orthogonalises Q against I. DC should be removed first.
+type IQBalancer struct {
+	fs float64
+	tc time.Duration
+
+	ii, qq, iq float64
+	primed     bool
+
+	// Hold freezes the estimate, e.g. after loading a calibration.
+	Hold bool
+}
+
+// NewIQBalancer returns a balancer averaging over time constant tc.
+func NewIQBalancer(sampleRate float64, tc time.Duration) *IQBalancer {
+	return &IQBalancer{fs: sampleRate, tc: tc}
+}
+
+// Estimate returns the Q/I gain ratio and the phase error, in radians,
+// of Q relative to I.
+func (b *IQBalancer) Estimate() (gain, phase float64) {
+	if !b.primed || b.ii <= 0 || b.qq <= 0 {
+		return 1, 0
+	}
+	gain = math.Sqrt(b.qq / b.ii)
+	s := b.iq / math.Sqrt(b.ii*b.qq)
+	return gain, math.Asin(math.Max(-1, math.Min(1, s)))
+}
+
+// SetEstimate seeds the estimate, e.g. from a saved Calibration.
+func (b *IQBalancer) SetEstimate(gain, phase float64) {
+	b.ii = 1
+	b.qq = gain * gain
+	b.iq = gain * math.Sin(phase)
+	b.primed = true
+}
+
+// Process corrects src into dst, updating the estimate first unless
+// Hold is set.
+func (b *IQBalancer) Process(dst, src []complex64) []complex64 {
+	dst = grow(dst, len(src))
+	if len(src) == 0 {
+		return dst
+	}
+	if !b.Hold {
+		var ii, qq, iq float64
+		for _, v := range src {
+			re, im := float64(real(v)), float64(imag(v))
+			ii += re * re
+			qq += im * im
+			iq += re * im
+		}
+		n := float64(len(src))
+		a := alpha(len(src), b.fs, b.tc)
+		if !b.primed {
+			a, b.primed = 1, true
+		}
+		b.ii += a * (ii/n - b.ii)
+		b.qq += a * (qq/n - b.qq)
+		b.iq += a * (iq/n - b.iq)
+	}
+	gain, phase := b.Estimate()
+	// Q = g(sin t cos p + cos t sin p), I = cos t
+	c1 := float32(1 / (gain * math.Cos(phase)))
+	c2 := float32(-math.Tan(phase))
+	for i, v := range src {
+		dst[i] = complex(real(v), c1*imag(v)+c2*real(v))
+	}
+	return dst
+}
+
+// Calibration holds the DC and I/Q imbalance estimates for a dongle so
+// they can be saved and restored by serial number.
+type Calibration struct {
+	DCI   float64 `json:"dc_i"`
+	DCQ   float64 `json:"dc_q"`
+	Gain  float64 `json:"gain"`
+	Phase float64 `json:"phase"`
+}
+
+// NewCalibration captures the current estimates.
+func NewCalibration(dc *DCBlocker, b *IQBalancer) Calibration {
+	c := Calibration{Gain: 1}
+	if dc != nil {
+		c.DCI, c.DCQ = float64(real(dc.dc)), float64(imag(dc.dc))
+	}
+	if b != nil {
+		c.Gain, c.Phase = b.Estimate()
+	}
+	return c
+}
+
+// Apply seeds the blocks with the calibration, either may be nil.
+func (c Calibration) Apply(dc *DCBlocker, b *IQBalancer) {
+	if dc != nil {
+		dc.SetOffset(complex(float32(c.DCI), float32(c.DCQ)))
+	}
+	if b != nil {
+		b.SetEstimate(c.Gain, c.Phase)
+	}
+}
+
+// ReadCalibrations reads calibrations keyed by dongle serial from a
+// JSON file. A missing file yields an empty map.
+func ReadCalibrations(path string) (map[string]Calibration, error) {
+	m := map[string]Calibration{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, json.Unmarshal(b, &m)
+}
+
+// WriteCalibrations writes calibrations keyed by dongle serial to a
+// JSON file.
+func WriteCalibrations(path string, m map[string]Calibration) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"errors"
+	"sort"
+)
+
+// FIRDecimator is a polyphase FIR decimator with real taps. Only the
+// retained outputs are computed: each is the sum of the M polyphase
+// branch outputs, which share the input delay line, so it's evaluated
+// as a single dot product over a stride-M window.
+type FIRDecimator struct {
+	taps []float32 // time reversed
+	m    int
+	buf  []complex64 // history followed by new input
+	pos  int         // start of the next output window in buf
+}
+
+// NewFIRDecimator returns a decimator by m using taps.
+func NewFIRDecimator(taps []float64, m int) *FIRDecimator {
+	if m < 1 {
+		m = 1
+	}
+	d := &FIRDecimator{taps: make([]float32, len(taps)), m: m}
+	for i, v := range taps {
+		d.taps[len(taps)-1-i] = float32(v)
+	}
+	d.Reset()
+	return d
+}
+
+// Factor returns the decimation factor.
+func (d *FIRDecimator) Factor() int {
+	return d.m
+}
+
+// Reset clears the filter history.
+func (d *FIRDecimator) Reset() {
+	d.buf = make([]complex64, len(d.taps)-1, 4096)
+	d.pos = 0
+}
+
+// Process filters and decimates src into dst.
+func (d *FIRDecimator) Process(dst, src []complex64) []complex64 {
+	nt := len(d.taps)
+	d.buf = append(d.buf, src...)
+	n := 0
+	if avail := len(d.buf) - d.pos - nt; avail >= 0 {
+		n = avail/d.m + 1
+	}
+	dst = grow(dst, n)
+	taps := d.taps
+	for j := range dst {
+		x := d.buf[d.pos : d.pos+nt]
+		var re, im float32
+		k := 0
+		for ; k+4 <= nt; k += 4 {
+			re += taps[k]*real(x[k]) + taps[k+1]*real(x[k+1]) +
+				taps[k+2]*real(x[k+2]) + taps[k+3]*real(x[k+3])
+			im += taps[k]*imag(x[k]) + taps[k+1]*imag(x[k+1]) +
+				taps[k+2]*imag(x[k+2]) + taps[k+3]*imag(x[k+3])
+		}
+		for ; k < nt; k++ {
+			re += taps[k] * real(x[k])
+			im += taps[k] * imag(x[k])
+		}
+		dst[j] = complex(re, im)
+		d.pos += d.m
+	}
+	// keep the samples still needed by future outputs
+	keep := d.pos
+	if keep > len(d.buf) {
+		keep = len(d.buf)
+	}
+	d.buf = d.buf[:copy(d.buf, d.buf[keep:])]
+	d.pos -= keep
+	return dst
+}
+
+// DecimatorConfig holds the settings for an automatically designed,
+// possibly multi-stage, decimator.
+type DecimatorConfig struct {
+	InputRate  float64
+	OutputRate float64 // requested rate, the actual rate is InputRate/Factor
+
+	// Passband is the highest frequency offset, in Hz, kept free of
+	// aliases, default 40% of the output rate.
+	Passband float64
+
+	Attenuation    float64 // stopband attenuation, default 60 dB
+	MaxStageFactor int     // largest factor per stage, default 8
+}
+
+// Decimator reduces the sample rate by an integer factor using a chain
+// of FIR decimators, each designed only as tight as needed to keep
+// aliases out of the final passband.
+type Decimator struct {
+	stages []*FIRDecimator
+	rate   float64
+	factor int
+	tmp    []complex64
+}
+
+// NewDecimator designs a decimator. The factor is the largest integer
+// giving an output rate at or above cfg.OutputRate; use a Resampler
+// when an exact rational rate is needed.
+func NewDecimator(cfg DecimatorConfig) (*Decimator, error) {
+	if cfg.InputRate <= 0 || cfg.OutputRate <= 0 || cfg.OutputRate > cfg.InputRate {
+		return nil, errors.New("invalid decimator rates")
+	}
+	if cfg.Attenuation == 0 {
+		cfg.Attenuation = 60
+	}
+	if cfg.MaxStageFactor < 2 {
+		cfg.MaxStageFactor = 8
+	}
+	r := int(cfg.InputRate/cfg.OutputRate + 1e-9)
+	out := cfg.InputRate / float64(r)
+	if cfg.Passband == 0 {
+		cfg.Passband = 0.4 * out
+	}
+	if cfg.Passband >= out/2 {
+		return nil, errors.New("passband must be below half the output rate")
+	}
+
+	d := &Decimator{rate: out, factor: r}
+	fs := cfg.InputRate
+	factors := stageFactors(r, cfg.MaxStageFactor)
+	for i, m := range factors {
+		fo := fs / float64(m)
+		// aliases folding into [-Passband, Passband] come from
+		// beyond fo - Passband, the last stage also protects the
+		// transition band
+		stop := fo - cfg.Passband
+		if i == len(factors)-1 {
+			stop = fo / 2
+		}
+		taps := LowpassKaiser(cfg.Passband/fs, stop/fs, cfg.Attenuation)
+		d.stages = append(d.stages, NewFIRDecimator(taps, m))
+		fs = fo
+	}
+	return d, nil
+}
+
+// stageFactors splits r into factors no larger than max, where
+// possible, largest first.
+func stageFactors(r, max int) []int {
+	var primes []int
+	for p := 2; r > 1; {
+		if r%p == 0 {
+			primes = append(primes, p)
+			r /= p
+			continue
+		}
+		p++
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(primes)))
+	var f []int
+next:
+	for _, p := range primes {
+		for i := range f {
+			if f[i]*p <= max {
+				f[i] *= p
+				continue next
+			}
+		}
+		f = append(f, p)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(f)))
+	return f
+}
+
+// OutputRate returns the actual output sample rate.
+func (d *Decimator) OutputRate() float64 {
+	return d.rate
+}
+
+// Factor returns the overall decimation factor.
+func (d *Decimator) Factor() int {
+	return d.factor
+}
+
+// Stages returns the decimation factor of each stage.
+func (d *Decimator) Stages() []int {
+	f := make([]int, len(d.stages))
+	for i, s := range d.stages {
+		f[i] = s.Factor()
+	}
+	return f
+}
+
+// Reset clears the filter history of every stage.
+func (d *Decimator) Reset() {
+	for _, s := range d.stages {
+		s.Reset()
+	}
+}
+
+// Process decimates src into dst.
+func (d *Decimator) Process(dst, src []complex64) []complex64 {
+	if len(d.stages) == 0 {
+		dst = grow(dst, len(src))
+		copy(dst, src)
+		return dst
+	}
+	in := src
+	for i, s := range d.stages {
+		if i == len(d.stages)-1 {
+			return s.Process(dst, in)
+		}
+		d.tmp = s.Process(d.tmp, in)
+		in = d.tmp
+	}
+	return dst
+}
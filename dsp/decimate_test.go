@@ -0,0 +1,34 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// blockSamples is the samples in a buffer of the device's default
+// length, 16 USB transfers of 16 KiB of cu8.
+const blockSamples = 16 * 16384 / 2
+
+// BenchmarkDecimator decimates device sized blocks from 2.4 MS/s to
+// 48 kHz. The bytes are those of the complex64 input.
+func BenchmarkDecimator(b *testing.B) {
+	d, err := NewDecimator(DecimatorConfig{InputRate: 2.4e6, OutputRate: 48e3})
+	if err != nil {
+		b.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	src := make([]complex64, blockSamples)
+	for i := range src {
+		src[i] = complex(float32(r.NormFloat64()), float32(r.NormFloat64()))
+	}
+	var dst []complex64
+	b.SetBytes(int64(8 * len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst = d.Process(dst, src)
+	}
+}
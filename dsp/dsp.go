@@ -0,0 +1,29 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package dsp provides signal processing blocks for the complex sample
+// stream, filters, decimators and the like.
+//
+// Blocks process a buffer at a time and keep their state between
+// calls. Process methods take an output slice that's grown as needed
+// and returned, so a caller can reuse one buffer per block:
+//
+//	out = dec.Process(out, in)
+package dsp
+
+// grow returns dst resized to n elements, reallocating when needed.
+func grow(dst []complex64, n int) []complex64 {
+	if cap(dst) < n {
+		return make([]complex64, n)
+	}
+	return dst[:n]
+}
+
+// growReal is grow for real samples.
+func growReal(dst []float32, n int) []float32 {
+	if cap(dst) < n {
+		return make([]float32, n)
+	}
+	return dst[:n]
+}
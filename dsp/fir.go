@@ -0,0 +1,54 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+// FIRFilter is a plain FIR filter with real taps for complex or real
+// streams. It keeps separate state for each, but should only be fed one
+// kind of stream.
+type FIRFilter struct {
+	taps []float32 // time reversed
+	buf  []complex64
+	rbuf []float32
+}
+
+// NewFIRFilter returns a filter using taps.
+func NewFIRFilter(taps []float64) *FIRFilter {
+	f := &FIRFilter{taps: make([]float32, len(taps))}
+	for i, v := range taps {
+		f.taps[len(taps)-1-i] = float32(v)
+	}
+	f.Reset()
+	return f
+}
+
+// Reset clears the filter history.
+func (f *FIRFilter) Reset() {
+	f.buf = make([]complex64, len(f.taps)-1, 4096)
+	f.rbuf = make([]float32, len(f.taps)-1, 4096)
+}
+
+// Process filters complex samples.
+func (f *FIRFilter) Process(dst, src []complex64) []complex64 {
+	nt := len(f.taps)
+	f.buf = append(f.buf, src...)
+	dst = grow(dst, len(src))
+	for i := range dst {
+		dst[i] = dotComplex(f.taps, f.buf[i:i+nt])
+	}
+	f.buf = f.buf[:copy(f.buf, f.buf[len(src):])]
+	return dst
+}
+
+// ProcessReal filters real samples.
+func (f *FIRFilter) ProcessReal(dst, src []float32) []float32 {
+	nt := len(f.taps)
+	f.rbuf = append(f.rbuf, src...)
+	dst = growReal(dst, len(src))
+	for i := range dst {
+		dst[i] = dotReal(f.taps, f.rbuf[i:i+nt])
+	}
+	f.rbuf = f.rbuf[:copy(f.rbuf, f.rbuf[len(src):])]
+	return dst
+}
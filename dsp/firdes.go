@@ -0,0 +1,196 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Frequencies passed to the design functions are normalised to the
+// sample rate, i.e. 0.5 is the Nyquist frequency.
+
+// KaiserBeta returns the Kaiser window beta giving atten dB of stopband
+// attenuation.
+func KaiserBeta(atten float64) float64 {
+	switch {
+	case atten > 50:
+		return 0.1102 * (atten - 8.7)
+	case atten >= 21:
+		return 0.5842*math.Pow(atten-21, 0.4) + 0.07886*(atten-21)
+	}
+	return 0
+}
+
+// KaiserTaps returns the odd filter length needed for atten dB of
+// stopband attenuation with the given transition width.
+func KaiserTaps(atten, transition float64) int {
+	n := int(math.Ceil((atten-7.95)/(14.36*transition))) + 1
+	if n < 3 {
+		n = 3
+	}
+	return n | 1
+}
+
+// Kaiser returns a symmetric Kaiser window of length n.
+func Kaiser(n int, beta float64) []float64 {
+	w := make([]float64, n)
+	if n == 1 {
+		w[0] = 1
+		return w
+	}
+	d := besselI0(beta)
+	for i := range w {
+		r := 2*float64(i)/float64(n-1) - 1
+		w[i] = besselI0(beta*math.Sqrt(1-r*r)) / d
+	}
+	return w
+}
+
+// besselI0 is the zeroth order modified Bessel function of the first
+// kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+// sinc returns the ideal lowpass impulse response with cutoff fc
+// centered on a filter of length n.
+func sinc(n int, fc float64) []float64 {
+	h := make([]float64, n)
+	m := float64(n-1) / 2
+	for i := range h {
+		t := float64(i) - m
+		if t == 0 {
+			h[i] = 2 * fc
+		} else {
+			h[i] = math.Sin(2*math.Pi*fc*t) / (math.Pi * t)
+		}
+	}
+	return h
+}
+
+// LowpassKaiser designs a Kaiser windowed-sinc lowpass filter with the
+// passband ending at pass and the stopband starting at stop, giving
+// atten dB of stopband attenuation. The taps have unity DC gain.
+func LowpassKaiser(pass, stop, atten float64) []float64 {
+	n := KaiserTaps(atten, stop-pass)
+	h := sinc(n, (pass+stop)/2)
+	w := Kaiser(n, KaiserBeta(atten))
+	sum := 0.0
+	for i := range h {
+		h[i] *= w[i]
+		sum += h[i]
+	}
+	for i := range h {
+		h[i] /= sum
+	}
+	return h
+}
+
+// HighpassKaiser designs a Kaiser windowed-sinc highpass filter with the
+// stopband ending at stop and the passband starting at pass, by
+// spectral inversion of the matching lowpass. The taps have unity gain
+// at Nyquist.
+func HighpassKaiser(stop, pass, atten float64) []float64 {
+	h := LowpassKaiser(stop, pass, atten)
+	for i := range h {
+		h[i] = -h[i]
+	}
+	h[len(h)/2]++
+	return h
+}
+
+// BandpassKaiser designs a Kaiser windowed-sinc bandpass filter passing
+// lo to hi with transition bands of the given width on either side. The
+// taps have unity gain at the band center.
+func BandpassKaiser(lo, hi, transition, atten float64) []float64 {
+	half := (hi - lo) / 2
+	h := LowpassKaiser(half, half+transition, atten)
+	fc := (lo + hi) / 2
+	m := float64(len(h)-1) / 2
+	for i := range h {
+		h[i] *= 2 * math.Cos(2*math.Pi*fc*(float64(i)-m))
+	}
+	g := cmplx.Abs(Response(h, fc))
+	for i := range h {
+		h[i] /= g
+	}
+	return h
+}
+
+// RootRaisedCosine designs a root raised cosine pulse shaping filter
+// for sps samples per symbol with rolloff beta, spanning span symbols.
+// The taps have unit energy, so a matched pair has unity peak gain.
+func RootRaisedCosine(sps, beta float64, span int) []float64 {
+	n := int(float64(span)*sps) | 1
+	h := make([]float64, n)
+	m := float64(n-1) / 2
+	energy := 0.0
+	for i := range h {
+		t := (float64(i) - m) / sps
+		switch {
+		case t == 0:
+			h[i] = 1 - beta + 4*beta/math.Pi
+		case beta != 0 && math.Abs(math.Abs(4*beta*t)-1) < 1e-9:
+			h[i] = beta / math.Sqrt2 * ((1+2/math.Pi)*math.Sin(math.Pi/(4*beta)) +
+				(1-2/math.Pi)*math.Cos(math.Pi/(4*beta)))
+		default:
+			h[i] = (math.Sin(math.Pi*t*(1-beta)) + 4*beta*t*math.Cos(math.Pi*t*(1+beta))) /
+				(math.Pi * t * (1 - 16*beta*beta*t*t))
+		}
+		energy += h[i] * h[i]
+	}
+	g := 1 / math.Sqrt(energy)
+	for i := range h {
+		h[i] *= g
+	}
+	return h
+}
+
+// Gaussian designs the Gaussian pulse shaping filter used by GMSK for
+// sps samples per symbol with bandwidth-time product bt, spanning span
+// symbols. The taps have unity DC gain.
+func Gaussian(sps, bt float64, span int) []float64 {
+	n := int(float64(span)*sps) | 1
+	h := make([]float64, n)
+	m := float64(n-1) / 2
+	// standard deviation in samples
+	sigma := math.Sqrt(math.Ln2) / (2 * math.Pi * bt) * sps
+	sum := 0.0
+	for i := range h {
+		t := float64(i) - m
+		h[i] = math.Exp(-t * t / (2 * sigma * sigma))
+		sum += h[i]
+	}
+	for i := range h {
+		h[i] /= sum
+	}
+	return h
+}
+
+// Response returns the frequency response of FIR taps h at normalised
+// frequency f.
+func Response(h []float64, f float64) complex128 {
+	var r complex128
+	for i, v := range h {
+		s, c := math.Sincos(-2 * math.Pi * f * float64(i))
+		r += complex(v*c, v*s)
+	}
+	return r
+}
+
+// ResponseDb returns the magnitude response of FIR taps h at normalised
+// frequency f in dB.
+func ResponseDb(h []float64, f float64) float64 {
+	return 20 * math.Log10(cmplx.Abs(Response(h, f)))
+}
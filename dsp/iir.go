@@ -0,0 +1,166 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import "math"
+
+// Biquad is a second order IIR section in transposed direct form II,
+//
+//	H(z) = (B0 + B1 z^-1 + B2 z^-2) / (1 + A1 z^-1 + A2 z^-2)
+//
+// The designs follow the RBJ audio EQ cookbook, f is normalised to the
+// sample rate and q is the quality factor, 1/sqrt(2) for a Butterworth
+// response.
+type Biquad struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+
+	z1, z2   float64    // real state
+	zc1, zc2 complex128 // complex state
+}
+
+// biquad normalises cookbook coefficients by a0.
+func biquad(b0, b1, b2, a0, a1, a2 float64) *Biquad {
+	return &Biquad{B0: b0 / a0, B1: b1 / a0, B2: b2 / a0, A1: a1 / a0, A2: a2 / a0}
+}
+
+// LowpassBiquad returns a lowpass section with corner frequency f.
+func LowpassBiquad(f, q float64) *Biquad {
+	s, c := math.Sincos(2 * math.Pi * f)
+	a := s / (2 * q)
+	return biquad((1-c)/2, 1-c, (1-c)/2, 1+a, -2*c, 1-a)
+}
+
+// HighpassBiquad returns a highpass section with corner frequency f.
+func HighpassBiquad(f, q float64) *Biquad {
+	s, c := math.Sincos(2 * math.Pi * f)
+	a := s / (2 * q)
+	return biquad((1+c)/2, -(1 + c), (1+c)/2, 1+a, -2*c, 1-a)
+}
+
+// BandpassBiquad returns a bandpass section centered on f with unity
+// peak gain.
+func BandpassBiquad(f, q float64) *Biquad {
+	s, c := math.Sincos(2 * math.Pi * f)
+	a := s / (2 * q)
+	return biquad(a, 0, -a, 1+a, -2*c, 1-a)
+}
+
+// NotchBiquad returns a notch section centered on f.
+func NotchBiquad(f, q float64) *Biquad {
+	s, c := math.Sincos(2 * math.Pi * f)
+	a := s / (2 * q)
+	return biquad(1, -2*c, 1, 1+a, -2*c, 1-a)
+}
+
+// Reset clears the filter state.
+func (b *Biquad) Reset() {
+	b.z1, b.z2 = 0, 0
+	b.zc1, b.zc2 = 0, 0
+}
+
+// Response returns the frequency response at normalised frequency f.
+func (b *Biquad) Response(f float64) complex128 {
+	s, c := math.Sincos(-2 * math.Pi * f)
+	z1 := complex(c, s)
+	z2 := z1 * z1
+	return (complex(b.B0, 0) + complex(b.B1, 0)*z1 + complex(b.B2, 0)*z2) /
+		(1 + complex(b.A1, 0)*z1 + complex(b.A2, 0)*z2)
+}
+
+// ProcessReal filters real samples.
+func (b *Biquad) ProcessReal(dst, src []float32) []float32 {
+	dst = growReal(dst, len(src))
+	z1, z2 := b.z1, b.z2
+	for i, v := range src {
+		x := float64(v)
+		y := b.B0*x + z1
+		z1 = b.B1*x - b.A1*y + z2
+		z2 = b.B2*x - b.A2*y
+		dst[i] = float32(y)
+	}
+	b.z1, b.z2 = z1, z2
+	return dst
+}
+
+// Process filters complex samples.
+func (b *Biquad) Process(dst, src []complex64) []complex64 {
+	dst = grow(dst, len(src))
+	b0, b1, b2 := complex(b.B0, 0), complex(b.B1, 0), complex(b.B2, 0)
+	a1, a2 := complex(b.A1, 0), complex(b.A2, 0)
+	z1, z2 := b.zc1, b.zc2
+	for i, v := range src {
+		x := complex128(v)
+		y := b0*x + z1
+		z1 = b1*x - a1*y + z2
+		z2 = b2*x - a2*y
+		dst[i] = complex64(y)
+	}
+	b.zc1, b.zc2 = z1, z2
+	return dst
+}
+
+// Cascade is a chain of biquad sections.
+type Cascade []*Biquad
+
+// Response returns the combined frequency response at normalised
+// frequency f.
+func (c Cascade) Response(f float64) complex128 {
+	r := complex(1, 0)
+	for _, b := range c {
+		r *= b.Response(f)
+	}
+	return r
+}
+
+// ProcessReal filters real samples through every section.
+func (c Cascade) ProcessReal(dst, src []float32) []float32 {
+	if len(c) == 0 {
+		dst = growReal(dst, len(src))
+		copy(dst, src)
+		return dst
+	}
+	dst = c[0].ProcessReal(dst, src)
+	for _, b := range c[1:] {
+		b.ProcessReal(dst, dst)
+	}
+	return dst
+}
+
+// Process filters complex samples through every section.
+func (c Cascade) Process(dst, src []complex64) []complex64 {
+	if len(c) == 0 {
+		dst = grow(dst, len(src))
+		copy(dst, src)
+		return dst
+	}
+	dst = c[0].Process(dst, src)
+	for _, b := range c[1:] {
+		b.Process(dst, dst)
+	}
+	return dst
+}
+
+// ButterworthLowpass returns an order n Butterworth lowpass with corner
+// frequency f as a cascade of biquads, n rounded up to even.
+func ButterworthLowpass(n int, f float64) Cascade {
+	var c Cascade
+	for k := 0; k < (n+1)/2; k++ {
+		q := 1 / (2 * math.Sin(math.Pi*float64(2*k+1)/float64(4*((n+1)/2))))
+		c = append(c, LowpassBiquad(f, q))
+	}
+	return c
+}
+
+// ButterworthHighpass returns an order n Butterworth highpass with
+// corner frequency f as a cascade of biquads, n rounded up to even.
+func ButterworthHighpass(n int, f float64) Cascade {
+	var c Cascade
+	for k := 0; k < (n+1)/2; k++ {
+		q := 1 / (2 * math.Sin(math.Pi*float64(2*k+1)/float64(4*((n+1)/2))))
+		c = append(c, HighpassBiquad(f, q))
+	}
+	return c
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// PowerMeter measures RMS and peak power in dBFS.
+type PowerMeter struct {
+	a    float64
+	ms   float64 // smoothed mean square
+	peak float64
+}
+
+// NewPowerMeter returns a meter averaging over time constant tc.
+func NewPowerMeter(sampleRate float64, tc time.Duration) *PowerMeter {
+	return &PowerMeter{a: alpha(1, sampleRate, tc)}
+}
+
+// Measure updates the meter with complex samples.
+func (m *PowerMeter) Measure(x []complex64) {
+	ms, peak := m.ms, m.peak
+	for _, v := range x {
+		p := float64(real(v)*real(v) + imag(v)*imag(v))
+		ms += m.a * (p - ms)
+		if p > peak {
+			peak = p
+		}
+	}
+	m.ms, m.peak = ms, peak
+}
+
+// MeasureReal updates the meter with real samples. A full scale sine
+// reads -3 dBFS.
+func (m *PowerMeter) MeasureReal(x []float32) {
+	ms, peak := m.ms, m.peak
+	for _, v := range x {
+		p := float64(v * v)
+		ms += m.a * (p - ms)
+		if p > peak {
+			peak = p
+		}
+	}
+	m.ms, m.peak = ms, peak
+}
+
+// RMSDb returns the RMS power in dBFS.
+func (m *PowerMeter) RMSDb() float64 {
+	return iq.DB(m.ms)
+}
+
+// PeakDb returns the peak sample power since the last ResetPeak.
+func (m *PowerMeter) PeakDb() float64 {
+	return iq.DB(m.peak)
+}
+
+// ResetPeak clears the peak hold.
+func (m *PowerMeter) ResetPeak() {
+	m.peak = 0
+}
+
+// AGC is a software automatic gain control. It follows the signal
+// envelope with separate attack and decay time constants and scales
+// the signal to bring the envelope to the target level.
+type AGC struct {
+	target  float64
+	attack  float64
+	decay   float64
+	maxGain float64
+	env     float64
+}
+
+// NewAGC returns an AGC bringing the envelope to targetDb dBFS, with
+// gain limited to maxGainDb.
+func NewAGC(sampleRate, targetDb, maxGainDb float64, attack, decay time.Duration) *AGC {
+	return &AGC{
+		target:  math.Pow(10, targetDb/20),
+		attack:  alpha(1, sampleRate, attack),
+		decay:   alpha(1, sampleRate, decay),
+		maxGain: math.Pow(10, maxGainDb/20),
+	}
+}
+
+// GainDb returns the current gain.
+func (a *AGC) GainDb() float64 {
+	return 20 * math.Log10(a.gain())
+}
+
+func (a *AGC) gain() float64 {
+	if a.env*a.maxGain <= a.target {
+		return a.maxGain
+	}
+	return a.target / a.env
+}
+
+// track updates the envelope with magnitude e and returns the gain.
+func (a *AGC) track(e float64) float64 {
+	if e > a.env {
+		a.env += a.attack * (e - a.env)
+	} else {
+		a.env += a.decay * (e - a.env)
+	}
+	return a.gain()
+}
+
+// Process applies the AGC to complex samples.
+func (a *AGC) Process(dst, src []complex64) []complex64 {
+	dst = grow(dst, len(src))
+	for i, v := range src {
+		e := math.Hypot(float64(real(v)), float64(imag(v)))
+		dst[i] = v * complex(float32(a.track(e)), 0)
+	}
+	return dst
+}
+
+// ProcessReal applies the AGC to real samples, e.g. demodulated audio.
+func (a *AGC) ProcessReal(dst, src []float32) []float32 {
+	dst = growReal(dst, len(src))
+	for i, v := range src {
+		dst[i] = v * float32(a.track(math.Abs(float64(v))))
+	}
+	return dst
+}
+
+// SquelchEvent reports the squelch opening or closing.
+type SquelchEvent struct {
+	Open    bool
+	Sample  int64   // sample index since the squelch was created
+	LevelDb float64 // averaged level at the transition
+}
+
+// SquelchConfig holds the squelch settings.
+type SquelchConfig struct {
+	SampleRate   float64
+	ThresholdDb  float64       // opening level, dBFS
+	HysteresisDb float64       // closes this far below the threshold
+	Averaging    time.Duration // level time constant, default 5ms
+	Tail         time.Duration // stays open this long after the level drops
+
+	// OnEvent, when set, is called on every open and close.
+	OnEvent func(SquelchEvent)
+}
+
+// Squelch gates a signal on its averaged power, with hysteresis and a
+// tail time to ride through short fades.
+type Squelch struct {
+	cfg       SquelchConfig
+	a         float64
+	open      float64
+	close     float64
+	tail      int64
+	ms        float64
+	isOpen    bool
+	lastAbove int64
+	n         int64
+}
+
+// NewSquelch returns a squelch for the given configuration.
+func NewSquelch(cfg SquelchConfig) *Squelch {
+	if cfg.Averaging <= 0 {
+		cfg.Averaging = 5 * time.Millisecond
+	}
+	return &Squelch{
+		cfg:   cfg,
+		a:     alpha(1, cfg.SampleRate, cfg.Averaging),
+		open:  iq.FromDB(cfg.ThresholdDb),
+		close: iq.FromDB(cfg.ThresholdDb - cfg.HysteresisDb),
+		tail:  int64(cfg.Tail.Seconds() * cfg.SampleRate),
+	}
+}
+
+// Open reports whether the squelch is open.
+func (s *Squelch) Open() bool {
+	return s.isOpen
+}
+
+// LevelDb returns the averaged level in dBFS.
+func (s *Squelch) LevelDb() float64 {
+	return iq.DB(s.ms)
+}
+
+// update advances the squelch by one sample of power p.
+func (s *Squelch) update(p float64) bool {
+	s.ms += s.a * (p - s.ms)
+	switch {
+	case !s.isOpen && s.ms >= s.open:
+		s.isOpen = true
+		s.lastAbove = s.n
+		s.event()
+	case s.isOpen && s.ms >= s.close:
+		s.lastAbove = s.n
+	case s.isOpen && s.n-s.lastAbove > s.tail:
+		s.isOpen = false
+		s.event()
+	}
+	s.n++
+	return s.isOpen
+}
+
+func (s *Squelch) event() {
+	if s.cfg.OnEvent != nil {
+		s.cfg.OnEvent(SquelchEvent{Open: s.isOpen, Sample: s.n, LevelDb: s.LevelDb()})
+	}
+}
+
+// Process gates complex samples, zeroing them while closed.
+func (s *Squelch) Process(dst, src []complex64) []complex64 {
+	dst = grow(dst, len(src))
+	for i, v := range src {
+		if s.update(float64(real(v)*real(v) + imag(v)*imag(v))) {
+			dst[i] = v
+		} else {
+			dst[i] = 0
+		}
+	}
+	return dst
+}
+
+// ProcessReal gates real samples, zeroing them while closed.
+func (s *Squelch) ProcessReal(dst, src []float32) []float32 {
+	dst = growReal(dst, len(src))
+	for i, v := range src {
+		if s.update(float64(v * v)) {
+			dst[i] = v
+		} else {
+			dst[i] = 0
+		}
+	}
+	return dst
+}
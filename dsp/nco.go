@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import "math"
+
+// The NCO's phase is a 32-bit accumulator. The top 20 bits index two
+// 1024 entry tables, a coarse one covering the whole circle and a fine
+// one covering one coarse step, whose product gives the phasor with
+// spurs around -114 dBc.
+const (
+	ncoTableBits = 10
+	ncoTableSize = 1 << ncoTableBits
+	ncoFineShift = 32 - 2*ncoTableBits
+)
+
+var ncoCoarse, ncoFine [ncoTableSize]complex64
+
+func init() {
+	for i := range ncoCoarse {
+		s, c := math.Sincos(2 * math.Pi * float64(i) / ncoTableSize)
+		ncoCoarse[i] = complex(float32(c), float32(s))
+		s, c = math.Sincos(2 * math.Pi * float64(i) / (ncoTableSize * ncoTableSize))
+		ncoFine[i] = complex(float32(c), float32(s))
+	}
+}
+
+// NCO is a table driven numerically controlled oscillator and complex
+// mixer with a resolution of the sample rate / 2^32, about 0.5 mHz at
+// 2.4 MS/s. Frequency changes are phase continuous.
+type NCO struct {
+	fs    float64
+	phase uint32
+	step  uint32
+}
+
+// NewNCO returns an oscillator at freq Hz, which may be negative.
+func NewNCO(sampleRate, freq float64) *NCO {
+	n := &NCO{fs: sampleRate}
+	n.SetFrequency(freq)
+	return n
+}
+
+// SetFrequency changes the frequency without a phase discontinuity.
+func (n *NCO) SetFrequency(freq float64) {
+	n.step = uint32(int64(math.Floor(freq/n.fs*(1<<32) + 0.5)))
+}
+
+// Frequency returns the actual, quantised, frequency.
+func (n *NCO) Frequency() float64 {
+	return float64(int32(n.step)) / (1 << 32) * n.fs
+}
+
+// Phase returns the current phase in radians.
+func (n *NCO) Phase() float64 {
+	return float64(n.phase) / (1 << 32) * 2 * math.Pi
+}
+
+// SetPhase sets the current phase in radians.
+func (n *NCO) SetPhase(rad float64) {
+	n.phase = uint32(int64(math.Floor(rad/(2*math.Pi)*(1<<32) + 0.5)))
+}
+
+func (n *NCO) phasor() complex64 {
+	return ncoCoarse[n.phase>>(32-ncoTableBits)] *
+		ncoFine[n.phase>>ncoFineShift&(ncoTableSize-1)]
+}
+
+// Next returns the next oscillator sample, e^(j phase).
+func (n *NCO) Next() complex64 {
+	v := n.phasor()
+	n.phase += n.step
+	return v
+}
+
+// Mix multiplies src by the oscillator into dst, moving signals up by
+// the NCO frequency. Use a negative frequency to bring a signal at +f
+// down to 0 Hz. dst may be src.
+func (n *NCO) Mix(dst, src []complex64) []complex64 {
+	dst = grow(dst, len(src))
+	for i, v := range src {
+		dst[i] = v * n.phasor()
+		n.phase += n.step
+	}
+	return dst
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	remezDensity = 16
+	remezIters   = 40
+)
+
+// Remez designs an n tap linear phase equiripple FIR filter with the
+// Parks-McClellan algorithm. bands holds pairs of normalised band
+// edges, ascending in [0, 0.5], desired the gain wanted in each band
+// and weight, which may be nil, the relative weight of each band's
+// error. Even lengths can't have gain at Nyquist.
+func Remez(n int, bands, desired, weight []float64) ([]float64, error) {
+	nb := len(bands) / 2
+	if n < 3 || len(bands) != 2*nb || nb == 0 || len(desired) != nb {
+		return nil, errors.New("invalid remez specification")
+	}
+	if weight == nil {
+		weight = make([]float64, nb)
+		for i := range weight {
+			weight[i] = 1
+		}
+	}
+	if len(weight) != nb {
+		return nil, errors.New("invalid remez weights")
+	}
+	for i := 1; i < len(bands); i++ {
+		if bands[i] < bands[i-1] || bands[0] < 0 || bands[i] > 0.5 {
+			return nil, errors.New("invalid remez band edges")
+		}
+	}
+	even := n%2 == 0
+	r := (n + 1) / 2 // cosine terms
+	if even {
+		r = n / 2
+	}
+
+	// dense grid over the bands, for even lengths the desired response
+	// and weight absorb the cos(pi f) factor, which vanishes at Nyquist
+	var grid, des, wt []float64
+	var band []int
+	step := 0.5 / float64(remezDensity*r)
+	for b := 0; b < nb; b++ {
+		lo, hi := bands[2*b], bands[2*b+1]
+		if even && hi > 0.5-step {
+			hi = 0.5 - step
+		}
+		k := int(math.Ceil((hi-lo)/step)) + 1
+		for j := 0; j < k; j++ {
+			f := lo + (hi-lo)*float64(j)/math.Max(1, float64(k-1))
+			d, w := desired[b], weight[b]
+			if even {
+				c := math.Cos(math.Pi * f)
+				d, w = d/c, w*c
+			}
+			grid = append(grid, f)
+			des = append(des, d)
+			wt = append(wt, w)
+			band = append(band, b)
+		}
+	}
+	if len(grid) < r+1 {
+		return nil, errors.New("remez grid too small")
+	}
+
+	ext := make([]int, r+1)
+	for i := range ext {
+		ext[i] = i * (len(grid) - 1) / r
+	}
+	x := make([]float64, r+1)
+	c := make([]float64, r+1)
+	e := make([]float64, len(grid))
+	var ad []float64
+	for it := 0; it < remezIters; it++ {
+		for i, g := range ext {
+			x[i] = math.Cos(2 * math.Pi * grid[g])
+		}
+		ad = baryWeights(x)
+		var num, den float64
+		sign := 1.0
+		for i, g := range ext {
+			num += ad[i] * des[g]
+			den += sign * ad[i] / wt[g]
+			sign = -sign
+		}
+		delta := num / den
+		sign = 1
+		for i, g := range ext {
+			c[i] = des[g] - sign*delta/wt[g]
+			sign = -sign
+		}
+		// interpolate through the first r points
+		ad = baryWeights(x[:r])
+		for j, f := range grid {
+			e[j] = wt[j] * (des[j] - baryEval(math.Cos(2*math.Pi*f), x[:r], c[:r], ad))
+		}
+		next := remezExtrema(e, band, r+1)
+		if next == nil {
+			return nil, errors.New("remez failed to converge")
+		}
+		ext = next
+		lo, hi := math.Inf(1), 0.0
+		for _, g := range ext {
+			a := math.Abs(e[g])
+			lo = math.Min(lo, a)
+			hi = math.Max(hi, a)
+		}
+		if hi-lo <= 1e-4*hi {
+			break
+		}
+	}
+
+	// recover the taps from the amplitude response sampled at n points,
+	// evaluated through the nodes the last interpolation was built on
+	amp := make([]float64, n)
+	for j := range amp {
+		w := 2 * math.Pi * float64(j) / float64(n)
+		a := baryEval(math.Cos(w), x[:r], c[:r], ad)
+		if even {
+			a *= math.Cos(w / 2)
+		}
+		amp[j] = a
+	}
+	h := make([]float64, n)
+	m := float64(n-1) / 2
+	for i := range h {
+		s := 0.0
+		for j, a := range amp {
+			s += a * math.Cos(2*math.Pi*float64(j)*(float64(i)-m)/float64(n))
+		}
+		h[i] = s / float64(n)
+	}
+	return h, nil
+}
+
+// baryWeights returns the barycentric interpolation weights for nodes
+// x, scaled to avoid overflow.
+func baryWeights(x []float64) []float64 {
+	w := make([]float64, len(x))
+	logs := make([]float64, len(x))
+	max := math.Inf(-1)
+	for i := range x {
+		l, sign := 0.0, 1.0
+		for j := range x {
+			if i == j {
+				continue
+			}
+			d := 2 * (x[i] - x[j])
+			if d < 0 {
+				sign = -sign
+			}
+			l -= math.Log(math.Abs(d) + 1e-300)
+		}
+		logs[i] = l
+		w[i] = sign
+		if l > max {
+			max = l
+		}
+	}
+	for i := range w {
+		w[i] *= math.Exp(logs[i] - max)
+	}
+	return w
+}
+
+// baryEval evaluates the polynomial through (x, y) at t.
+func baryEval(t float64, x, y, w []float64) float64 {
+	var num, den float64
+	for i := range x {
+		d := t - x[i]
+		if math.Abs(d) < 1e-12 {
+			return y[i]
+		}
+		num += w[i] / d * y[i]
+		den += w[i] / d
+	}
+	return num / den
+}
+
+// remezExtrema returns the indexes of n alternating local extrema of e,
+// or nil when there are too few. Points only compare against neighbours
+// in their own band.
+func remezExtrema(e []float64, band []int, n int) []int {
+	var ext []int
+	for j := range e {
+		// signed extrema, so a band edge where the error is still
+		// growing counts
+		a, s := math.Abs(e[j]), math.Copysign(1, e[j])
+		if j > 0 && band[j-1] == band[j] && a < s*e[j-1] ||
+			j < len(e)-1 && band[j+1] == band[j] && a < s*e[j+1] {
+			continue
+		}
+		// keep the larger of neighbouring extrema of the same sign
+		if k := len(ext) - 1; k >= 0 && (e[ext[k]] >= 0) == (e[j] >= 0) {
+			if a > math.Abs(e[ext[k]]) {
+				ext[k] = j
+			}
+			continue
+		}
+		ext = append(ext, j)
+	}
+	for len(ext) > n {
+		if math.Abs(e[ext[0]]) < math.Abs(e[ext[len(ext)-1]]) {
+			ext = ext[1:]
+		} else {
+			ext = ext[:len(ext)-1]
+		}
+	}
+	if len(ext) < n {
+		return nil
+	}
+	return ext
+}
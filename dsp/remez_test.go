@@ -0,0 +1,44 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRemez(t *testing.T) {
+	for _, c := range []struct {
+		n                      int
+		bands, desired, weight []float64
+		ripple, stop           float64 // dB, largest allowed
+	}{
+		{31, []float64{0, 0.2, 0.3, 0.5}, []float64{1, 0}, nil, 0.015, -57},
+		{61, []float64{0, 0.1, 0.15, 0.5}, []float64{1, 0}, []float64{1, 10}, 0.06, -64},
+		{60, []float64{0, 0.1, 0.15, 0.5}, []float64{1, 0}, []float64{1, 10}, 0.06, -64},
+		{101, []float64{0, 0.1, 0.15, 0.25, 0.3, 0.5}, []float64{0, 1, 0}, nil, 0.001, -84},
+	} {
+		h, err := Remez(c.n, c.bands, c.desired, c.weight)
+		if err != nil {
+			t.Fatalf("%d taps: %v", c.n, err)
+		}
+		if len(h) != c.n {
+			t.Fatalf("%d taps: got %d", c.n, len(h))
+		}
+		for b, d := range c.desired {
+			lo, hi := math.Inf(1), math.Inf(-1)
+			for f := c.bands[2*b]; f <= c.bands[2*b+1]; f += 1e-4 {
+				v := ResponseDb(h, f)
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+			if d == 0 && hi > c.stop {
+				t.Errorf("%d taps, band %d: stopband %.1f dB, want below %v dB", c.n, b, hi, c.stop)
+			}
+			if d == 1 && (hi > c.ripple || lo < -c.ripple) {
+				t.Errorf("%d taps, band %d: passband %.4f to %.4f dB, want within %v dB", c.n, b, lo, hi, c.ripple)
+			}
+		}
+	}
+}
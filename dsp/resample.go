@@ -0,0 +1,303 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package dsp
+
+import (
+	"errors"
+	"math"
+)
+
+// Resampler changes the sample rate of complex or real streams. A
+// Resampler instance keeps separate state for each, but should only be
+// fed one kind of stream.
+type Resampler interface {
+	Process(dst, src []complex64) []complex64
+	ProcessReal(dst, src []float32) []float32
+	// Ratio returns the output to input rate ratio.
+	Ratio() float64
+}
+
+// maxRationalPhases caps the interpolation factor NewResampler will use
+// before switching to a FractionalResampler.
+const maxRationalPhases = 512
+
+// resampleAtten is the default stopband attenuation.
+const resampleAtten = 60
+
+// NewResampler returns a resampler from inRate to outRate. When the
+// rates reduce to a ratio L/M with L no more than 512 it's a
+// RationalResampler, otherwise a FractionalResampler. For large rate
+// reductions decimate with a Decimator first, it's much cheaper.
+func NewResampler(inRate, outRate float64) (Resampler, error) {
+	if inRate <= 0 || outRate <= 0 {
+		return nil, errors.New("invalid resampler rates")
+	}
+	if inRate == math.Trunc(inRate) && outRate == math.Trunc(outRate) {
+		g := gcd(int64(inRate), int64(outRate))
+		l, m := int64(outRate)/g, int64(inRate)/g
+		if l <= maxRationalPhases {
+			return NewRationalResampler(int(l), int(m), 0)
+		}
+	}
+	return NewFractionalResampler(outRate/inRate, 0)
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// resampleTaps designs the prototype lowpass for a resampler with
+// phases branches running at phases times the input rate, with ratio
+// out/in, scaled for unity passband gain after interpolation.
+func resampleTaps(phases int, ratio, atten float64) []float64 {
+	bw := math.Min(1, ratio) / float64(phases) // output band, normalised
+	h := LowpassKaiser(0.4*bw, 0.5*bw, atten)
+	// pad to a whole number of taps per phase
+	for len(h)%phases != 0 {
+		h = append(h, 0)
+	}
+	for i := range h {
+		h[i] *= float64(phases)
+	}
+	return h
+}
+
+// polyphase splits h into n branches, each time reversed so it lines up
+// with an oldest-first window of input samples.
+func polyphase(h []float64, n int) [][]float32 {
+	k := len(h) / n
+	br := make([][]float32, n)
+	for p := range br {
+		br[p] = make([]float32, k)
+		for j := 0; j < k; j++ {
+			br[p][k-1-j] = float32(h[j*n+p])
+		}
+	}
+	return br
+}
+
+func dotComplex(taps []float32, x []complex64) complex64 {
+	var re, im float32
+	for k, t := range taps {
+		re += t * real(x[k])
+		im += t * imag(x[k])
+	}
+	return complex(re, im)
+}
+
+func dotReal(taps []float32, x []float32) float32 {
+	var s float32
+	for k, t := range taps {
+		s += t * x[k]
+	}
+	return s
+}
+
+// RationalResampler is a polyphase resampler by L/M, interpolating by
+// L, filtering, and decimating by M while only computing the retained
+// outputs.
+type RationalResampler struct {
+	l, m   int
+	phases [][]float32
+	k      int
+
+	buf   []complex64
+	i     int // index in buf of the current input sample
+	phase int
+
+	rbuf   []float32
+	ri     int
+	rphase int
+}
+
+// NewRationalResampler returns a resampler by interp/decim, reduced to
+// lowest terms, with atten dB of stopband attenuation, default 60.
+func NewRationalResampler(interp, decim int, atten float64) (*RationalResampler, error) {
+	if interp < 1 || decim < 1 {
+		return nil, errors.New("invalid resampling ratio")
+	}
+	if atten == 0 {
+		atten = resampleAtten
+	}
+	g := int(gcd(int64(interp), int64(decim)))
+	r := &RationalResampler{l: interp / g, m: decim / g}
+	h := resampleTaps(r.l, float64(r.l)/float64(r.m), atten)
+	r.phases = polyphase(h, r.l)
+	r.k = len(r.phases[0])
+	r.buf = make([]complex64, r.k-1)
+	r.i = r.k - 1
+	r.rbuf = make([]float32, r.k-1)
+	r.ri = r.k - 1
+	return r, nil
+}
+
+// Factors returns the reduced interpolation and decimation factors.
+func (r *RationalResampler) Factors() (interp, decim int) {
+	return r.l, r.m
+}
+
+// Ratio returns L/M.
+func (r *RationalResampler) Ratio() float64 {
+	return float64(r.l) / float64(r.m)
+}
+
+// Process resamples complex samples.
+func (r *RationalResampler) Process(dst, src []complex64) []complex64 {
+	r.buf = append(r.buf, src...)
+	dst = dst[:0]
+	for {
+		for r.phase >= r.l {
+			r.phase -= r.l
+			r.i++
+		}
+		if r.i >= len(r.buf) {
+			break
+		}
+		dst = append(dst, dotComplex(r.phases[r.phase], r.buf[r.i-r.k+1:r.i+1]))
+		r.phase += r.m
+	}
+	drop := r.i - (r.k - 1)
+	if drop > len(r.buf) {
+		drop = len(r.buf)
+	}
+	r.buf = r.buf[:copy(r.buf, r.buf[drop:])]
+	r.i -= drop
+	return dst
+}
+
+// ProcessReal resamples real samples.
+func (r *RationalResampler) ProcessReal(dst, src []float32) []float32 {
+	r.rbuf = append(r.rbuf, src...)
+	dst = dst[:0]
+	for {
+		for r.rphase >= r.l {
+			r.rphase -= r.l
+			r.ri++
+		}
+		if r.ri >= len(r.rbuf) {
+			break
+		}
+		dst = append(dst, dotReal(r.phases[r.rphase], r.rbuf[r.ri-r.k+1:r.ri+1]))
+		r.rphase += r.m
+	}
+	drop := r.ri - (r.k - 1)
+	if drop > len(r.rbuf) {
+		drop = len(r.rbuf)
+	}
+	r.rbuf = r.rbuf[:copy(r.rbuf, r.rbuf[drop:])]
+	r.ri -= drop
+	return dst
+}
+
+// fractionalPhases is the number of polyphase branches a
+// FractionalResampler interpolates between.
+const fractionalPhases = 128
+
+// FractionalResampler resamples by an arbitrary ratio, linearly
+// interpolating between the outputs of the two nearest branches of a
+// 128 phase filter bank.
+type FractionalResampler struct {
+	ratio  float64
+	step   float64 // input samples per output
+	phases [][]float32
+	k      int
+
+	buf []complex64
+	t   float64 // position in buf of the next output
+
+	rbuf []float32
+	rt   float64
+}
+
+// NewFractionalResampler returns a resampler by ratio, the output to
+// input rate ratio, with atten dB of stopband attenuation, default 60.
+func NewFractionalResampler(ratio, atten float64) (*FractionalResampler, error) {
+	if ratio <= 0 {
+		return nil, errors.New("invalid resampling ratio")
+	}
+	if atten == 0 {
+		atten = resampleAtten
+	}
+	h := resampleTaps(fractionalPhases, ratio, atten)
+	r := &FractionalResampler{
+		ratio:  ratio,
+		step:   1 / ratio,
+		phases: polyphase(h, fractionalPhases),
+		k:      len(h) / fractionalPhases,
+	}
+	r.buf = make([]complex64, r.k-1)
+	r.t = float64(r.k - 1)
+	r.rbuf = make([]float32, r.k-1)
+	r.rt = float64(r.k - 1)
+	return r, nil
+}
+
+// Ratio returns the output to input rate ratio.
+func (r *FractionalResampler) Ratio() float64 {
+	return r.ratio
+}
+
+// branches returns the branch at fractional position f, in units of
+// branches, and the window offset and branch following it. The last
+// branch is followed by branch 0 one input sample later.
+func (r *FractionalResampler) branches(f float64) (p int, w float32, next []float32, off int) {
+	p = int(f)
+	w = float32(f - float64(p))
+	if p+1 < fractionalPhases {
+		return p, w, r.phases[p+1], 0
+	}
+	return p, w, r.phases[0], 1
+}
+
+// Process resamples complex samples.
+func (r *FractionalResampler) Process(dst, src []complex64) []complex64 {
+	r.buf = append(r.buf, src...)
+	dst = dst[:0]
+	for {
+		i := int(r.t)
+		if i+1 >= len(r.buf) {
+			break
+		}
+		p, w, next, off := r.branches((r.t - float64(i)) * fractionalPhases)
+		a := dotComplex(r.phases[p], r.buf[i-r.k+1:i+1])
+		b := dotComplex(next, r.buf[i-r.k+1+off:i+1+off])
+		dst = append(dst, a+complex(w, 0)*(b-a))
+		r.t += r.step
+	}
+	drop := int(r.t) - (r.k - 1)
+	if drop > len(r.buf) {
+		drop = len(r.buf)
+	}
+	r.buf = r.buf[:copy(r.buf, r.buf[drop:])]
+	r.t -= float64(drop)
+	return dst
+}
+
+// ProcessReal resamples real samples.
+func (r *FractionalResampler) ProcessReal(dst, src []float32) []float32 {
+	r.rbuf = append(r.rbuf, src...)
+	dst = dst[:0]
+	for {
+		i := int(r.rt)
+		if i+1 >= len(r.rbuf) {
+			break
+		}
+		p, w, next, off := r.branches((r.rt - float64(i)) * fractionalPhases)
+		a := dotReal(r.phases[p], r.rbuf[i-r.k+1:i+1])
+		b := dotReal(next, r.rbuf[i-r.k+1+off:i+1+off])
+		dst = append(dst, a+w*(b-a))
+		r.rt += r.step
+	}
+	drop := int(r.rt) - (r.k - 1)
+	if drop > len(r.rbuf) {
+		drop = len(r.rbuf)
+	}
+	r.rbuf = r.rbuf[:copy(r.rbuf, r.rbuf[drop:])]
+	r.rt -= float64(drop)
+	return dst
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package cmdutil holds the device setup and network plumbing the
+// receiver commands share.
+package cmdutil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"log"
+	"time"
+
+	rtl "github.com/jpoirier/gortlsdr"
+)
+
+// Configure opens device index and sets its sample rate, frequency
+// correction and gain, negative for auto. It tunes to freq, unless it's
+// 0 and the tuning is left to a tune.Tuner.
+func Configure(index, freq, rate, gain, ppm int) (*rtl.Context, error) {
+	dev, err := rtl.Open(index)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("using %s, tuner %s\n", rtl.GetDeviceName(index), dev.GetTunerType())
+	err = dev.SetSampleRate(rate)
+	if err == nil && freq != 0 {
+		err = dev.SetCenterFreq(freq)
+	}
+	if err == nil && ppm != 0 {
+		err = dev.SetFreqCorrection(ppm)
+	}
+	if err == nil {
+		err = dev.SetTunerGainMode(gain >= 0)
+	}
+	if err == nil && gain >= 0 {
+		err = dev.SetTunerGain(gain)
+	}
+	if err == nil {
+		err = dev.ResetBuffer()
+	}
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	return dev, nil
+}
+
+// BufferTime returns the time the first sample of buf, a cu8 buffer
+// just passed to a ReadAsync callback, was received. The buffer was
+// filled over its length in samples.
+func BufferTime(buf []byte, rate float64) time.Time {
+	return time.Now().Add(-time.Duration(float64(len(buf)/2) / rate * 1e9))
+}
+
+// Audio reads signed 16-bit little endian samples from r until it ends,
+// passing them to process scaled to ±1.
+func Audio(r io.Reader, process func([]float32)) error {
+	br := bufio.NewReader(r)
+	buf := make([]byte, 8192)
+	x := make([]float32, len(buf)/2)
+	for {
+		n, err := io.ReadFull(br, buf)
+		for i := 0; i < n/2; i++ {
+			x[i] = float32(int16(binary.LittleEndian.Uint16(buf[2*i:]))) / 32768
+		}
+		process(x[:n/2])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package cmdutil
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	queueLen     = 256 // writes held for each client
+	writeTimeout = 10 * time.Second
+)
+
+// Feed is an io.Writer copying each write to every client: the writers
+// added, such as stdout or a UDP socket, and the connections Serve
+// accepts. Writes come from the ReadAsync callback, so they never
+// block. Each client has a queue written out by its own goroutine, and
+// the writes that don't fit in it are dropped.
+type Feed struct {
+	Name string // what's fed, for the log, e.g. "SBS"
+
+	mu      sync.Mutex
+	clients map[*client]bool
+}
+
+type client struct {
+	name   string
+	w      io.Writer
+	conn   net.Conn // nil for added writers
+	queue  chan []byte
+	behind bool // dropping writes
+}
+
+// Add adds a writer. Unlike connections it's kept when writes fail, so
+// UDP errors such as nothing listening are passing.
+func (f *Feed) Add(name string, w io.Writer) {
+	f.add(&client{name: name, w: w})
+}
+
+func (f *Feed) add(c *client) {
+	c.queue = make(chan []byte, queueLen)
+	f.mu.Lock()
+	if f.clients == nil {
+		f.clients = map[*client]bool{}
+	}
+	f.clients[c] = true
+	f.mu.Unlock()
+	go f.run(c)
+}
+
+// Serve accepts clients from ln until it fails. What they send is
+// discarded, and they're dropped when they disconnect or a write fails.
+func (f *Feed) Serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		c := &client{name: conn.RemoteAddr().String(), w: conn, conn: conn}
+		log.Printf("%s client %s\n", f.Name, c.name)
+		f.add(c)
+		go func() {
+			io.Copy(ioutil.Discard, conn)
+			f.remove(c, nil)
+		}()
+	}
+}
+
+// run writes out c's queue until it's removed.
+func (f *Feed) run(c *client) {
+	for p := range c.queue {
+		if c.conn == nil {
+			c.w.Write(p)
+			continue
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := c.conn.Write(p); err != nil {
+			f.remove(c, err)
+			return
+		}
+	}
+}
+
+func (f *Feed) remove(c *client, err error) {
+	f.mu.Lock()
+	if !f.clients[c] {
+		f.mu.Unlock()
+		return
+	}
+	delete(f.clients, c)
+	close(c.queue)
+	f.mu.Unlock()
+
+	c.conn.Close()
+	if err != nil {
+		log.Printf("%s client %s: %v\n", f.Name, c.name, err)
+	} else {
+		log.Printf("%s client %s closed\n", f.Name, c.name)
+	}
+}
+
+// Write queues a copy of p for every client.
+func (f *Feed) Write(p []byte) (int, error) {
+	b := append([]byte(nil), p...)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for c := range f.clients {
+		select {
+		case c.queue <- b:
+			c.behind = false
+		default:
+			if !c.behind {
+				log.Printf("%s client %s is behind, dropping writes\n", f.Name, c.name)
+				c.behind = true
+			}
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package hdlc deframes the HDLC bit stream AX.25 and AIS frames are
+// sent in. Frames are delimited by 01111110 flags, a 0 is stuffed after
+// five 1s within them so they never look like one, and seven or more 1s
+// abort the frame. Bytes are sent least significant bit first and end
+// with a CRC-16 frame check sequence.
+package hdlc
+
+// crcGood is the CRC-16 of a frame followed by its FCS.
+const crcGood = 0xf0b8
+
+// Deframer finds the frames in a stream of NRZI decoded bits.
+type Deframer struct {
+	min, max int
+
+	ones int    // run of 1 bits
+	bits []byte // frame bits since the last flag, one per byte
+}
+
+// NewDeframer returns a deframer keeping frames of min to max bits,
+// counting the FCS.
+func NewDeframer(min, max int) *Deframer {
+	return &Deframer{min: min, max: max}
+}
+
+// Bit deframes one bit. It returns true when the bit completes a flag,
+// along with the frame before it, without the FCS, if there was one and
+// its FCS checks.
+func (d *Deframer) Bit(b byte) ([]byte, bool) {
+	if b == 1 {
+		if d.ones++; d.ones > 6 {
+			d.bits = d.bits[:0]
+		} else {
+			d.bits = append(d.bits, 1)
+		}
+		return nil, false
+	}
+	ones := d.ones
+	d.ones = 0
+	switch ones {
+	case 5:
+		return nil, false
+	case 6:
+		// the bits end with the flag's 0111111
+		var f []byte
+		if n := len(d.bits) - 7; n >= d.min && n%8 == 0 {
+			f = frame(d.bits[:n])
+		}
+		d.bits = d.bits[:0]
+		return f, true
+	}
+	d.bits = append(d.bits, 0)
+	if len(d.bits) > d.max {
+		d.bits = d.bits[:0]
+	}
+	return nil, false
+}
+
+// frame packs the frame bits between two flags into bytes and checks
+// the FCS, returning nil if it's wrong.
+func frame(b []byte) []byte {
+	buf := make([]byte, len(b)/8)
+	for i, v := range b {
+		buf[i/8] |= v << uint(i%8)
+	}
+	if crc16(buf) != crcGood {
+		return nil
+	}
+	return buf[:len(buf)-2]
+}
+
+// FCS returns the frame check sequence of b, sent least significant
+// byte first after it.
+func FCS(b []byte) uint16 {
+	return ^crc16(b)
+}
+
+// crc16 computes CRC-16-CCITT in the reflected form starting from all
+// 1s, without the final inversion.
+func crc16(b []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, v := range b {
+		crc ^= uint16(v)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
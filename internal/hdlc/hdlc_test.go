@@ -0,0 +1,90 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package hdlc
+
+import (
+	"bytes"
+	"testing"
+)
+
+var flag = []byte{0, 1, 1, 1, 1, 1, 1, 0}
+
+// stuff returns the bits sent for b, least significant first with a 0
+// after five 1s.
+func stuff(b []byte) []byte {
+	var bits []byte
+	ones := 0
+	for _, v := range b {
+		for k := uint(0); k < 8; k++ {
+			bit := v >> k & 1
+			bits = append(bits, bit)
+			if ones++; bit == 0 {
+				ones = 0
+			} else if ones == 5 {
+				bits = append(bits, 0)
+				ones = 0
+			}
+		}
+	}
+	return bits
+}
+
+// withFCS returns b followed by its FCS.
+func withFCS(b []byte) []byte {
+	fcs := FCS(b)
+	return append(append([]byte(nil), b...), byte(fcs), byte(fcs>>8))
+}
+
+func join(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestDeframer(t *testing.T) {
+	data := []byte("\x7e\xff\x00 frame \xfe\x3f")
+	bad := withFCS(data)
+	bad[3] ^= 0x10
+	for _, c := range []struct {
+		name  string
+		bits  []byte
+		want  [][]byte
+		flags int
+	}{
+		{"frame", join(flag, stuff(withFCS(data)), flag), [][]byte{data}, 2},
+		{"shared flag", join(flag, stuff(withFCS(data)), flag, stuff(withFCS(data[:3])), flag),
+			[][]byte{data, data[:3]}, 3},
+		{"bad FCS", join(flag, stuff(bad), flag), nil, 2},
+		{"too short", join(flag, stuff(withFCS(data[:1])), flag), nil, 2},
+		{"too long", join(flag, stuff(withFCS(bytes.Repeat(data, 4))), flag), nil, 2},
+		{"not whole bytes", join(flag, stuff(withFCS(data)), []byte{1, 0}, flag), nil, 2},
+		{"aborted", join(flag, stuff(withFCS(data))[:40], []byte{1, 1, 1, 1, 1, 1, 1}, stuff(withFCS(data))[40:], flag),
+			nil, 2},
+	} {
+		d := NewDeframer(5*8, 30*8)
+		var got [][]byte
+		flags := 0
+		for _, b := range c.bits {
+			f, flag := d.Bit(b)
+			if flag {
+				flags++
+			}
+			if f != nil {
+				got = append(got, f)
+			}
+		}
+		if flags != c.flags || len(got) != len(c.want) {
+			t.Errorf("%s: got %d frames and %d flags, want %d and %d", c.name, len(got), flags, len(c.want), c.flags)
+			continue
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], c.want[i]) {
+				t.Errorf("%s: got %q, want %q", c.name, got[i], c.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package iq
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jpoirier/gortlsdr/sigmf"
+	"github.com/jpoirier/gortlsdr/wav"
+)
+
+// Info holds the recording metadata carried between file formats.
+// Raw files carry none of it, WAV files only the sample rate.
+type Info struct {
+	Format      Format
+	SampleRate  float64
+	CenterFreq  float64
+	Datetime    string // ISO 8601
+	Description string
+}
+
+// merge fills unset fields from hint.
+func (i *Info) merge(hint Info) {
+	if i.Format == FormatUnknown {
+		i.Format = hint.Format
+	}
+	if i.SampleRate == 0 {
+		i.SampleRate = hint.SampleRate
+	}
+	if i.CenterFreq == 0 {
+		i.CenterFreq = hint.CenterFreq
+	}
+	if i.Datetime == "" {
+		i.Datetime = hint.Datetime
+	}
+	if i.Description == "" {
+		i.Description = hint.Description
+	}
+}
+
+type container int
+
+const (
+	containerRaw container = iota
+	containerWAV
+	containerSigMF
+)
+
+// fileType returns the container and, for raw files named after their
+// format, the sample format implied by the file extension.
+func fileType(path string) (container, Format) {
+	if strings.HasSuffix(path, sigmf.MetaExt) || strings.HasSuffix(path, sigmf.DataExt) ||
+		strings.HasSuffix(path, ".sigmf") {
+		return containerSigMF, FormatUnknown
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if ext == "wav" {
+		return containerWAV, FormatUnknown
+	}
+	f, _ := ParseFormat(ext)
+	return containerRaw, f
+}
+
+func sigmfBase(path string) string {
+	return strings.TrimSuffix(path, ".sigmf")
+}
+
+// FileReader reads samples from a raw, WAV or SigMF recording.
+type FileReader struct {
+	Info
+	*Reader
+	f *os.File
+}
+
+// OpenFile opens a recording, choosing the container by file extension.
+// Raw files use the format named by their extension, e.g. ".cu8", or
+// hint.Format otherwise. Fields the file doesn't carry are taken from
+// hint.
+func OpenFile(path string, hint Info) (*FileReader, error) {
+	c, ef := fileType(path)
+	r := &FileReader{}
+	var err error
+	switch c {
+	case containerSigMF:
+		base := sigmfBase(path)
+		m, err := sigmf.ReadMeta(base)
+		if err != nil {
+			return nil, err
+		}
+		if r.Format, err = FormatFromDatatype(m.Global.Datatype); err != nil {
+			return nil, err
+		}
+		r.SampleRate = m.Global.SampleRate
+		r.CenterFreq = m.CenterFreq()
+		r.Description = m.Global.Description
+		if len(m.Captures) > 0 {
+			r.Datetime = m.Captures[0].Datetime
+		}
+		if r.f, err = os.Open(sigmf.DataPath(base)); err != nil {
+			return nil, err
+		}
+		r.Reader = NewReader(r.f, r.Format)
+	case containerWAV:
+		if r.f, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		w, err := wav.NewReader(r.f)
+		if err != nil {
+			r.f.Close()
+			return nil, err
+		}
+		if r.Format, err = wavSampleFormat(w.Format); err != nil {
+			r.f.Close()
+			return nil, err
+		}
+		r.SampleRate = float64(w.SampleRate)
+		r.Reader = NewReader(w, r.Format)
+	default:
+		r.Format = ef
+		if r.Format == FormatUnknown {
+			r.Format = hint.Format
+		}
+		if r.Format == FormatUnknown {
+			return nil, errors.New("unknown sample format for " + path)
+		}
+		if r.f, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		r.Reader = NewReader(r.f, r.Format)
+	}
+	r.Info.merge(hint)
+	return r, nil
+}
+
+// Close closes the file.
+func (r *FileReader) Close() error {
+	return r.f.Close()
+}
+
+func wavSampleFormat(f wav.Format) (Format, error) {
+	switch {
+	case f.Channels != 2:
+		return FormatUnknown, errors.New("WAV I/Q files must have two channels")
+	case f.Float:
+		return CF32, nil
+	case f.BitsPerSample == 8:
+		return CU8, nil
+	}
+	return CS16, nil
+}
+
+// FileWriter writes samples to a raw, WAV or SigMF recording.
+type FileWriter struct {
+	Info
+	*Writer
+	f   *os.File
+	wav *wav.Writer
+}
+
+// CreateFile creates a recording, choosing the container by file
+// extension. The sample format is info.Format or, when unset, the one
+// named by a raw file's extension, cs16 for WAV and cf32 for SigMF.
+// SigMF recordings get a metadata file describing info.
+func CreateFile(path string, info Info) (*FileWriter, error) {
+	c, ef := fileType(path)
+	if info.Format == FormatUnknown {
+		switch c {
+		case containerWAV:
+			info.Format = CS16
+		case containerSigMF:
+			info.Format = CF32
+		default:
+			info.Format = ef
+		}
+	}
+	switch {
+	case info.Format == FormatUnknown:
+		return nil, errors.New("unknown sample format for " + path)
+	case ef != FormatUnknown && ef != info.Format:
+		return nil, errors.New("sample format doesn't match file extension " + path)
+	}
+
+	w := &FileWriter{Info: info}
+	var err error
+	switch c {
+	case containerSigMF:
+		base := sigmfBase(path)
+		m := sigmf.New(info.Format.Datatype(), info.SampleRate, info.CenterFreq, info.Datetime)
+		m.Global.Description = info.Description
+		if err = sigmf.WriteMeta(base, m); err != nil {
+			return nil, err
+		}
+		if w.f, err = os.Create(sigmf.DataPath(base)); err != nil {
+			return nil, err
+		}
+		w.Writer = NewWriter(w.f, info.Format)
+	case containerWAV:
+		wf := wav.Format{Channels: 2, SampleRate: int(info.SampleRate + 0.5)}
+		switch info.Format {
+		case CU8:
+			wf.BitsPerSample = 8
+		case CS16:
+			wf.BitsPerSample = 16
+		case CF32:
+			wf.BitsPerSample, wf.Float = 32, true
+		default:
+			return nil, errors.New("WAV files can't hold " + info.Format.String() + " samples")
+		}
+		if w.f, err = os.Create(path); err != nil {
+			return nil, err
+		}
+		if w.wav, err = wav.NewWriter(w.f, wf); err != nil {
+			w.f.Close()
+			return nil, err
+		}
+		w.Writer = NewWriter(w.wav, info.Format)
+	default:
+		if w.f, err = os.Create(path); err != nil {
+			return nil, err
+		}
+		w.Writer = NewWriter(w.f, info.Format)
+	}
+	return w, nil
+}
+
+// Close finishes and closes the file.
+func (w *FileWriter) Close() error {
+	var err error
+	if w.wav != nil {
+		err = w.wav.Close()
+	}
+	if e := w.f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Copy copies samples from src to dst until src returns io.EOF,
+// returning the number of samples copied.
+func Copy(dst SampleWriter, src SampleReader) (int64, error) {
+	buf := make([]complex64, 16384)
+	var total int64
+	for {
+		n, err := src.ReadSamples(buf)
+		if n > 0 {
+			if werr := dst.WriteSamples(buf[:n]); werr != nil {
+				return total, werr
+			}
+			total += int64(n)
+		}
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
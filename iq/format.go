@@ -0,0 +1,238 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package iq
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+)
+
+// Format is an interleaved I/Q sample encoding.
+type Format int
+
+// Sample formats.
+const (
+	FormatUnknown Format = iota
+	CU8                  // unsigned 8-bit, the RTL2832 native format
+	CS8                  // signed 8-bit
+	CS16                 // signed 16-bit little endian
+	CF32                 // 32-bit float little endian
+)
+
+var formatNames = map[Format]string{
+	CU8:  "cu8",
+	CS8:  "cs8",
+	CS16: "cs16",
+	CF32: "cf32",
+}
+
+// SigMF datatype names, the SigMF spec calls signed formats "ci".
+var formatDatatypes = map[Format]string{
+	CU8:  "cu8",
+	CS8:  "ci8",
+	CS16: "ci16_le",
+	CF32: "cf32_le",
+}
+
+// ParseFormat returns the format for a name such as "cu8" or "cs16".
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(name)
+	for f, n := range formatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return FormatUnknown, errors.New("unknown sample format: " + name)
+}
+
+// FormatFromDatatype returns the format for a SigMF datatype.
+func FormatFromDatatype(datatype string) (Format, error) {
+	for f, n := range formatDatatypes {
+		if n == datatype {
+			return f, nil
+		}
+	}
+	return FormatUnknown, errors.New("unsupported SigMF datatype: " + datatype)
+}
+
+func (f Format) String() string {
+	if n, ok := formatNames[f]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// Datatype returns the SigMF datatype name.
+func (f Format) Datatype() string {
+	return formatDatatypes[f]
+}
+
+// Size returns the size in bytes of one complex sample.
+func (f Format) Size() int {
+	switch f {
+	case CU8, CS8:
+		return 2
+	case CS16:
+		return 4
+	case CF32:
+		return 8
+	}
+	return 0
+}
+
+// Decode converts src, encoded as format f, to complex samples scaled
+// to +/-1.0. dst is grown as needed and returned; a trailing partial
+// sample is ignored.
+func Decode(dst []complex64, src []byte, f Format) []complex64 {
+	if f == CU8 {
+		return FromCU8(dst, src)
+	}
+	sz := f.Size()
+	if sz == 0 {
+		return dst[:0]
+	}
+	n := len(src) / sz
+	if cap(dst) < n {
+		dst = make([]complex64, n)
+	}
+	dst = dst[:n]
+	switch f {
+	case CS8:
+		for i := range dst {
+			dst[i] = complex(float32(int8(src[2*i]))/128, float32(int8(src[2*i+1]))/128)
+		}
+	case CS16:
+		for i := range dst {
+			re := int16(binary.LittleEndian.Uint16(src[4*i:]))
+			im := int16(binary.LittleEndian.Uint16(src[4*i+2:]))
+			dst[i] = complex(float32(re)/32768, float32(im)/32768)
+		}
+	case CF32:
+		for i := range dst {
+			re := math.Float32frombits(binary.LittleEndian.Uint32(src[8*i:]))
+			im := math.Float32frombits(binary.LittleEndian.Uint32(src[8*i+4:]))
+			dst[i] = complex(re, im)
+		}
+	}
+	return dst
+}
+
+// Encode converts complex samples in the range +/-1.0 to format f,
+// clipping values out of range. dst is grown as needed and returned.
+func Encode(dst []byte, src []complex64, f Format) []byte {
+	if f == CU8 {
+		return ToCU8(dst, src)
+	}
+	n := len(src) * f.Size()
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	switch f {
+	case CS8:
+		for i, v := range src {
+			dst[2*i] = byte(clampInt(real(v)*128, 127))
+			dst[2*i+1] = byte(clampInt(imag(v)*128, 127))
+		}
+	case CS16:
+		for i, v := range src {
+			binary.LittleEndian.PutUint16(dst[4*i:], uint16(clampInt(real(v)*32768, 32767)))
+			binary.LittleEndian.PutUint16(dst[4*i+2:], uint16(clampInt(imag(v)*32768, 32767)))
+		}
+	case CF32:
+		for i, v := range src {
+			binary.LittleEndian.PutUint32(dst[8*i:], math.Float32bits(real(v)))
+			binary.LittleEndian.PutUint32(dst[8*i+4:], math.Float32bits(imag(v)))
+		}
+	}
+	return dst
+}
+
+// clampInt rounds v and clips it to [-max-1, max].
+func clampInt(v float32, max int) int {
+	if v >= 0 {
+		v += 0.5
+	} else {
+		v -= 0.5
+	}
+	switch {
+	case v >= float32(max):
+		return max
+	case v <= float32(-max-1):
+		return -max - 1
+	}
+	return int(v)
+}
+
+// SampleReader is implemented by complex sample sources.
+type SampleReader interface {
+	// ReadSamples reads up to len(x) samples, returning io.EOF when
+	// no more samples are available.
+	ReadSamples(x []complex64) (int, error)
+}
+
+// SampleWriter is implemented by complex sample sinks.
+type SampleWriter interface {
+	WriteSamples(x []complex64) error
+}
+
+// Reader decodes samples from an io.Reader.
+type Reader struct {
+	r   io.Reader
+	f   Format
+	buf []byte
+	eof bool
+}
+
+// NewReader returns a reader decoding format f.
+func NewReader(r io.Reader, f Format) *Reader {
+	return &Reader{r: r, f: f}
+}
+
+// ReadSamples reads up to len(x) samples. A trailing partial sample at
+// the end of the input is dropped.
+func (r *Reader) ReadSamples(x []complex64) (int, error) {
+	if r.eof {
+		return 0, io.EOF
+	}
+	sz := r.f.Size()
+	if sz == 0 {
+		return 0, errors.New("unknown sample format")
+	}
+	if n := len(x) * sz; cap(r.buf) < n {
+		r.buf = make([]byte, n)
+	}
+	n, err := io.ReadFull(r.r, r.buf[:len(x)*sz])
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		r.eof = true
+		err = nil
+		if n < sz {
+			err = io.EOF
+		}
+	}
+	return len(Decode(x[:0], r.buf[:n], r.f)), err
+}
+
+// Writer encodes samples to an io.Writer.
+type Writer struct {
+	w   io.Writer
+	f   Format
+	buf []byte
+}
+
+// NewWriter returns a writer encoding format f.
+func NewWriter(w io.Writer, f Format) *Writer {
+	return &Writer{w: w, f: f}
+}
+
+// WriteSamples encodes and writes x.
+func (w *Writer) WriteSamples(x []complex64) error {
+	w.buf = Encode(w.buf, x, w.f)
+	_, err := w.w.Write(w.buf)
+	return err
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package iq provides helpers for working with the interleaved 8-bit
+// unsigned I/Q samples delivered by RTL2832 based dongles.
+package iq
+
+import "math"
+
+// MinDb is the floor returned by DB for zero or negative power.
+const MinDb = -200.0
+
+// cu8 maps a raw unsigned byte to a sample value in the range +/-1.0.
+var cu8 [256]float32
+
+func init() {
+	for i := range cu8 {
+		cu8[i] = (float32(i) - 127.5) / 127.5
+	}
+}
+
+// FromCU8 converts interleaved unsigned 8-bit I/Q pairs to complex
+// samples scaled to +/-1.0. dst is grown as needed and returned; a
+// trailing odd byte is ignored.
+func FromCU8(dst []complex64, src []byte) []complex64 {
+	n := len(src) / 2
+	if cap(dst) < n {
+		dst = make([]complex64, n)
+	}
+	dst = dst[:n]
+	for i := range dst {
+		dst[i] = complex(cu8[src[2*i]], cu8[src[2*i+1]])
+	}
+	return dst
+}
+
+// ToCU8 converts complex samples in the range +/-1.0 to interleaved
+// unsigned 8-bit I/Q pairs. dst is grown as needed and returned.
+func ToCU8(dst []byte, src []complex64) []byte {
+	n := len(src) * 2
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	for i, v := range src {
+		dst[2*i] = clampU8(real(v)*127.5 + 127.5)
+		dst[2*i+1] = clampU8(imag(v)*127.5 + 127.5)
+	}
+	return dst
+}
+
+func clampU8(v float32) byte {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return byte(v + 0.5)
+}
+
+// Power returns the mean power, |x|^2, of the samples.
+func Power(x []complex64) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range x {
+		re, im := float64(real(v)), float64(imag(v))
+		sum += re*re + im*im
+	}
+	return sum / float64(len(x))
+}
+
+// DB converts a linear power ratio to decibels. Since samples are
+// scaled to +/-1.0, a power value yields dBFS.
+func DB(p float64) float64 {
+	if p <= 0 {
+		return MinDb
+	}
+	db := 10 * math.Log10(p)
+	if db < MinDb {
+		return MinDb
+	}
+	return db
+}
+
+// FromDB converts decibels to a linear power ratio.
+func FromDB(db float64) float64 {
+	return math.Pow(10, db/10)
+}
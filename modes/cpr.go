@@ -0,0 +1,107 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package modes
+
+import "math"
+
+// Positions are sent in Compact Position Reporting format, 17-bit
+// latitude and longitude fractions of zones whose size differs between
+// the even and odd frames. A pair of even and odd frames gives a global
+// position; a single frame gives one relative to a nearby reference.
+
+const cprScale = 1 << 17
+
+// cprNL returns the number of longitude zones at latitude lat.
+func cprNL(lat float64) int {
+	lat = math.Abs(lat)
+	switch {
+	case lat == 0:
+		return 59
+	case lat == 87:
+		return 2
+	case lat > 87:
+		return 1
+	}
+	const nz = 15
+	a := 1 - math.Cos(math.Pi/(2*nz))
+	c := math.Cos(math.Pi / 180 * lat)
+	return int(math.Floor(2 * math.Pi / math.Acos(1-a/(c*c))))
+}
+
+// cprMod is the modulo with a non-negative result.
+func cprMod(a, b float64) float64 {
+	r := math.Mod(a, b)
+	if r < 0 {
+		r += b
+	}
+	return r
+}
+
+// cprGlobal decodes an airborne position from an even and an odd frame,
+// using the latitude and longitude of the more recent one, odd when
+// oddLast.
+func cprGlobal(even, odd [2]int, oddLast bool) (lat, lon float64, ok bool) {
+	latE, lonE := float64(even[0])/cprScale, float64(even[1])/cprScale
+	latO, lonO := float64(odd[0])/cprScale, float64(odd[1])/cprScale
+	const dLatE, dLatO = 360.0 / 60, 360.0 / 59
+
+	j := math.Floor(59*latE - 60*latO + 0.5)
+	rlatE := dLatE * (cprMod(j, 60) + latE)
+	rlatO := dLatO * (cprMod(j, 59) + latO)
+	if rlatE >= 270 {
+		rlatE -= 360
+	}
+	if rlatO >= 270 {
+		rlatO -= 360
+	}
+	nl := cprNL(rlatE)
+	if nl != cprNL(rlatO) {
+		// the frames straddle a zone boundary
+		return 0, 0, false
+	}
+
+	lat = rlatE
+	ni, f := nl, lonE
+	if oddLast {
+		lat, ni, f = rlatO, nl-1, lonO
+	}
+	if ni < 1 {
+		ni = 1
+	}
+	m := math.Floor(lonE*float64(nl-1) - lonO*float64(nl) + 0.5)
+	lon = 360 / float64(ni) * (cprMod(m, float64(ni)) + f)
+	if lon >= 180 {
+		lon -= 360
+	}
+	return lat, lon, true
+}
+
+// cprLocal decodes a single frame relative to a reference position
+// within half a zone, about 180 NM for airborne and 45 NM for surface
+// positions, which use zones a quarter of the size.
+func cprLocal(cpr [2]int, odd, surface bool, refLat, refLon float64) (lat, lon float64) {
+	span := 360.0
+	if surface {
+		span = 90
+	}
+	i := 0.0
+	if odd {
+		i = 1
+	}
+	fLat, fLon := float64(cpr[0])/cprScale, float64(cpr[1])/cprScale
+
+	dLat := span / (60 - i)
+	j := math.Floor(refLat/dLat) + math.Floor(0.5+cprMod(refLat, dLat)/dLat-fLat)
+	lat = dLat * (j + fLat)
+
+	n := float64(cprNL(lat)) - i
+	if n < 1 {
+		n = 1
+	}
+	dLon := span / n
+	m := math.Floor(refLon/dLon) + math.Floor(0.5+cprMod(refLon, dLon)/dLon-fLon)
+	lon = dLon * (m + fLon)
+	return lat, lon
+}
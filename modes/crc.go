@@ -0,0 +1,105 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package modes
+
+// The last 24 bits of every frame are parity, the CRC of the rest under
+// the generator 0xfff409. In DF11 replies the parity is XORed with the
+// interrogator code and in most others with the aircraft address, only
+// DF17 and DF18 carry it plain.
+const crcPoly = 0xfff409
+
+// Frame lengths in bits.
+const (
+	shortBits = 56
+	longBits  = 112
+)
+
+var crcTable [256]uint32
+
+func init() {
+	for i := range crcTable {
+		c := uint32(i) << 16
+		for j := 0; j < 8; j++ {
+			if c&0x800000 != 0 {
+				c = c<<1 ^ crcPoly
+			} else {
+				c <<= 1
+			}
+		}
+		crcTable[i] = c & 0xffffff
+	}
+	fixShort = fixTable(shortBits)
+	fixLong = fixTable(longBits)
+}
+
+// syndrome returns the CRC of the frame's data bits XORed with its
+// parity field.
+func syndrome(msg []byte) uint32 {
+	n := len(msg) - 3
+	var c uint32
+	for _, b := range msg[:n] {
+		c = (c<<8 ^ crcTable[byte(c>>16)^b]) & 0xffffff
+	}
+	return c ^ uint32(msg[n])<<16 ^ uint32(msg[n+1])<<8 ^ uint32(msg[n+2])
+}
+
+// errorFix is the bit positions of a correctable error.
+type errorFix struct {
+	n   int
+	bit [2]int
+}
+
+// fixShort and fixLong map the syndrome of each error of up to two bits
+// to the bits, leaving out the DF field since a wrong DF changes the
+// frame length.
+var fixShort, fixLong map[uint32]errorFix
+
+func fixTable(bits int) map[uint32]errorFix {
+	t := map[uint32]errorFix{}
+	msg := make([]byte, bits/8)
+	flip := func(i int) {
+		msg[i/8] ^= 0x80 >> uint(i%8)
+	}
+	bad := map[uint32]bool{}
+	add := func(s uint32, f errorFix) {
+		if _, ok := t[s]; ok {
+			// ambiguous, can't correct either
+			bad[s] = true
+		}
+		t[s] = f
+	}
+	for i := 5; i < bits; i++ {
+		flip(i)
+		add(syndrome(msg), errorFix{n: 1, bit: [2]int{i}})
+		for j := i + 1; j < bits; j++ {
+			flip(j)
+			add(syndrome(msg), errorFix{n: 2, bit: [2]int{i, j}})
+			flip(j)
+		}
+		flip(i)
+	}
+	for s := range bad {
+		delete(t, s)
+	}
+	return t
+}
+
+// fixErrors corrects up to max bits of msg given its syndrome, which
+// must be from an error free frame with zero parity overlay, and returns
+// the number of bits corrected, or -1.
+func fixErrors(msg []byte, s uint32, max int) int {
+	t := fixShort
+	if len(msg)*8 == longBits {
+		t = fixLong
+	}
+	f, ok := t[s]
+	if !ok || f.n > max {
+		return -1
+	}
+	for _, i := range f.bit[:f.n] {
+		msg[i/8] ^= 0x80 >> uint(i%8)
+	}
+	return f.n
+}
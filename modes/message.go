@@ -0,0 +1,345 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package modes
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Message is a received Mode S frame and the fields decoded from it.
+// The decoded fields are only meaningful when their Has flag is set.
+type Message struct {
+	Time      time.Time
+	Data      []byte // the frame, 7 or 14 bytes
+	DF        int    // downlink format
+	ICAO      uint32 // aircraft address
+	Corrected int    // bits fixed by error correction
+	SignalDb  float64
+
+	TypeCode int // extended squitter type code, DF17 and DF18
+
+	Callsign string
+
+	HasAltitude bool
+	Altitude    int // feet
+
+	Squawk string // Mode A code, e.g. "7700"
+
+	HasPosition bool
+	Lat, Lon    float64
+
+	HasVelocity  bool
+	GroundSpeed  float64 // knots
+	Track        float64 // degrees
+	HasVertical  bool
+	VerticalRate int // feet per minute
+
+	OnGround  bool
+	Alert     bool // Mode A code changed
+	SPI       bool // special position identification, "ident"
+	Emergency bool
+}
+
+// String returns a one line summary.
+func (m *Message) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "DF%d %06X", m.DF, m.ICAO)
+	if m.Callsign != "" {
+		fmt.Fprintf(&b, " %s", m.Callsign)
+	}
+	if m.HasAltitude {
+		fmt.Fprintf(&b, " %dft", m.Altitude)
+	}
+	if m.Squawk != "" {
+		fmt.Fprintf(&b, " sq %s", m.Squawk)
+	}
+	if m.HasPosition {
+		fmt.Fprintf(&b, " %.5f,%.5f", m.Lat, m.Lon)
+	}
+	if m.HasVelocity {
+		fmt.Fprintf(&b, " %.0fkt %.0f°", m.GroundSpeed, m.Track)
+	}
+	if m.HasVertical {
+		fmt.Fprintf(&b, " %+dfpm", m.VerticalRate)
+	}
+	return b.String()
+}
+
+// bits returns the n bits of msg starting at bit i, numbered from 0 at
+// the most significant bit of the first byte as in the standards.
+func bits(msg []byte, i, n int) uint32 {
+	var v uint32
+	for k := i; k < i+n; k++ {
+		v = v<<1 | uint32(msg[k/8]>>uint(7-k%8)&1)
+	}
+	return v
+}
+
+// frameBits returns the frame length of downlink format df, 0 for the
+// formats that aren't decoded.
+func frameBits(df int) int {
+	switch df {
+	case 0, 4, 5, 11:
+		return shortBits
+	case 16, 17, 18, 20, 21:
+		return longBits
+	}
+	return 0
+}
+
+const callsignChars = "#ABCDEFGHIJKLMNOPQRSTUVWXYZ##### ###############0123456789######"
+
+// gillham reorders a 13-bit identity or altitude field, bits
+// C1 A1 C2 A2 C4 A4 X B1 D1 B2 D2 B4 D4, into the octal digits of the
+// Mode A code, A4 A2 A1 B4 B2 B1 C4 C2 C1 D4 D2 D1 in 4 bit nibbles.
+func gillham(f uint32) uint32 {
+	var h uint32
+	for _, m := range [...][2]uint32{
+		{0x1000, 0x0010}, {0x0800, 0x1000}, {0x0400, 0x0020}, {0x0200, 0x2000},
+		{0x0100, 0x0040}, {0x0080, 0x4000}, {0x0020, 0x0100}, {0x0010, 0x0001},
+		{0x0008, 0x0200}, {0x0004, 0x0002}, {0x0002, 0x0400}, {0x0001, 0x0004},
+	} {
+		if f&m[0] != 0 {
+			h |= m[1]
+		}
+	}
+	return h
+}
+
+// modeC converts Gillham coded altitude in Mode A form to hundreds of
+// feet.
+func modeC(a uint32) (int, bool) {
+	// D1 is never used and one of the C bits must be set
+	if a&0xffff8889 != 0 || a&0xf0 == 0 {
+		return 0, false
+	}
+	var hundreds, fiveHundreds int
+	for _, m := range [...]struct {
+		bit uint32
+		x   int
+	}{{0x0010, 7}, {0x0020, 3}, {0x0040, 1}} {
+		if a&m.bit != 0 {
+			hundreds ^= m.x
+		}
+	}
+	// the hundreds count 1 2 3 4 5 in a reflected code, 7 stands for 5
+	if hundreds&5 == 5 {
+		hundreds ^= 2
+	}
+	if hundreds > 5 {
+		return 0, false
+	}
+	for _, m := range [...]struct {
+		bit uint32
+		x   int
+	}{
+		{0x0002, 0xff}, {0x0004, 0x7f}, {0x1000, 0x3f}, {0x2000, 0x1f},
+		{0x4000, 0x0f}, {0x0100, 0x07}, {0x0200, 0x03}, {0x0400, 0x01},
+	} {
+		if a&m.bit != 0 {
+			fiveHundreds ^= m.x
+		}
+	}
+	if fiveHundreds&1 != 0 {
+		hundreds = 6 - hundreds
+	}
+	return fiveHundreds*5 + hundreds - 13, true
+}
+
+// altitude13 decodes a 13-bit altitude field in feet.
+func altitude13(f uint32) (int, bool) {
+	if f == 0 {
+		return 0, false
+	}
+	if f&0x40 != 0 {
+		// M bit, metres
+		n := f>>1&0xfc0 | f&0x3f
+		return int(math.Floor(float64(n)*3.28084 + 0.5)), true
+	}
+	if f&0x10 != 0 {
+		// Q bit, 25 ft steps
+		n := f>>7<<5 | f>>5&1<<4 | f&0xf
+		return int(n)*25 - 1000, true
+	}
+	h, ok := modeC(gillham(f))
+	return h * 100, ok
+}
+
+// altitude12 decodes the 12-bit altitude of an extended squitter, the
+// 13-bit form without the M bit.
+func altitude12(f uint32) (int, bool) {
+	return altitude13(f&0xfc0<<1 | f&0x3f)
+}
+
+// squawk formats a 13-bit identity field.
+func squawk(f uint32) string {
+	return fmt.Sprintf("%04x", gillham(f))
+}
+
+// decode fills in the fields of m from its frame. Positions need the
+// tracker.
+func (d *Decoder) decode(m *Message) {
+	msg := m.Data
+	switch m.DF {
+	case 0, 16:
+		m.OnGround = bits(msg, 5, 1) != 0
+		m.Altitude, m.HasAltitude = altitude13(bits(msg, 19, 13))
+	case 4, 20:
+		d.flightStatus(m)
+		m.Altitude, m.HasAltitude = altitude13(bits(msg, 19, 13))
+	case 5, 21:
+		d.flightStatus(m)
+		m.Squawk = squawk(bits(msg, 19, 13))
+		m.Emergency = isEmergency(m.Squawk)
+	case 11:
+		m.OnGround = bits(msg, 5, 3) == 4
+	case 17, 18:
+		d.extended(m)
+	}
+	if m.DF == 20 || m.DF == 21 {
+		// Comm-B identification reply, BDS 2,0
+		if bits(msg, 32, 8) == 0x20 {
+			m.Callsign = callsign(msg[5:11])
+		}
+	}
+}
+
+func (d *Decoder) flightStatus(m *Message) {
+	fs := bits(m.Data, 5, 3)
+	m.OnGround = fs == 1 || fs == 3
+	m.Alert = fs >= 2 && fs <= 4
+	m.SPI = fs == 4 || fs == 5
+}
+
+func isEmergency(sq string) bool {
+	return sq == "7500" || sq == "7600" || sq == "7700"
+}
+
+// callsign decodes the 8 characters packed in 6 bytes.
+func callsign(b []byte) string {
+	var s [8]byte
+	for i := range s {
+		s[i] = callsignChars[bits(b, i*6, 6)]
+	}
+	return strings.TrimRight(string(s[:]), " ")
+}
+
+// extended decodes an extended squitter message, the 56-bit ME field
+// starting at bit 32.
+func (d *Decoder) extended(m *Message) {
+	msg := m.Data
+	me := msg[4:11]
+	tc := int(bits(me, 0, 5))
+	m.TypeCode = tc
+	switch {
+	case tc >= 1 && tc <= 4:
+		m.Callsign = callsign(me[1:7])
+
+	case tc >= 5 && tc <= 8:
+		m.OnGround = true
+		if mov := bits(me, 5, 7); mov > 0 && mov < 125 {
+			m.GroundSpeed, m.HasVelocity = groundMovement(mov), true
+		}
+		if bits(me, 12, 1) != 0 {
+			m.Track = float64(bits(me, 13, 7)) * 360 / 128
+		} else {
+			m.HasVelocity = false
+		}
+		d.position(m, me, true)
+
+	case tc >= 9 && tc <= 18 || tc >= 20 && tc <= 22:
+		alt := bits(me, 8, 12)
+		if tc <= 18 {
+			m.Altitude, m.HasAltitude = altitude12(alt)
+		} else if alt != 0 {
+			// GNSS height in metres
+			m.Altitude, m.HasAltitude = int(math.Floor(float64(alt)*3.28084+0.5)), true
+		}
+		d.position(m, me, false)
+
+	case tc == 19:
+		d.velocity(m, me)
+
+	case tc == 28:
+		if bits(me, 5, 3) == 1 {
+			m.Emergency = bits(me, 8, 3) != 0
+			m.Squawk = squawk(bits(me, 11, 13))
+		}
+	}
+}
+
+// groundMovement decodes the non-linear surface speed field in knots.
+func groundMovement(v uint32) float64 {
+	f := float64(v)
+	switch {
+	case v == 1:
+		return 0
+	case v <= 8:
+		return 0.125 + (f-2)*0.125
+	case v <= 12:
+		return 1 + (f-9)*0.25
+	case v <= 38:
+		return 2 + (f-13)*0.5
+	case v <= 93:
+		return 15 + (f - 39)
+	case v <= 108:
+		return 70 + (f-94)*2
+	case v <= 123:
+		return 100 + (f-109)*5
+	}
+	return 175
+}
+
+func (d *Decoder) velocity(m *Message, me []byte) {
+	st := bits(me, 5, 3)
+	switch st {
+	case 1, 2:
+		ew, ns := int(bits(me, 14, 10)), int(bits(me, 25, 10))
+		if ew == 0 || ns == 0 {
+			break
+		}
+		scale := 1.0
+		if st == 2 {
+			scale = 4 // supersonic
+		}
+		vx, vy := float64(ew-1)*scale, float64(ns-1)*scale
+		if bits(me, 13, 1) != 0 {
+			vx = -vx
+		}
+		if bits(me, 24, 1) != 0 {
+			vy = -vy
+		}
+		m.GroundSpeed = math.Hypot(vx, vy)
+		m.Track = math.Mod(math.Atan2(vx, vy)*180/math.Pi+360, 360)
+		m.HasVelocity = true
+	case 3, 4:
+		// airspeed and heading, reported as such in place of ground
+		// speed and track
+		if bits(me, 13, 1) == 0 {
+			break
+		}
+		as := int(bits(me, 25, 10))
+		if as == 0 {
+			break
+		}
+		scale := 1.0
+		if st == 4 {
+			scale = 4
+		}
+		m.GroundSpeed = float64(as-1) * scale
+		m.Track = float64(bits(me, 14, 10)) * 360 / 1024
+		m.HasVelocity = true
+	}
+	if vr := int(bits(me, 37, 9)); vr != 0 {
+		m.VerticalRate = (vr - 1) * 64
+		if bits(me, 36, 1) != 0 {
+			m.VerticalRate = -m.VerticalRate
+		}
+		m.HasVertical = true
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package modes decodes Mode S replies and ADS-B extended squitters on
+// 1090 MHz from magnitude samples at 2 MS/s or more, 2.4 MS/s working as
+// well as 2.
+//
+// Each sample position is checked for the four pulse preamble, the
+// timing refined to a fifth of a sample, and the pulse position bits
+// sliced by comparing the energy in each half bit. Frames are checked
+// by CRC, with single and double bit errors corrected in DF11, DF17 and
+// DF18 frames; the replies whose parity is overlaid with the aircraft
+// address are accepted for aircraft already seen. Positions are decoded
+// from pairs of even and odd frames, or from single frames near a known
+// position.
+package modes
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// Config holds the decoder settings.
+type Config struct {
+	SampleRate int // at least 2 MS/s
+
+	// FixBits is the most bit errors corrected in DF11, DF17 and DF18
+	// frames, 1 or 2, default 1, negative disables correction.
+	FixBits int
+
+	// Lat and Lon are the receiver position in degrees, when known. It
+	// allows single frame and surface positions to be decoded.
+	Lat, Lon float64
+
+	// OnMessage is called with each message decoded.
+	OnMessage func(Message)
+}
+
+// Frame timing and tracking limits.
+const (
+	preambleLen = 8                 // us
+	addrTTL     = 60 * time.Second  // how long an address stays known
+	pairTTL     = 10 * time.Second  // max age of the other CPR frame
+	posTTL      = 120 * time.Second // max age of a reference position
+	phases      = 5                 // preamble phases tried per sample
+)
+
+type cprFrame struct {
+	v       [2]int
+	t       time.Time
+	surface bool
+}
+
+// aircraft holds the state needed across messages.
+type aircraft struct {
+	seen     time.Time // last DF11/17/18
+	cpr      [2]cprFrame
+	lat, lon float64
+	posTime  time.Time
+}
+
+// Decoder finds and decodes Mode S frames.
+type Decoder struct {
+	cfg Config
+	spb float64 // samples per microsecond
+	fix int
+
+	buf   []float32 // magnitudes, the tail of the last block first
+	start time.Time // time of buf[0]
+
+	aircraft map[uint32]*aircraft
+	purged   time.Time
+}
+
+// NewDecoder returns a decoder for the given configuration.
+func NewDecoder(cfg Config) (*Decoder, error) {
+	if cfg.SampleRate < 2000000 {
+		return nil, errors.New("sample rate below 2 MS/s")
+	}
+	fix := cfg.FixBits
+	switch {
+	case fix == 0:
+		fix = 1
+	case fix < 0:
+		fix = 0
+	case fix > 2:
+		return nil, errors.New("at most 2 bit errors can be corrected")
+	}
+	return &Decoder{
+		cfg:      cfg,
+		spb:      float64(cfg.SampleRate) / 1e6,
+		fix:      fix,
+		aircraft: map[uint32]*aircraft{},
+	}, nil
+}
+
+// Magnitude computes the magnitude of each sample into dst.
+func Magnitude(dst []float32, src []complex64) []float32 {
+	if cap(dst) < len(src) {
+		dst = make([]float32, len(src))
+	}
+	dst = dst[:len(src)]
+	for i, v := range src {
+		dst[i] = float32(math.Sqrt(float64(real(v)*real(v) + imag(v)*imag(v))))
+	}
+	return dst
+}
+
+var cu8Mag [1 << 16]float32
+
+func init() {
+	for i := range cu8Mag {
+		re := (float64(i>>8) - 127.5) / 127.5
+		im := (float64(i&0xff) - 127.5) / 127.5
+		cu8Mag[i] = float32(math.Hypot(re, im))
+	}
+}
+
+// MagnitudeCU8 computes the magnitude of each sample of an unsigned
+// 8-bit interleaved buffer, as read from the device, into dst.
+func MagnitudeCU8(dst []float32, buf []byte) []float32 {
+	n := len(buf) / 2
+	if cap(dst) < n {
+		dst = make([]float32, n)
+	}
+	dst = dst[:n]
+	for i := range dst {
+		dst[i] = cu8Mag[int(buf[2*i])<<8|int(buf[2*i+1])]
+	}
+	return dst
+}
+
+// area returns the integral of m over samples [t0, t1), sample k
+// standing for the signal over [k, k+1).
+func area(m []float32, t0, t1 float64) float64 {
+	var s float64
+	for k := int(t0); float64(k) < t1; k++ {
+		lo := math.Max(t0, float64(k))
+		hi := math.Min(t1, float64(k+1))
+		s += float64(m[k]) * (hi - lo)
+	}
+	return s
+}
+
+// Process decodes the magnitude samples in mag, t being the time of the
+// first sample.
+func (d *Decoder) Process(mag []float32, t time.Time) {
+	fs := float64(d.cfg.SampleRate)
+	if len(d.buf) == 0 {
+		d.start = t
+	} else {
+		d.start = t.Add(-time.Duration(float64(len(d.buf)) / fs * 1e9))
+	}
+	d.buf = append(d.buf, mag...)
+	need := int(math.Ceil((preambleLen+longBits)*d.spb)) + 2
+
+	i := 0
+	for ; i+need <= len(d.buf); i++ {
+		if !d.preamble(i) {
+			continue
+		}
+		if m, ok := d.frame(i); ok {
+			m.Time = d.timeAt(float64(i))
+			d.decode(&m)
+			if d.cfg.OnMessage != nil {
+				d.cfg.OnMessage(m)
+			}
+			i += int(float64(preambleLen+len(m.Data)*8)*d.spb) - 1
+		}
+	}
+	d.buf = d.buf[:copy(d.buf, d.buf[i:])]
+
+	if t.Sub(d.purged) > addrTTL {
+		d.purged = t
+		for a, ac := range d.aircraft {
+			if t.Sub(ac.seen) > posTTL && t.Sub(ac.posTime) > posTTL {
+				delete(d.aircraft, a)
+			}
+		}
+	}
+}
+
+// at returns the sample covering t microseconds after sample i.
+func (d *Decoder) at(i int, t float64) float32 {
+	return d.buf[i+int(t*d.spb)]
+}
+
+// preamble is the quick check for pulses at 0, 1, 3.5 and 4.5 us
+// standing clear of the gaps between them.
+func (d *Decoder) preamble(i int) bool {
+	hi := d.at(i, 0.25)
+	for _, t := range [...]float64{1.25, 3.75, 4.75} {
+		if v := d.at(i, t); v < hi {
+			hi = v
+		}
+	}
+	var lo float32
+	for _, t := range [...]float64{2.25, 2.75, 5.75, 6.25, 6.75, 7.25} {
+		if v := d.at(i, t); v > lo {
+			lo = v
+		}
+	}
+	return hi > 1.5*lo
+}
+
+// frame slices and checks a frame whose preamble starts at sample i,
+// trying the timing phases in order of preamble fit until one needs no
+// correction.
+func (d *Decoder) frame(i int) (Message, bool) {
+	var score [phases]float64
+	var order [phases]int
+	for p := range score {
+		t0 := float64(i) + float64(p)/phases
+		h := d.spb / 2
+		var s float64
+		for _, us := range [...]float64{0, 1, 3.5, 4.5} {
+			s += area(d.buf, t0+us*d.spb, t0+us*d.spb+h)
+		}
+		for _, us := range [...]float64{0.5, 1.5, 2, 2.5, 3, 4, 5} {
+			s -= area(d.buf, t0+us*d.spb, t0+us*d.spb+h) * 4 / 7
+		}
+		score[p], order[p] = s, p
+	}
+	for a := 1; a < phases; a++ {
+		for b := a; b > 0 && score[order[b]] > score[order[b-1]]; b-- {
+			order[b], order[b-1] = order[b-1], order[b]
+		}
+	}
+	// a phase slicing without errors beats one that needed correcting
+	var best Message
+	found := false
+	for _, p := range order {
+		if score[p] <= 0 {
+			break
+		}
+		if m, ok := d.slice(float64(i) + float64(p)/phases); ok {
+			if m.Corrected == 0 {
+				return m, true
+			}
+			if !found {
+				best, found = m, true
+			}
+		}
+	}
+	return best, found
+}
+
+// overlap returns how much of sample j, covering [j, j+1), the pulse
+// [p0, p1) covers.
+func overlap(p0, p1 float64, j int) float64 {
+	lo := math.Max(p0, float64(j))
+	hi := math.Min(p1, float64(j+1))
+	if hi > lo {
+		return hi - lo
+	}
+	return 0
+}
+
+// slice demodulates and checks the frame at sample t0.
+//
+// At 2 MS/s a half bit pulse is a single sample wide, and unless it's
+// aligned with the samples it spreads into the neighbouring half bits.
+// So each bit is decided by which value best predicts the samples it
+// covers, given the decided previous bit and either value of the next,
+// scaled by the pulse amplitude measured on the preamble.
+func (d *Decoder) slice(t0 float64) (Message, bool) {
+	h := d.spb / 2
+	// the least squares fit of the preamble pulses to the samples they
+	// cover, a pulse straddling two samples being split between them
+	var num, den float64
+	for _, us := range [...]float64{0, 1, 3.5, 4.5} {
+		p0 := t0 + us*d.spb
+		for j := int(p0); float64(j) < p0+h; j++ {
+			o := overlap(p0, p0+h, j)
+			num += float64(d.buf[j]) * o
+			den += o * o
+		}
+	}
+	amp := num / den
+
+	// pulse returns the pulse of bit value v starting at t
+	pulse := func(t float64, v byte) (float64, float64) {
+		if v == 1 {
+			return t, t + h
+		}
+		return t + h, t + 2*h
+	}
+	prev := byte(1) // the preamble's quiet end looks like a 1
+	bit := func(k int) byte {
+		t := t0 + float64(preambleLen+k)*d.spb
+		pp0, pp1 := pulse(t-d.spb, prev)
+		var cost [2]float64
+		for v := byte(0); v < 2; v++ {
+			c0, c1 := pulse(t, v)
+			cost[v] = math.Inf(1)
+			for next := byte(0); next < 2; next++ {
+				n0, n1 := pulse(t+d.spb, next)
+				var c float64
+				for j := int(t); float64(j) < t+d.spb; j++ {
+					e := float64(d.buf[j]) - amp*(overlap(pp0, pp1, j)+overlap(c0, c1, j)+overlap(n0, n1, j))
+					c += e * e
+				}
+				cost[v] = math.Min(cost[v], c)
+			}
+		}
+		prev = 0
+		if cost[1] < cost[0] {
+			prev = 1
+		}
+		return prev
+	}
+	df := 0
+	for k := 0; k < 5; k++ {
+		df = df<<1 | int(bit(k))
+	}
+	n := frameBits(df)
+	if n == 0 {
+		return Message{}, false
+	}
+	msg := make([]byte, n/8)
+	msg[0] = byte(df << 3)
+	for k := 5; k < n; k++ {
+		msg[k/8] |= bit(k) << uint(7-k%8)
+	}
+
+	m := Message{Data: msg, DF: df}
+	s := syndrome(msg)
+	switch df {
+	case 17, 18:
+		if s != 0 {
+			if m.Corrected = fixErrors(msg, s, d.fix); m.Corrected < 0 {
+				return Message{}, false
+			}
+		}
+		m.ICAO = bits(msg, 8, 24)
+	case 11:
+		if s&^0x7f != 0 {
+			// the low 7 bits can be the interrogator code, correct
+			// assuming it's 0, i.e. a squitter
+			if m.Corrected = fixErrors(msg, s, d.fix); m.Corrected < 0 {
+				return Message{}, false
+			}
+		}
+		m.ICAO = bits(msg, 8, 24)
+	default:
+		// address parity, only believed for known aircraft
+		ac := d.aircraft[s]
+		if ac == nil || d.timeAt(t0).Sub(ac.seen) > addrTTL {
+			return Message{}, false
+		}
+		m.ICAO = s
+	}
+	if df == 17 || df == 18 || df == 11 && m.Corrected == 0 {
+		d.track(m.ICAO).seen = d.timeAt(t0)
+	}
+	m.SignalDb = iq.DB(amp * amp)
+	return m, true
+}
+
+// timeAt returns the time of sample t0.
+func (d *Decoder) timeAt(t0 float64) time.Time {
+	return d.start.Add(time.Duration(t0 / float64(d.cfg.SampleRate) * 1e9))
+}
+
+func (d *Decoder) track(icao uint32) *aircraft {
+	ac := d.aircraft[icao]
+	if ac == nil {
+		ac = &aircraft{}
+		d.aircraft[icao] = ac
+	}
+	return ac
+}
+
+// position decodes the CPR position of an extended squitter.
+func (d *Decoder) position(m *Message, me []byte, surface bool) {
+	ac := d.aircraft[m.ICAO]
+	if ac == nil {
+		return
+	}
+	odd := bits(me, 21, 1)
+	f := cprFrame{
+		v:       [2]int{int(bits(me, 22, 17)), int(bits(me, 39, 17))},
+		t:       m.Time,
+		surface: surface,
+	}
+	ac.cpr[odd] = f
+	other := ac.cpr[1-odd]
+
+	if !surface && !other.surface && !other.t.IsZero() && m.Time.Sub(other.t) <= pairTTL {
+		m.Lat, m.Lon, m.HasPosition = cprGlobal(ac.cpr[0].v, ac.cpr[1].v, odd == 1)
+	}
+	switch {
+	case m.HasPosition:
+	case !ac.posTime.IsZero() && m.Time.Sub(ac.posTime) <= posTTL:
+		m.Lat, m.Lon = cprLocal(f.v, odd == 1, surface, ac.lat, ac.lon)
+		m.HasPosition = true
+	case d.cfg.Lat != 0 || d.cfg.Lon != 0:
+		m.Lat, m.Lon = cprLocal(f.v, odd == 1, surface, d.cfg.Lat, d.cfg.Lon)
+		m.HasPosition = true
+	}
+	if m.HasPosition {
+		ac.lat, ac.lon, ac.posTime = m.Lat, m.Lon, m.Time
+	}
+}
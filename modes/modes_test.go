@@ -0,0 +1,261 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package modes
+
+import (
+	"encoding/hex"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// modulate returns the magnitudes at fs of the frames sent 400 us
+// apart, the first starting phase samples after 200 us, with the bits
+// in flip inverted. Each sample is the pulse energy over its period.
+func modulate(fs float64, frames [][]byte, phase float64, flip []int, r *rand.Rand) []float32 {
+	spu := fs / 1e6
+	sig := make([]float64, int(float64(len(frames)+1)*400*spu))
+	for i, f := range frames {
+		start := float64(200+400*i)*spu + phase
+		b := append([]byte(nil), f...)
+		for _, k := range flip {
+			b[k/8] ^= 0x80 >> uint(k%8)
+		}
+		pulses := []float64{0, 1, 3.5, 4.5}
+		for k := 0; k < len(b)*8; k++ {
+			// pulse position modulation, a 1 is high then low
+			t := float64(preambleLen + k)
+			if b[k/8]>>uint(7-k%8)&1 == 0 {
+				t += 0.5
+			}
+			pulses = append(pulses, t)
+		}
+		for _, p := range pulses {
+			t0, t1 := start+p*spu, start+(p+0.5)*spu
+			for k := int(t0); k <= int(t1); k++ {
+				if v := math.Min(t1, float64(k+1)) - math.Max(t0, float64(k)); v > 0 {
+					sig[k] += v
+				}
+			}
+		}
+	}
+	mag := make([]float32, len(sig))
+	for i, v := range sig {
+		re := 0.3*v + 0.01*r.NormFloat64()
+		im := 0.01 * r.NormFloat64()
+		mag[i] = float32(math.Hypot(re, im))
+	}
+	return mag
+}
+
+func frame(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	frames := [][]byte{
+		frame("8D4840D6202CC371C32CE0576098"), // identification
+		frame("8D40621D58C386435CC412692AD6"), // odd position
+		frame("8D40621D58C382D690C8AC2863A7"), // even position
+		frame("8D485020994409940838175B284F"), // velocity
+	}
+	check := func(t *testing.T, got []Message, errs int) {
+		if len(got) != len(frames) {
+			t.Fatalf("got %d messages, want %d", len(got), len(frames))
+		}
+		// another timing phase can slice a flipped bit right, so not
+		// every frame needs correcting
+		corrected := 0
+		for i, m := range got {
+			if m.DF != 17 || m.Corrected < 0 || m.Corrected > errs ||
+				hex.EncodeToString(m.Data) != hex.EncodeToString(frames[i]) {
+				t.Errorf("message %d: got DF%d %x, %d corrected", i, m.DF, m.Data, m.Corrected)
+			}
+			if m.Corrected == errs {
+				corrected++
+			}
+		}
+		if corrected == 0 {
+			t.Errorf("no message with %d bits corrected", errs)
+		}
+		if m := got[0]; m.ICAO != 0x4840d6 || m.Callsign != "KLM1023" {
+			t.Errorf("got %06X %q, want 4840D6 KLM1023", m.ICAO, m.Callsign)
+		}
+		// the even frame is the newer
+		if m := got[2]; !m.HasPosition || math.Abs(m.Lat-52.25720) > 1e-5 || math.Abs(m.Lon-3.91937) > 1e-5 ||
+			!m.HasAltitude || m.Altitude != 38000 {
+			t.Errorf("got %s, want 52.25720,3.91937 at 38000 ft", m.String())
+		}
+		if m := got[3]; !m.HasVelocity || math.Floor(m.GroundSpeed) != 159 || math.Abs(m.Track-182.88) > 0.01 ||
+			!m.HasVertical || m.VerticalRate != -832 {
+			t.Errorf("got %s, want 159 kt, 183°, -832 fpm", m.String())
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for _, fs := range []float64{2e6, 2.4e6} {
+		for _, c := range []struct {
+			fix  int
+			flip []int
+		}{
+			{1, nil},
+			{1, []int{45}},
+			{2, []int{20, 90}},
+		} {
+			// timing offsets across a sample
+			for phase := 0.0; phase < 1; phase += 0.125 {
+				var got []Message
+				d, err := NewDecoder(Config{SampleRate: int(fs), FixBits: c.fix, OnMessage: func(m Message) {
+					got = append(got, m)
+				}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				mag := modulate(fs, frames, phase, c.flip, r)
+				t0 := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+				for i := 0; i < len(mag); i += 5000 {
+					e := i + 5000
+					if e > len(mag) {
+						e = len(mag)
+					}
+					d.Process(mag[i:e], t0.Add(time.Duration(float64(i)/fs*1e9)))
+				}
+				t.Run("", func(t *testing.T) {
+					t.Logf("%v S/s, %v sample offset, bits %v flipped", fs, phase, c.flip)
+					check(t, got, len(c.flip))
+				})
+			}
+		}
+	}
+}
+
+func TestAltitude(t *testing.T) {
+	for _, c := range []struct {
+		f   uint32 // 13-bit altitude field
+		alt int
+		ok  bool
+	}{
+		{0x0000, 0, false},
+		// 25 ft steps
+		{0x1838, 38000, true},
+		// metres
+		{0x07e8, 3281, true},
+		// Gillham coded
+		{0x0400, -1000, true},
+		{0x1028, 1200, true},
+		{0x1422, 2100, true},
+		{0x0ca1, 35000, true},
+		{0x0105, 62800, true}, // D2 set
+		{0x0104, 126700, true},
+		{0x0800, 0, false}, // no C bit
+		{0x1500, 0, false}, // C1 C2 C4, not a hundreds code
+	} {
+		alt, ok := altitude13(c.f)
+		if ok != c.ok || ok && alt != c.alt {
+			t.Errorf("altitude13(%04x): got %d ft, %v, want %d ft, %v", c.f, alt, ok, c.alt, c.ok)
+		}
+	}
+}
+
+// TestAddressParity decodes replies with the parity overlaid with the
+// aircraft address, and an all-call squitter with a bit error.
+func TestAddressParity(t *testing.T) {
+	unknown := frame("20000CA1233B70") // DF4 from 123456, never seen
+	frames := [][]byte{
+		unknown,
+		frame("8D4840D6202CC371C32CE0576098"), // DF17 identification
+		frame("20000CA1794FF0"),               // DF4, Gillham coded 35000 ft
+		frame("A0001838202CC371C32CE0F15CF5"), // DF20, 38000 ft and BDS 2,0
+		frame("5D4840D6F8F40F"),               // DF11, bit 40 flipped
+		unknown,
+	}
+	var got []Message
+	d, err := NewDecoder(Config{SampleRate: 2400000, FixBits: 1, OnMessage: func(m Message) {
+		got = append(got, m)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Process(modulate(2.4e6, frames, 0, nil, rand.New(rand.NewSource(1))), time.Unix(1500000000, 0))
+
+	want := []struct {
+		df        int
+		alt       int
+		callsign  string
+		corrected int
+	}{
+		{17, 0, "KLM1023", 0},
+		{4, 35000, "", 0},
+		{20, 38000, "KLM1023", 0},
+		{11, 0, "", 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		m := got[i]
+		if m.DF != w.df || m.ICAO != 0x4840d6 || m.HasAltitude != (w.alt != 0) || m.Altitude != w.alt ||
+			m.Callsign != w.callsign || m.Corrected != w.corrected {
+			t.Errorf("message %d: got %s, %d corrected, want DF%d %d ft %q, %d corrected",
+				i, m.String(), m.Corrected, w.df, w.alt, w.callsign, w.corrected)
+		}
+	}
+	if m := got[3]; hex.EncodeToString(m.Data) != "5d4840d6f8740f" || m.OnGround {
+		t.Errorf("got %x, on ground %v, want 5d4840d6f8740f airborne", m.Data, m.OnGround)
+	}
+}
+
+func TestSBS(t *testing.T) {
+	tm := time.Date(2018, 1, 2, 3, 4, 5, 678e6, time.UTC)
+	const stamp = "2018/01/02,03:04:05.678,2018/01/02,03:04:05.678"
+	for _, c := range []struct {
+		m    Message
+		want string
+	}{
+		{Message{DF: 17, ICAO: 0x4840d6, TypeCode: 4, Callsign: "KLM1023"},
+			"MSG,1,1,1,4840D6,1," + stamp + ",KLM1023,,,,,,,,0,0,0,0"},
+		{Message{DF: 17, ICAO: 0x40621d, TypeCode: 11, HasAltitude: true, Altitude: 38000,
+			HasPosition: true, Lat: 52.257202, Lon: 3.919373},
+			"MSG,3,1,1,40621D,1," + stamp + ",,38000,,,52.25720,3.91937,,,0,0,0,0"},
+		{Message{DF: 17, ICAO: 0x485020, TypeCode: 19, HasVelocity: true, GroundSpeed: 159.2, Track: 182.88,
+			HasVertical: true, VerticalRate: -832},
+			"MSG,4,1,1,485020,1," + stamp + ",,,159,183,,,-832,,0,0,0,0"},
+		{Message{DF: 17, ICAO: 0x4840d6, TypeCode: 6, OnGround: true, HasPosition: true, Lat: 1, Lon: -2},
+			"MSG,2,1,1,4840D6,1," + stamp + ",,,,,1.00000,-2.00000,,,0,0,0,-1"},
+		{Message{DF: 4, ICAO: 0x4840d6, HasAltitude: true, Altitude: 35000},
+			"MSG,5,1,1,4840D6,1," + stamp + ",,35000,,,,,,,0,0,0,0"},
+		{Message{DF: 21, ICAO: 0xabc, Squawk: "7700", Emergency: true, Alert: true, SPI: true},
+			"MSG,6,1,1,000ABC,1," + stamp + ",,,,,,,,7700,-1,-1,-1,0"},
+		{Message{DF: 0, ICAO: 0x4840d6, HasAltitude: true, Altitude: -1000},
+			"MSG,7,1,1,4840D6,1," + stamp + ",,-1000,,,,,,,0,0,0,0"},
+		{Message{DF: 11, ICAO: 0x4840d6},
+			"MSG,8,1,1,4840D6,1," + stamp + ",,,,,,,,,0,0,0,0"},
+		// no BaseStation equivalent
+		{Message{DF: 17, ICAO: 0x4840d6, TypeCode: 31}, ""},
+		{Message{DF: 24, ICAO: 0x4840d6}, ""},
+	} {
+		c.m.Time = tm
+		if got := SBS(&c.m); got != c.want {
+			t.Errorf("DF%d TC%d:\ngot  %q\nwant %q", c.m.DF, c.m.TypeCode, got, c.want)
+		}
+	}
+
+	var b strings.Builder
+	w := SBSLines(&b)
+	for _, m := range []Message{{DF: 11, ICAO: 1, Time: tm}, {DF: 24, Time: tm}, {DF: 11, ICAO: 2, Time: tm}} {
+		w(m)
+	}
+	want := "MSG,8,1,1,000001,1," + stamp + ",,,,,,,,,0,0,0,0\r\n" +
+		"MSG,8,1,1,000002,1," + stamp + ",,,,,,,,,0,0,0,0\r\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package modes
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SBSPort is the TCP port BaseStation format feeds are served on.
+const SBSPort = 30003
+
+// sbsType returns the BaseStation transmission type of a message, 0 for
+// messages without one.
+func sbsType(m *Message) int {
+	switch m.DF {
+	case 17, 18:
+		switch tc := m.TypeCode; {
+		case tc >= 1 && tc <= 4:
+			return 1
+		case tc >= 5 && tc <= 8:
+			return 2
+		case tc >= 9 && tc <= 18 || tc >= 20 && tc <= 22:
+			return 3
+		case tc == 19:
+			return 4
+		}
+	case 4, 20:
+		return 5
+	case 5, 21:
+		return 6
+	case 0, 16:
+		return 7
+	case 11:
+		return 8
+	}
+	return 0
+}
+
+// SBS formats m as a line of the SBS-1 BaseStation text format, the
+// format dump1090 serves on port 30003, without the line ending. It
+// returns "" for messages that have no BaseStation equivalent.
+func SBS(m *Message) string {
+	typ := sbsType(m)
+	if typ == 0 {
+		return ""
+	}
+	date, tod := m.Time.Format("2006/01/02"), m.Time.Format("15:04:05.000")
+	f := make([]string, 22)
+	f[0], f[1], f[2], f[3] = "MSG", strconv.Itoa(typ), "1", "1"
+	f[4], f[5] = fmt.Sprintf("%06X", m.ICAO), "1"
+	f[6], f[7], f[8], f[9] = date, tod, date, tod
+	f[10] = m.Callsign
+	if m.HasAltitude {
+		f[11] = strconv.Itoa(m.Altitude)
+	}
+	if m.HasVelocity {
+		f[12] = strconv.Itoa(int(m.GroundSpeed + 0.5))
+		f[13] = strconv.Itoa(int(m.Track + 0.5))
+	}
+	if m.HasPosition {
+		f[14] = strconv.FormatFloat(m.Lat, 'f', 5, 64)
+		f[15] = strconv.FormatFloat(m.Lon, 'f', 5, 64)
+	}
+	if m.HasVertical {
+		f[16] = strconv.Itoa(m.VerticalRate)
+	}
+	f[17] = m.Squawk
+	for i, b := range [...]bool{m.Alert, m.Emergency, m.SPI, m.OnGround} {
+		// BaseStation flags are -1 for true
+		f[18+i] = "0"
+		if b {
+			f[18+i] = "-1"
+		}
+	}
+	return strings.Join(f, ",")
+}
+
+// SBSLines returns a message handler writing each message to w in SBS-1
+// format, one line per message.
+func SBSLines(w io.Writer) func(Message) {
+	return func(m Message) {
+		if s := SBS(&m); s != "" {
+			io.WriteString(w, s+"\r\n")
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package pocsag
+
+import "math/bits"
+
+// Codewords are 31 bits of BCH(31,21) code, generator
+// x^10+x^9+x^8+x^6+x^5+x^3+1, followed by an even parity bit. The code
+// corrects two bit errors, the parity bit a third's detection.
+
+const bchPoly = 0x769
+
+// bchSyndrome returns the remainder of the 31-bit code word w, bits 30
+// to 0, divided by the generator.
+func bchSyndrome(w uint32) uint32 {
+	for i := 30; i >= 10; i-- {
+		if w&(1<<uint(i)) != 0 {
+			w ^= bchPoly << uint(i-10)
+		}
+	}
+	return w
+}
+
+// bchFix maps the syndromes of single and double bit errors to the bits
+// in error.
+var bchFix = map[uint32]uint32{}
+
+func init() {
+	for i := uint(0); i < 31; i++ {
+		e := uint32(1) << i
+		bchFix[bchSyndrome(e)] = e
+		for j := i + 1; j < 31; j++ {
+			e2 := e | 1<<j
+			bchFix[bchSyndrome(e2)] = e2
+		}
+	}
+}
+
+// correct fixes up to two bit errors in the codeword cw, returning it,
+// the number of bits corrected, and false when there are more errors
+// than that.
+func correct(cw uint32) (uint32, int, bool) {
+	n := 0
+	if s := bchSyndrome(cw >> 1); s != 0 {
+		e, ok := bchFix[s]
+		if !ok {
+			return cw, 0, false
+		}
+		cw ^= e << 1
+		n = bits.OnesCount32(e)
+	}
+	if bits.OnesCount32(cw)&1 != 0 {
+		if n == 2 {
+			return cw, 0, false
+		}
+		// the parity bit itself
+		cw ^= 1
+		n++
+	}
+	return cw, n, true
+}
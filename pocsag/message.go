@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package pocsag
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// Message is a page. The function bits usually select the format,
+// numeric for 0 and alphanumeric for 3, but networks differ so the
+// message is decoded both ways.
+type Message struct {
+	Baud     int    `json:"baud"`
+	Capcode  uint32 `json:"capcode"` // address and frame, 21 bits
+	Function int    `json:"function"`
+	Numeric  string `json:"numeric,omitempty"`
+	Alpha    string `json:"alpha,omitempty"`
+	ToneOnly bool   `json:"tone_only,omitempty"` // an address without a message
+
+	Errors  int  `json:"errors"`            // bits corrected
+	Damaged bool `json:"damaged,omitempty"` // codewords were lost to errors
+}
+
+// Text returns the message in the format the function bits usually
+// select.
+func (m *Message) Text() string {
+	if m.Function == 0 {
+		return m.Numeric
+	}
+	return m.Alpha
+}
+
+// JSONLines returns a message handler writing each message to w as a
+// line of JSON. Errors writing to w are passed to onErr when it's set.
+func JSONLines(w io.Writer, onErr func(error)) func(Message) {
+	enc := json.NewEncoder(w)
+	return func(m Message) {
+		if err := enc.Encode(m); err != nil && onErr != nil {
+			onErr(err)
+		}
+	}
+}
+
+// bcdChars are the numeric characters; 0xa is unused.
+const bcdChars = "0123456789*U -]["
+
+// numeric decodes 4-bit characters, sent least significant bit first.
+func numeric(data []byte) string {
+	s := make([]byte, 0, len(data)/4)
+	for i := 0; i+4 <= len(data); i += 4 {
+		c := data[i] | data[i+1]<<1 | data[i+2]<<2 | data[i+3]<<3
+		s = append(s, bcdChars[c])
+	}
+	return strings.TrimRight(string(s), " ")
+}
+
+// alpha decodes 7-bit ASCII characters, sent least significant bit
+// first, dropping the end of text characters and padding.
+func alpha(data []byte) string {
+	s := make([]byte, 0, len(data)/7)
+	for i := 0; i+7 <= len(data); i += 7 {
+		var c byte
+		for k := 0; k < 7; k++ {
+			c |= data[i+k] << uint(k)
+		}
+		s = append(s, c)
+	}
+	return strings.TrimRight(string(s), "\x00\x03\x04\x17 ")
+}
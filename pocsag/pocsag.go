@@ -0,0 +1,278 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package pocsag decodes POCSAG paging at 512, 1200 and 2400 baud, from
+// FM discriminator audio, such as rtl_fm's, or from complex samples of
+// the channel at 0 Hz.
+//
+// The audio is integrated over a bit, the bits sliced at a slowly
+// tracked mean and clocked by a loop tracking their transitions, one
+// slicer for each speed. Each slicer hunts for the sync codeword, or
+// its inverse as the FSK polarity isn't fixed, then takes the batch of
+// 16 codewords following it, correcting up to two bit errors in each.
+// A page is an address codeword followed by message codewords, ended by
+// the next address, an idle codeword or the end of the transmission.
+package pocsag
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+
+	"github.com/jpoirier/gortlsdr/demod"
+	"github.com/jpoirier/gortlsdr/dsp"
+)
+
+// Bauds are the POCSAG speeds.
+var Bauds = []int{512, 1200, 2400}
+
+// Codewords and batch structure.
+const (
+	syncWord = 0x7cd215d8
+	idleWord = 0x7a89c197
+
+	batchWords = 16 // codewords after the sync word, 2 per frame
+	syncErrors = 2  // bits that may be wrong in the sync word when hunting
+	nextErrors = 4  // and in the sync word starting the next batch
+
+	deviation = 4500 // Hz
+	iqRate    = 24e3 // rate complex samples are demodulated at
+	iqPass    = 6500 // one sided channel bandwidth
+	iqStop    = 9000
+
+	clockGain = 0.1 // share of the timing error corrected per transition
+)
+
+// Config holds the decoder settings.
+type Config struct {
+	// SampleRate is the rate of the audio passed to Process, or with
+	// IQ set the complex samples passed to ProcessIQ.
+	SampleRate float64
+	IQ         bool
+
+	// Baud selects 512, 1200 or 2400 baud, 0 decodes all three.
+	Baud int
+
+	// OnMessage is called with each page decoded, see JSONLines.
+	OnMessage func(Message)
+}
+
+// Decoder decodes POCSAG pages.
+type Decoder struct {
+	cfg     Config
+	slicers []*slicer
+
+	// complex front end
+	dec  *dsp.Decimator
+	lp   *dsp.FIRFilter
+	quad *demod.Quadrature
+	bb   []complex64
+	fm   []float32
+}
+
+// NewDecoder returns a decoder for the given configuration.
+func NewDecoder(cfg Config) (*Decoder, error) {
+	bauds := Bauds
+	if cfg.Baud != 0 {
+		bauds = []int{cfg.Baud}
+		if cfg.Baud != 512 && cfg.Baud != 1200 && cfg.Baud != 2400 {
+			return nil, errors.New("baud rate not 512, 1200 or 2400")
+		}
+	}
+	d := &Decoder{cfg: cfg}
+	fs := cfg.SampleRate
+	if cfg.IQ {
+		if fs < iqRate {
+			return nil, errors.New("sample rate too low for POCSAG")
+		}
+		dec, err := dsp.NewDecimator(dsp.DecimatorConfig{
+			InputRate:  fs,
+			OutputRate: iqRate,
+			Passband:   iqStop,
+		})
+		if err != nil {
+			return nil, err
+		}
+		fs = dec.OutputRate()
+		d.dec = dec
+		d.lp = dsp.NewFIRFilter(dsp.LowpassKaiser(iqPass/fs, iqStop/fs, 50))
+		d.quad = demod.NewQuadrature(fs, deviation)
+	}
+	for _, b := range bauds {
+		if fs < 4*float64(b) {
+			return nil, errors.New("sample rate too low for the baud rate")
+		}
+		d.slicers = append(d.slicers, newSlicer(fs, b, d.emit))
+	}
+	return d, nil
+}
+
+func (d *Decoder) emit(m Message) {
+	if d.cfg.OnMessage != nil {
+		d.cfg.OnMessage(m)
+	}
+}
+
+// Process decodes FM discriminator audio.
+func (d *Decoder) Process(audio []float32) {
+	for _, s := range d.slicers {
+		for _, v := range audio {
+			s.sample(float64(v))
+		}
+	}
+}
+
+// ProcessIQ decodes complex samples, for a decoder configured with IQ
+// set.
+func (d *Decoder) ProcessIQ(x []complex64) {
+	d.bb = d.dec.Process(d.bb, x)
+	d.bb = d.lp.Process(d.bb, d.bb)
+	d.fm = d.quad.Process(d.fm, d.bb)
+	d.Process(d.fm)
+}
+
+// slicer recovers the bits at one baud rate and decodes the batches.
+type slicer struct {
+	baud int
+	step float64 // bits per sample
+	emit func(Message)
+
+	box  []float64 // the last bit's samples
+	k    int
+	sum  float64
+	dc   float64
+	dca  float64
+	clk  float64 // bit clock phase, bits are taken as it wraps
+	last bool
+
+	reg    uint32 // the last 32 bits
+	batch  int    // codewords to go in the batch, -1 when hunting
+	nbits  int    // bits into the codeword
+	invert bool
+
+	msg  *Message
+	data []byte // message bits, one per byte
+}
+
+func newSlicer(fs float64, baud int, emit func(Message)) *slicer {
+	n := int(math.Floor(fs/float64(baud) + 0.5))
+	return &slicer{
+		baud:  baud,
+		step:  float64(baud) / fs,
+		emit:  emit,
+		box:   make([]float64, n),
+		dca:   1.0 / 64,
+		batch: -1,
+	}
+}
+
+// sample integrates one sample and slices a bit when the clock wraps.
+func (s *slicer) sample(v float64) {
+	s.sum += v - s.box[s.k]
+	s.box[s.k] = v
+	s.k = (s.k + 1) % len(s.box)
+	y := s.sum / float64(len(s.box))
+
+	high := y > s.dc
+	if high != s.last {
+		// the integrated bits cross half way through
+		s.clk -= clockGain * (s.clk - 0.5)
+		s.last = high
+	}
+	if s.clk += s.step; s.clk < 1 {
+		return
+	}
+	s.clk--
+	s.dc += s.dca * (y - s.dc)
+
+	// FSK 1s are the lower frequency
+	var b uint32
+	if !high {
+		b = 1
+	}
+	s.bit(b)
+}
+
+// bit takes one bit, hunting for the sync word or filling codewords.
+func (s *slicer) bit(b uint32) {
+	s.reg = s.reg<<1 | b
+	if s.batch < 0 {
+		switch {
+		case bits.OnesCount32(s.reg^syncWord) <= syncErrors:
+			s.invert = false
+		case bits.OnesCount32(^s.reg^syncWord) <= syncErrors:
+			s.invert = true
+		default:
+			return
+		}
+		s.batch, s.nbits = batchWords, 0
+		return
+	}
+	if s.nbits++; s.nbits < 32 {
+		return
+	}
+	s.nbits = 0
+	cw := s.reg
+	if s.invert {
+		cw = ^cw
+	}
+	if s.batch == 0 {
+		// the next batch's sync word, or the end of the transmission
+		if bits.OnesCount32(cw^syncWord) <= nextErrors {
+			s.batch = batchWords
+		} else {
+			s.flush()
+			s.batch = -1
+		}
+		return
+	}
+	s.word(cw, batchWords-s.batch)
+	s.batch--
+}
+
+// word decodes codeword cw, the i'th in its batch.
+func (s *slicer) word(cw uint32, i int) {
+	cw, n, ok := correct(cw)
+	if !ok {
+		if s.msg != nil {
+			s.msg.Damaged = true
+		}
+		return
+	}
+	switch {
+	case cw == idleWord:
+		s.flush()
+	case cw>>31 == 0:
+		// address, its low 3 bits given by the frame
+		s.flush()
+		s.msg = &Message{
+			Baud:     s.baud,
+			Capcode:  cw>>13<<3 | uint32(i/2),
+			Function: int(cw >> 11 & 3),
+			Errors:   n,
+		}
+		s.data = s.data[:0]
+	case s.msg != nil:
+		for k := uint(30); k >= 11; k-- {
+			s.data = append(s.data, byte(cw>>k&1))
+		}
+		s.msg.Errors += n
+	}
+}
+
+// flush emits the page in progress.
+func (s *slicer) flush() {
+	m := s.msg
+	if m == nil {
+		return
+	}
+	s.msg = nil
+	if len(s.data) == 0 {
+		m.ToneOnly = true
+	} else {
+		m.Numeric = numeric(s.data)
+		m.Alpha = alpha(s.data)
+	}
+	s.emit(*m)
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package pocsag
+
+import (
+	"bytes"
+	"math"
+	"math/bits"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// encode returns the codeword of the 21 data bits d.
+func encode(d uint32) uint32 {
+	cw := d<<10 | bchSyndrome(d<<10)
+	cw <<= 1
+	if bits.OnesCount32(cw)&1 != 0 {
+		cw |= 1
+	}
+	return cw
+}
+
+func TestCorrect(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range []struct {
+		name string
+		errs int
+		bch  bool // errors only in the BCH code, not the parity bit
+		n    int
+		ok   bool
+	}{
+		{"none", 0, false, 0, true},
+		{"one", 1, true, 1, true},
+		{"parity", 1, false, 1, true},
+		{"two", 2, true, 2, true},
+		{"one and parity", 2, false, 2, true},
+		{"three", 3, true, 0, false},
+		{"two and parity", 3, false, 0, false},
+	} {
+		for trial := 0; trial < 200; trial++ {
+			cw := encode(uint32(r.Intn(1 << 21)))
+			var e uint32
+			if c.bch {
+				for _, k := range r.Perm(31)[:c.errs] {
+					e |= 2 << uint(k)
+				}
+			} else if c.errs > 0 {
+				e = 1
+				for _, k := range r.Perm(31)[:c.errs-1] {
+					e |= 2 << uint(k)
+				}
+			}
+			got, n, ok := correct(cw ^ e)
+			if ok != c.ok || ok && (got != cw || n != c.n) {
+				t.Fatalf("%s: corrected %08x to %08x, %d bits, %v, want %08x, %d bits, %v",
+					c.name, cw^e, got, n, ok, cw, c.n, c.ok)
+			}
+		}
+	}
+}
+
+// charBits returns the characters of s as w-bit values from table,
+// least significant bit first, one bit per byte.
+func charBits(s string, w uint, table func(byte) byte) []byte {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := table(s[i])
+		for k := uint(0); k < w; k++ {
+			b = append(b, c>>k&1)
+		}
+	}
+	return b
+}
+
+func bcd(c byte) byte { return byte(strings.IndexByte(bcdChars, c)) }
+
+func ascii(c byte) byte { return c }
+
+func TestText(t *testing.T) {
+	for _, c := range []struct {
+		data []byte
+		want string
+		dec  func([]byte) string
+	}{
+		{charBits("0123456789*U -][", 4, bcd), "0123456789*U -][", numeric},
+		{charBits("555 1234    ", 4, bcd), "555 1234", numeric},
+		// a partial character is dropped
+		{append(charBits("42", 4, bcd), 1, 0, 0), "42", numeric},
+		{charBits("Hello, world!", 7, ascii), "Hello, world!", alpha},
+		{charBits("CALL 911\x04\x00\x00", 7, ascii), "CALL 911", alpha},
+		{charBits("ETX\x03\x17", 7, ascii), "ETX", alpha},
+		{append(charBits("ok", 7, ascii), 1, 1, 1), "ok", alpha},
+	} {
+		if got := c.dec(c.data); got != c.want {
+			t.Errorf("got %q, want %q", got, c.want)
+		}
+	}
+}
+
+type page struct {
+	Message
+	flip []int // bits flipped, counting from the address codeword's first
+}
+
+// transmission returns the bits sent for the pages: the preamble then
+// batches of a sync word and 16 codewords, each page's address
+// codeword in the frame its capcode selects.
+func transmission(pages []page) []byte {
+	var words []uint32
+	for _, p := range pages {
+		for len(words)%batchWords != int(p.Capcode&7)*2 {
+			words = append(words, idleWord)
+		}
+		first := len(words)
+		words = append(words, encode(p.Capcode>>3<<2|uint32(p.Function)))
+
+		var data []byte
+		switch {
+		case p.ToneOnly:
+		case p.Function == 0:
+			data = charBits(p.Numeric, 4, bcd)
+			for len(data)%20 != 0 {
+				data = append(data, charBits(" ", 4, bcd)...)
+			}
+		default:
+			data = charBits(p.Alpha+"\x04", 7, ascii)
+			for len(data)%20 != 0 {
+				data = append(data, 0)
+			}
+		}
+		for i := 0; i < len(data); i += 20 {
+			d := uint32(1) << 20
+			for k := 0; k < 20; k++ {
+				d |= uint32(data[i+k]) << uint(19-k)
+			}
+			words = append(words, encode(d))
+		}
+		for _, k := range p.flip {
+			words[first+k/32] ^= 1 << uint(31-k%32)
+		}
+	}
+	words = append(words, idleWord)
+	for len(words)%batchWords != 0 {
+		words = append(words, idleWord)
+	}
+
+	var b []byte
+	for i := 0; i < 576; i++ {
+		b = append(b, byte(1-i%2))
+	}
+	for i, w := range words {
+		if i%batchWords == 0 {
+			for k := 31; k >= 0; k-- {
+				b = append(b, byte(uint32(syncWord)>>uint(k)&1))
+			}
+		}
+		for k := 31; k >= 0; k-- {
+			b = append(b, byte(w>>uint(k)&1))
+		}
+	}
+	return b
+}
+
+var testPages = []page{
+	{Message{Capcode: 1234567, Function: 3, Alpha: "Hello world, this is a POCSAG test page", Errors: 3}, []int{3, 40, 41}},
+	{Message{Capcode: 8, Function: 0, Numeric: "0123 456-789", Errors: 1}, []int{63}},
+	{Message{Capcode: 2000003, Function: 2, ToneOnly: true}, nil},
+	{Message{Capcode: 77775, Function: 3, Alpha: "A second page running on into the next batch of sixteen codewords"}, nil},
+}
+
+// modulate returns the bits at baud as discriminator audio at fs, with
+// a DC offset and noise, or as complex FSK 300 Hz off the channel. The
+// transmitter's clock is 300 ppm fast.
+func modulate(b []byte, baud int, fs float64, iq, invert bool, noise float64, r *rand.Rand) ([]float32, []complex64) {
+	sps := fs / float64(baud)
+	n := int(float64(len(b)+100) * sps)
+	audio := make([]float32, n)
+	x := make([]complex64, n)
+	var phase float64
+	for i := range audio {
+		var v float64
+		if k := int(float64(i)/sps*1.0003) - 50; k >= 0 && k < len(b) {
+			// 1s are the lower frequency
+			v = 1 - 2*float64(b[k])
+		}
+		if invert {
+			v = -v
+		}
+		if !iq {
+			audio[i] = float32(v + 0.1 + noise*r.NormFloat64())
+			continue
+		}
+		phase += 2 * math.Pi * (deviation*v + 300) / fs
+		x[i] = complex(float32(math.Cos(phase)+noise*r.NormFloat64()), float32(math.Sin(phase)+noise*r.NormFloat64()))
+	}
+	return audio, x
+}
+
+func TestDecode(t *testing.T) {
+	b := transmission(testPages)
+	r := rand.New(rand.NewSource(1))
+	for _, baud := range Bauds {
+		for _, c := range []struct {
+			fs     float64
+			iq     bool
+			invert bool
+			noise  float64
+		}{
+			{22050, false, false, 0.3},
+			{48000, false, true, 0.5},
+			{240000, true, false, 0.5},
+			{240000, true, true, 0.3},
+		} {
+			var got []Message
+			var lines bytes.Buffer
+			jl := JSONLines(&lines, func(err error) { t.Error(err) })
+			d, err := NewDecoder(Config{SampleRate: c.fs, IQ: c.iq, OnMessage: func(m Message) {
+				got = append(got, m)
+				jl(m)
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			audio, x := modulate(b, baud, c.fs, c.iq, c.invert, c.noise, r)
+			for i := 0; i < len(audio); i += 16384 {
+				e := i + 16384
+				if e > len(audio) {
+					e = len(audio)
+				}
+				if c.iq {
+					d.ProcessIQ(x[i:e])
+				} else {
+					d.Process(audio[i:e])
+				}
+			}
+
+			if len(got) != len(testPages) {
+				t.Errorf("%d baud %+v: got %d pages, want %d", baud, c, len(got), len(testPages))
+				continue
+			}
+			for i, p := range testPages {
+				m, want := got[i], p.Message
+				want.Baud = baud
+				if m.Baud != want.Baud || m.Capcode != want.Capcode || m.Function != want.Function ||
+					m.Text() != want.Text() || m.ToneOnly != want.ToneOnly || m.Errors != want.Errors || m.Damaged {
+					t.Errorf("%d baud %+v: got %+v, want %+v", baud, c, m, want)
+				}
+			}
+			want := `{"baud":` + strconv.Itoa(baud) + `,"capcode":2000003,"function":2,"tone_only":true,"errors":0}`
+			if !strings.Contains(lines.String(), want+"\n") {
+				t.Errorf("%d baud %+v: missing JSON line %s", baud, c, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package rds
+
+// Each 26-bit block is 16 information bits followed by a 10-bit check
+// word, the remainder of the information times x^10 divided by
+// x^10+x^8+x^7+x^5+x^4+x^3+1, plus an offset word naming the block's
+// position in the group.
+const (
+	poly      = 0x5b9
+	blockBits = 26
+)
+
+// Block positions within a group.
+const (
+	blockA = iota
+	blockB
+	blockC
+	blockD
+)
+
+// Offset words, C' replaces C in version B groups.
+const (
+	offsetA      = 0x0fc
+	offsetB      = 0x198
+	offsetC      = 0x168
+	offsetCPrime = 0x350
+	offsetD      = 0x1b4
+)
+
+var offsets = [...]uint16{offsetA, offsetB, offsetC, offsetD}
+
+// syndrome returns the check word computed from a block's information
+// bits XORed with its received check word. For an error free block
+// it's the block's offset word.
+func syndrome(block uint32) uint16 {
+	return uint16(crc(block>>10&0xffff) ^ block&0x3ff)
+}
+
+// crc returns the 10-bit check word of 16 information bits.
+func crc(info uint32) uint32 {
+	reg := info << 10
+	for i := 25; i >= 10; i-- {
+		if reg&(1<<uint(i)) != 0 {
+			reg ^= poly << uint(i-10)
+		}
+	}
+	return reg & 0x3ff
+}
+
+// bursts maps the syndrome of each error burst of up to two bits to the
+// burst. The code can correct longer bursts but the risk of
+// miscorrection grows with them.
+var bursts = map[uint16]uint32{}
+
+func init() {
+	for i := uint(0); i < blockBits; i++ {
+		bursts[syndrome(1<<i)] = 1 << i
+		if i+1 < blockBits {
+			bursts[syndrome(3<<i)] = 3 << i
+		}
+	}
+}
+
+// correct checks block against offset, fixing a short error burst when
+// possible, and returns the information bits.
+func correct(block uint32, offset uint16) (uint16, bool) {
+	s := syndrome(block) ^ offset
+	if s == 0 {
+		return uint16(block >> 10), true
+	}
+	e, ok := bursts[s]
+	if !ok {
+		return 0, false
+	}
+	return uint16((block ^ e) >> 10), true
+}
+
+// position returns the block position matching an error free block,
+// and whether it's a C' block.
+func position(block uint32) (pos int, cprime, ok bool) {
+	s := syndrome(block)
+	for p, o := range offsets {
+		if s == o {
+			return p, false, true
+		}
+	}
+	if s == offsetCPrime {
+		return blockC, true, true
+	}
+	return 0, false, false
+}
+
+// maxBadBlocks is the number of consecutive uncorrectable blocks after
+// which synchronisation is considered lost.
+const maxBadBlocks = 10
+
+// candidate is a bit position where a valid block was seen while
+// searching for synchronisation.
+type candidate struct {
+	bit int64
+	pos int
+}
+
+// syncer finds the block boundaries in the bit stream and assembles
+// groups.
+type syncer struct {
+	reg    uint32
+	bits   int64
+	synced bool
+	next   int64 // bit count at which the next block completes
+	pos    int   // position of the next block
+	bad    int
+	cands  []candidate
+
+	group  [4]uint16
+	valid  [4]bool
+	cprime bool
+
+	onGroup func(blocks [4]uint16, valid [4]bool, versionB bool)
+	onSync  func(bool)
+}
+
+// bit shifts in one data bit.
+func (s *syncer) bit(b uint8) {
+	s.reg = (s.reg<<1 | uint32(b)) & (1<<blockBits - 1)
+	s.bits++
+	if !s.synced {
+		s.search()
+		return
+	}
+	if s.bits < s.next {
+		return
+	}
+	s.next += blockBits
+	s.block()
+}
+
+// search looks for two valid blocks a whole number of blocks apart and
+// in the right order.
+func (s *syncer) search() {
+	if s.bits < blockBits {
+		return
+	}
+	pos, cprime, ok := position(s.reg)
+	if !ok {
+		return
+	}
+	for _, c := range s.cands {
+		d := s.bits - c.bit
+		if d > 0 && d%blockBits == 0 && d/blockBits < 4 && int(d/blockBits) == (pos-c.pos+4)%4 {
+			s.synced = true
+			s.bad = 0
+			s.next = s.bits + blockBits
+			s.pos = pos
+			s.valid = [4]bool{}
+			s.accept(uint16(s.reg>>10), cprime)
+			if s.onSync != nil {
+				s.onSync(true)
+			}
+			s.cands = s.cands[:0]
+			return
+		}
+	}
+	// keep only candidates that could still pair up
+	keep := s.cands[:0]
+	for _, c := range s.cands {
+		if s.bits-c.bit < 4*blockBits {
+			keep = append(keep, c)
+		}
+	}
+	s.cands = append(keep, candidate{bit: s.bits, pos: pos})
+}
+
+// block checks a block at a known boundary.
+func (s *syncer) block() {
+	off := offsets[s.pos]
+	cprime := s.pos == blockC && syndrome(s.reg) == offsetCPrime
+	if cprime {
+		off = offsetCPrime
+	}
+	info, ok := correct(s.reg, off)
+	if !ok && s.pos == blockC {
+		info, ok = correct(s.reg, offsetCPrime)
+		cprime = ok
+	}
+	if !ok {
+		s.valid[s.pos] = false
+		s.advance()
+		if s.bad++; s.bad >= maxBadBlocks {
+			s.synced = false
+			if s.onSync != nil {
+				s.onSync(false)
+			}
+		}
+		return
+	}
+	s.bad = 0
+	s.accept(info, cprime)
+}
+
+// accept stores a good block and completes the group after block D.
+func (s *syncer) accept(info uint16, cprime bool) {
+	s.group[s.pos] = info
+	s.valid[s.pos] = true
+	if s.pos == blockC {
+		s.cprime = cprime
+	}
+	s.advance()
+}
+
+func (s *syncer) advance() {
+	if s.pos == blockD {
+		if s.onGroup != nil {
+			s.onGroup(s.group, s.valid, s.cprime)
+		}
+		s.valid = [4]bool{}
+		s.cprime = false
+	}
+	s.pos = (s.pos + 1) % 4
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package rds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is one decoded group. Fields that the group doesn't carry are
+// left empty; PS and RadioText are only set once the whole text has
+// been received.
+type Event struct {
+	PI        string     `json:"pi"`    // program identification, e.g. "0xC201"
+	Group     string     `json:"group"` // group type, e.g. "0A"
+	TP        bool       `json:"tp"`
+	PTY       int        `json:"pty"`
+	PTYName   string     `json:"prog_type,omitempty"`
+	TA        *bool      `json:"ta,omitempty"`
+	PS        string     `json:"ps,omitempty"`
+	RadioText string     `json:"radiotext,omitempty"`
+	ClockTime *time.Time `json:"clock_time,omitempty"`
+	AltFreqs  []float64  `json:"alt_freqs,omitempty"` // MHz
+}
+
+// JSONLines returns an event handler writing each event to w as a line
+// of JSON. Errors writing to w are passed to onErr when it's set.
+func JSONLines(w io.Writer, onErr func(error)) func(Event) {
+	enc := json.NewEncoder(w)
+	return func(e Event) {
+		if err := enc.Encode(e); err != nil && onErr != nil {
+			onErr(err)
+		}
+	}
+}
+
+// Station is the station information gathered so far.
+type Station struct {
+	PI        uint16
+	PTY       int
+	TP, TA    bool
+	PS        string
+	RadioText string
+	AltFreqs  []float64 // MHz
+}
+
+// station accumulates the multi-group fields.
+type station struct {
+	pi     uint16
+	pty    int
+	tp, ta bool
+	ps     [8]byte
+	psMask uint8
+	rt     [64]byte
+	rtMask uint16
+	rtAB   uint16
+	rtEnd  int
+	afs    map[int]bool
+}
+
+// group parses one group into an event, returning false when the
+// blocks needed are missing.
+func (d *Decoder) group(b [4]uint16, valid [4]bool, versionB bool) (Event, bool) {
+	if !valid[blockA] || !valid[blockB] {
+		return Event{}, false
+	}
+	s := &d.st
+	typ := int(b[blockB] >> 12)
+	vb := b[blockB]>>11&1 != 0
+	if vb != versionB && valid[blockC] {
+		// block C and the B0 flag disagree
+		return Event{}, false
+	}
+	s.pi = b[blockA]
+	s.tp = b[blockB]>>10&1 != 0
+	s.pty = int(b[blockB] >> 5 & 0x1f)
+	ver := "A"
+	if vb {
+		ver = "B"
+	}
+	e := Event{
+		PI:      fmt.Sprintf("0x%04X", s.pi),
+		Group:   fmt.Sprintf("%d%s", typ, ver),
+		TP:      s.tp,
+		PTY:     s.pty,
+		PTYName: d.ptyName(s.pty),
+	}
+
+	switch {
+	case typ == 0:
+		s.ta = b[blockB]>>4&1 != 0
+		ta := s.ta
+		e.TA = &ta
+		if valid[blockD] {
+			i := int(b[blockB]&3) * 2
+			s.ps[i], s.ps[i+1] = byte(b[blockD]>>8), byte(b[blockD])
+			s.psMask |= 1 << uint(b[blockB]&3)
+		}
+		if !vb && valid[blockC] {
+			s.addAF(int(b[blockC] >> 8))
+			s.addAF(int(b[blockC] & 0xff))
+		}
+		if s.psMask == 0xf {
+			e.PS = decodeText(s.ps[:])
+		}
+		e.AltFreqs = s.altFreqs()
+
+	case typ == 2:
+		ab := b[blockB] >> 4 & 1
+		if ab != s.rtAB {
+			// the A/B flag toggles when a new text starts
+			s.rtAB, s.rtMask, s.rtEnd = ab, 0, 0
+			for i := range s.rt {
+				s.rt[i] = ' '
+			}
+		}
+		addr := int(b[blockB] & 0xf)
+		var chars []byte
+		var at int
+		switch {
+		case !vb && valid[blockC] && valid[blockD]:
+			at = addr * 4
+			chars = []byte{byte(b[blockC] >> 8), byte(b[blockC]), byte(b[blockD] >> 8), byte(b[blockD])}
+		case vb && valid[blockD]:
+			at = addr * 2
+			chars = []byte{byte(b[blockD] >> 8), byte(b[blockD])}
+		}
+		if chars != nil {
+			copy(s.rt[at:], chars)
+			s.rtMask |= 1 << uint(addr)
+			for i, c := range chars {
+				if c == '\r' && (s.rtEnd == 0 || at+i < s.rtEnd) {
+					s.rtEnd = at + i
+				}
+			}
+		}
+		size, seg := 64, 4
+		if vb {
+			size, seg = 32, 2
+		}
+		if s.rtEnd > 0 {
+			size = s.rtEnd
+		}
+		need := uint16(1)<<uint((size+seg-1)/seg) - 1
+		if s.rtMask&need == need {
+			e.RadioText = strings.TrimRight(decodeText(s.rt[:size]), " ")
+		}
+
+	case typ == 4 && !vb && valid[blockC] && valid[blockD]:
+		mjd := int(b[blockB]&3)<<15 | int(b[blockC]>>1)
+		hour := int(b[blockC]&1)<<4 | int(b[blockD]>>12)
+		min := int(b[blockD] >> 6 & 0x3f)
+		off := int(b[blockD]&0x1f) * 30 * 60 // half hours
+		if b[blockD]&0x20 != 0 {
+			off = -off
+		}
+		if hour < 24 && min < 60 {
+			t := time.Date(1858, time.November, 17, hour, min, 0, 0, time.UTC).AddDate(0, 0, mjd)
+			t = t.In(time.FixedZone("", off))
+			e.ClockTime = &t
+		}
+	}
+	return e, true
+}
+
+// addAF records an alternative frequency code. Codes 1 to 204 are FM
+// frequencies from 87.6 MHz in 100 kHz steps, the rest are fillers,
+// counts and LF/MF markers.
+func (s *station) addAF(code int) {
+	if code < 1 || code > 204 {
+		return
+	}
+	if s.afs == nil {
+		s.afs = map[int]bool{}
+	}
+	s.afs[code] = true
+}
+
+func (s *station) altFreqs() []float64 {
+	if len(s.afs) == 0 {
+		return nil
+	}
+	f := make([]float64, 0, len(s.afs))
+	for c := range s.afs {
+		f = append(f, float64(875+c)/10)
+	}
+	sort.Float64s(f)
+	return f
+}
+
+// decodeText converts RDS characters, which agree with ASCII for the
+// printable range, replacing the rest.
+func decodeText(b []byte) string {
+	r := make([]byte, len(b))
+	for i, c := range b {
+		if c < 0x20 || c > 0x7e {
+			c = '?'
+		}
+		r[i] = c
+	}
+	return string(r)
+}
+
+var ptyRDS = [32]string{
+	"None", "News", "Current affairs", "Information", "Sport", "Education",
+	"Drama", "Culture", "Science", "Varied", "Pop music", "Rock music",
+	"Easy listening", "Light classical", "Serious classical", "Other music",
+	"Weather", "Finance", "Children's programmes", "Social affairs",
+	"Religion", "Phone-in", "Travel", "Leisure", "Jazz music",
+	"Country music", "National music", "Oldies music", "Folk music",
+	"Documentary", "Alarm test", "Alarm",
+}
+
+var ptyRBDS = [32]string{
+	"None", "News", "Information", "Sports", "Talk", "Rock", "Classic rock",
+	"Adult hits", "Soft rock", "Top 40", "Country", "Oldies", "Soft",
+	"Nostalgia", "Jazz", "Classical", "Rhythm and blues",
+	"Soft rhythm and blues", "Language", "Religious music", "Religious talk",
+	"Personality", "Public", "College", "", "", "", "", "", "Weather",
+	"Emergency test", "Emergency",
+}
+
+func (d *Decoder) ptyName(pty int) string {
+	if d.cfg.RBDS {
+		return ptyRBDS[pty]
+	}
+	return ptyRDS[pty]
+}
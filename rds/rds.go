@@ -0,0 +1,224 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package rds decodes the Radio Data System, and its North American
+// variant RBDS, from the FM multiplex produced by demod.WBFM.
+//
+// The 57 kHz subcarrier is brought to baseband, a Costas loop recovers
+// the suppressed carrier, the biphase symbols at 1187.5 Hz are
+// integrated at the timing found to give the strongest symbols and
+// differentially decoded to bits. The bits are then searched for the
+// block check words, short error bursts are corrected, and complete
+// groups parsed into events.
+package rds
+
+import (
+	"errors"
+	"math"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+)
+
+const (
+	subcarrier = 57e3
+	symbolRate = 1187.5
+	sps        = 16 // samples per symbol at the symbol rate
+	chipRate   = sps * symbolRate
+	bandwidth  = 2400 // one sided
+)
+
+// Config holds the decoder settings.
+type Config struct {
+	MPXRate float64 // rate of the multiplex, see demod.WBFM.MPXRate
+
+	// RBDS selects the North American program type names.
+	RBDS bool
+
+	// OnEvent, when set, is called with each decoded group, see
+	// JSONLines.
+	OnEvent func(Event)
+}
+
+// Decoder decodes RDS from the FM multiplex.
+type Decoder struct {
+	cfg Config
+
+	nco *dsp.NCO
+	dec *dsp.Decimator
+	rs  dsp.Resampler
+	lp  *dsp.FIRFilter
+
+	// Costas loop
+	phase, freq float64
+	kp, ki      float64
+	power       float64
+
+	// symbol timing, the integrate and dump window is chosen from the
+	// sps possible offsets by which gives the largest magnitudes
+	hist    [sps]float32
+	n       int
+	metric  [sps]float64
+	wait    int // samples to the next symbol
+	prevSym bool
+
+	sync syncer
+	st   station
+
+	bb, tmp []complex64
+}
+
+// NewDecoder returns a decoder for the given configuration.
+func NewDecoder(cfg Config) (*Decoder, error) {
+	if cfg.MPXRate < 2*(subcarrier+bandwidth) {
+		return nil, errors.New("multiplex rate too low for RDS")
+	}
+	dec, err := dsp.NewDecimator(dsp.DecimatorConfig{
+		InputRate:  cfg.MPXRate,
+		OutputRate: chipRate,
+		Passband:   bandwidth,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rs, err := dsp.NewResampler(dec.OutputRate(), chipRate)
+	if err != nil {
+		return nil, err
+	}
+	// 10 Hz loop noise bandwidth, critically damped
+	const bn, zeta = 10.0, 0.707
+	wn := 2 * bn / (zeta + 1/(4*zeta)) / chipRate
+	d := &Decoder{
+		cfg: cfg,
+		nco: dsp.NewNCO(cfg.MPXRate, -subcarrier),
+		dec: dec,
+		rs:  rs,
+		lp:  dsp.NewFIRFilter(dsp.LowpassKaiser(bandwidth/chipRate, 4000/chipRate, 40)),
+		kp:  2 * zeta * wn,
+		ki:  wn * wn,
+	}
+	for i := range d.st.rt {
+		d.st.rt[i] = ' '
+	}
+	d.sync.onGroup = func(b [4]uint16, valid [4]bool, versionB bool) {
+		if e, ok := d.group(b, valid, versionB); ok && d.cfg.OnEvent != nil {
+			d.cfg.OnEvent(e)
+		}
+	}
+	return d, nil
+}
+
+// Synced reports whether the decoder is synchronised to the block
+// structure.
+func (d *Decoder) Synced() bool {
+	return d.sync.synced
+}
+
+// Station returns the station information decoded so far.
+func (d *Decoder) Station() Station {
+	s := Station{
+		PI:       d.st.pi,
+		PTY:      d.st.pty,
+		TP:       d.st.tp,
+		TA:       d.st.ta,
+		AltFreqs: d.st.altFreqs(),
+	}
+	if d.st.psMask == 0xf {
+		s.PS = decodeText(d.st.ps[:])
+	}
+	if d.st.rtMask != 0 {
+		end := len(d.st.rt)
+		if d.st.rtEnd > 0 {
+			end = d.st.rtEnd
+		}
+		s.RadioText = decodeText(d.st.rt[:end])
+	}
+	return s
+}
+
+// Process decodes the multiplex samples in mpx.
+func (d *Decoder) Process(mpx []float32) {
+	d.tmp = grow(d.tmp, len(mpx))
+	for i, v := range mpx {
+		d.tmp[i] = complex(v, 0)
+	}
+	d.tmp = d.nco.Mix(d.tmp, d.tmp)
+	d.bb = d.dec.Process(d.bb, d.tmp)
+	d.tmp = d.rs.Process(d.tmp, d.bb)
+	d.bb = d.lp.Process(d.bb, d.tmp)
+	for _, v := range d.bb {
+		d.sample(v)
+	}
+}
+
+// sample runs the carrier and symbol recovery on one baseband sample.
+func (d *Decoder) sample(v complex64) {
+	s, c := math.Sincos(d.phase)
+	re := float64(real(v))*c + float64(imag(v))*s
+	im := float64(imag(v))*c - float64(real(v))*s
+	p := re*re + im*im
+	d.power += 0.001 * (p - d.power)
+
+	// BPSK Costas error, normalised by the signal power
+	e := re * im / math.Max(d.power, 1e-20)
+	d.freq += d.ki * e
+	d.phase += d.freq + d.kp*e
+	if d.phase > math.Pi {
+		d.phase -= 2 * math.Pi
+	} else if d.phase < -math.Pi {
+		d.phase += 2 * math.Pi
+	}
+
+	// a biphase symbol is one chip followed by its inverse, so the
+	// symbol is the first half of the window minus the second
+	k := d.n % sps
+	d.hist[k] = float32(re)
+	d.n++
+	var sum float32
+	for j := 0; j < sps; j++ {
+		x := d.hist[(k+1+j)%sps]
+		if j < sps/2 {
+			sum += x
+		} else {
+			sum -= x
+		}
+	}
+	m := &d.metric[k]
+	*m += 0.01 * (math.Abs(float64(sum)) - *m)
+	if d.wait--; d.wait > 0 {
+		return
+	}
+
+	sym := sum > 0
+	var b uint8
+	if sym != d.prevSym {
+		b = 1
+	}
+	d.prevSym = sym
+
+	// step the timing one sample towards the offset giving the
+	// strongest symbols, so no symbol is skipped or taken twice
+	best := 0
+	for j := range d.metric {
+		if d.metric[j] > d.metric[best] {
+			best = j
+		}
+	}
+	d.wait = sps
+	if dk := (best - k + sps) % sps; dk != 0 && d.metric[best] > 1.05*d.metric[k] {
+		if dk < sps/2 {
+			d.wait++
+		} else {
+			d.wait--
+		}
+	}
+	d.sync.bit(b)
+}
+
+// grow returns dst resized to n elements, reallocating when needed.
+func grow(dst []complex64, n int) []complex64 {
+	if cap(dst) < n {
+		return make([]complex64, n)
+	}
+	return dst[:n]
+}
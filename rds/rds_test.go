@@ -0,0 +1,189 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package rds
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testPI  = 0xc201
+	testPTY = 10 // pop music
+)
+
+// typeBlock returns block B of a group: the type, version, TP, PTY and
+// the type specific low 5 bits.
+func typeBlock(typ int, versionB bool, low uint16) uint16 {
+	b := uint16(typ)<<12 | 1<<10 | testPTY<<5 | low
+	if versionB {
+		b |= 1 << 11
+	}
+	return b
+}
+
+func chars(s string, i int) uint16 {
+	return uint16(s[i])<<8 | uint16(s[i+1])
+}
+
+// testGroups returns a program service name with alternative
+// frequencies, a RadioText in 2A groups, another in 2B groups with the
+// A/B flag toggled, and the clock time.
+func testGroups() [][4]uint16 {
+	var g [][4]uint16
+	ps := "TESTFM  "
+	// two AFs, codes 21 and 45, and a filler
+	af := [4]uint16{225<<8 | 21, 45<<8 | 205, 225<<8 | 21, 45<<8 | 205}
+	for k := 0; k < 4; k++ {
+		g = append(g, [4]uint16{testPI, typeBlock(0, false, uint16(k)), af[k], chars(ps, 2*k)})
+	}
+	rt := "Hello RDS world\r"
+	for k := 0; k < 4; k++ {
+		g = append(g, [4]uint16{testPI, typeBlock(2, false, uint16(k)), chars(rt, 4*k), chars(rt, 4*k+2)})
+	}
+	rt = "NEWS 24\r"
+	for k := 0; k < 4; k++ {
+		g = append(g, [4]uint16{testPI, typeBlock(2, true, 1<<4|uint16(k)), testPI, chars(rt, 2*k)})
+	}
+	// MJD 58000, 2017-09-04, 12:30 UTC, local offset +2 half hours
+	const mjd = 58000
+	g = append(g, [4]uint16{testPI, typeBlock(4, false, mjd>>15), mjd << 1 & 0xffff, 12<<12 | 30<<6 | 2})
+	return g
+}
+
+// modulate returns the groups as the RDS subcarrier of a multiplex at
+// fs, with the pilot, some audio and noise.
+func modulate(groups [][4]uint16, fs float64) []float32 {
+	var bits []uint32
+	for _, g := range groups {
+		for i, info := range g {
+			off := offsets[i]
+			if i == blockC && g[blockB]>>11&1 != 0 {
+				off = offsetCPrime
+			}
+			w := uint32(info)<<10 | crc(uint32(info)) ^ uint32(off)
+			for k := blockBits - 1; k >= 0; k-- {
+				bits = append(bits, w>>uint(k)&1)
+			}
+		}
+	}
+	r := rand.New(rand.NewSource(1))
+	spb := fs / symbolRate
+	mpx := make([]float32, int(float64(len(bits))*spb))
+	var level uint32
+	prev := -1
+	for i := range mpx {
+		k := int(float64(i) / spb)
+		if k != prev {
+			// differentially coded, a 1 changes the symbol
+			level ^= bits[k]
+			prev = k
+		}
+		// biphase, the symbol then its inverse
+		v := 1.0
+		if float64(i)/spb-float64(k) >= 0.5 {
+			v = -v
+		}
+		if level == 1 {
+			v = -v
+		}
+		t := float64(i) / fs
+		x := 0.03*v*math.Cos(2*math.Pi*subcarrier*t+0.7) +
+			0.1*math.Sin(2*math.Pi*19e3*t) +
+			0.4*math.Sin(2*math.Pi*1e3*t) +
+			0.01*r.NormFloat64()
+		mpx[i] = float32(x)
+	}
+	return mpx
+}
+
+func TestDecode(t *testing.T) {
+	const fs = 240000.0
+	var groups [][4]uint16
+	for i := 0; i < 3; i++ {
+		groups = append(groups, testGroups()...)
+	}
+	mpx := modulate(groups, fs)
+
+	var events []Event
+	var lines bytes.Buffer
+	jl := JSONLines(&lines, func(err error) { t.Error(err) })
+	d, err := NewDecoder(Config{MPXRate: fs, OnEvent: func(e Event) {
+		events = append(events, e)
+		jl(e)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(mpx); i += 4096 {
+		e := i + 4096
+		if e > len(mpx) {
+			e = len(mpx)
+		}
+		d.Process(mpx[i:e])
+	}
+	if !d.Synced() {
+		t.Fatal("not synchronised")
+	}
+	// the first groups go to finding the block sync
+	if len(events) < len(groups)-len(testGroups()) {
+		t.Fatalf("got %d events, want at least %d", len(events), len(groups)-len(testGroups()))
+	}
+
+	var ps, rtA, rtB, ct int
+	for _, e := range events {
+		if e.PI != "0xC201" || !e.TP || e.PTY != testPTY || e.PTYName != "Pop music" {
+			t.Errorf("got %+v", e)
+		}
+		switch {
+		case e.PS != "":
+			ps++
+			if e.Group != "0A" || e.PS != "TESTFM  " {
+				t.Errorf("got PS %q in %s", e.PS, e.Group)
+			}
+			if len(e.AltFreqs) != 2 || e.AltFreqs[0] != 89.6 || e.AltFreqs[1] != 92 {
+				t.Errorf("got AFs %v, want [89.6 92]", e.AltFreqs)
+			}
+		case e.RadioText != "":
+			if e.Group == "2A" {
+				rtA++
+				if e.RadioText != "Hello RDS world" {
+					t.Errorf("got RadioText %q in 2A", e.RadioText)
+				}
+			} else {
+				rtB++
+				if e.Group != "2B" || e.RadioText != "NEWS 24" {
+					t.Errorf("got RadioText %q in %s", e.RadioText, e.Group)
+				}
+			}
+		case e.ClockTime != nil:
+			ct++
+			want := time.Date(2017, time.September, 4, 12, 30, 0, 0, time.UTC)
+			if _, off := e.ClockTime.Zone(); !e.ClockTime.Equal(want) || off != 3600 {
+				t.Errorf("got clock time %v, want %v at +01:00", e.ClockTime, want)
+			}
+		}
+	}
+	// the text is only complete once all of its groups are received, and
+	// the A/B flag clears it for the next
+	if ps < 2 || rtA < 2 || rtB < 2 || ct < 2 {
+		t.Errorf("got %d PS, %d 2A and %d 2B RadioText, %d clock time events", ps, rtA, rtB, ct)
+	}
+
+	for _, want := range []string{
+		`{"pi":"0xC201","group":"0A","tp":true,"pty":10,"prog_type":"Pop music","ta":false,"ps":"TESTFM  ","alt_freqs":[89.6,92]}`,
+		`{"pi":"0xC201","group":"2A","tp":true,"pty":10,"prog_type":"Pop music","radiotext":"Hello RDS world"}`,
+		`{"pi":"0xC201","group":"2B","tp":true,"pty":10,"prog_type":"Pop music","radiotext":"NEWS 24"}`,
+		`{"pi":"0xC201","group":"4A","tp":true,"pty":10,"prog_type":"Pop music","clock_time":"2017-09-04T13:30:00+01:00"}`,
+	} {
+		if !strings.Contains(lines.String(), want+"\n") {
+			t.Errorf("missing JSON line %s", want)
+		}
+	}
+}
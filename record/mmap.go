@@ -0,0 +1,245 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+//go:build linux || darwin
+// +build linux darwin
+
+package record
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// DefaultChunkSize is the default mapping window of an MmapSink.
+const DefaultChunkSize = 16 << 20
+
+// chunksAhead is the number of chunks mapped ahead of the writer.
+const chunksAhead = 2
+
+// Errors returned by MmapSink.Write.
+var (
+	ErrFull    = errors.New("recording file full")
+	ErrOverrun = errors.New("no mapped chunk ready, samples dropped")
+)
+
+// MmapSink records raw samples into a pre-allocated, memory-mapped
+// file. Write only copies into the current mapping, so it's safe to
+// call from the ReadAsync callback at high sample rates; mapping,
+// prefaulting, msync and unmapping happen on a separate goroutine.
+//
+// Write isn't safe for concurrent use, and Close must not be called
+// until the last Write has returned, e.g. after CancelAsync.
+type MmapSink struct {
+	f     *os.File
+	size  int64
+	chunk int64
+
+	cur []byte
+	pos int
+
+	written int64 // atomic
+	dropped int64 // atomic
+
+	ready  chan []byte
+	full   chan []byte
+	done   chan struct{}
+	next   int64 // file offset of the next chunk to map
+	mapped bool  // the whole file has been mapped and ready closed
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewMmapSink creates the file at path, pre-allocates size bytes and
+// maps the first chunks. chunkSize is rounded up to a multiple of the
+// page size, zero selects DefaultChunkSize.
+func NewMmapSink(path string, size int64, chunkSize int) (*MmapSink, error) {
+	if size <= 0 {
+		return nil, errors.New("invalid file size")
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	page := os.Getpagesize()
+	chunkSize = (chunkSize + page - 1) / page * page
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = preallocate(f, size); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	s := &MmapSink{
+		f:     f,
+		size:  size,
+		chunk: int64(chunkSize),
+		ready: make(chan []byte, chunksAhead),
+		full:  make(chan []byte, size/int64(chunkSize)+1),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < chunksAhead; i++ {
+		s.prepare()
+	}
+	if err = s.error(); err != nil {
+		s.unmapReady()
+		f.Close()
+		return nil, err
+	}
+	go s.flusher()
+	return s, nil
+}
+
+// Callback returns a function suitable for passing to ReadAsync.
+// Dropped bytes are counted rather than reported.
+func (s *MmapSink) Callback() func([]byte) {
+	return func(buf []byte) {
+		s.Write(buf)
+	}
+}
+
+// Write copies buf into the mapped file. It doesn't allocate or block
+// on I/O; when no mapping is ready, or the file is full, the remainder
+// of buf is dropped and ErrOverrun or ErrFull returned.
+func (s *MmapSink) Write(buf []byte) (int, error) {
+	n := 0
+	for len(buf) > 0 {
+		if s.cur == nil {
+			select {
+			case b, ok := <-s.ready:
+				if !ok {
+					atomic.AddInt64(&s.dropped, int64(len(buf)))
+					atomic.AddInt64(&s.written, int64(n))
+					return n, ErrFull
+				}
+				s.cur, s.pos = b, 0
+			default:
+				atomic.AddInt64(&s.dropped, int64(len(buf)))
+				atomic.AddInt64(&s.written, int64(n))
+				return n, ErrOverrun
+			}
+		}
+		k := copy(s.cur[s.pos:], buf)
+		s.pos += k
+		n += k
+		buf = buf[k:]
+		if s.pos == len(s.cur) {
+			s.full <- s.cur
+			s.cur = nil
+		}
+	}
+	atomic.AddInt64(&s.written, int64(n))
+	return n, nil
+}
+
+// Written returns the number of bytes recorded.
+func (s *MmapSink) Written() int64 {
+	return atomic.LoadInt64(&s.written)
+}
+
+// Dropped returns the number of bytes dropped.
+func (s *MmapSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close flushes and unmaps the file, truncates it to the recorded
+// length and closes it.
+func (s *MmapSink) Close() error {
+	if s.cur != nil {
+		s.full <- s.cur
+		s.cur = nil
+	}
+	close(s.full)
+	<-s.done
+	if err := s.f.Truncate(s.Written()); err != nil {
+		s.setError(err)
+	}
+	if err := s.f.Close(); err != nil {
+		s.setError(err)
+	}
+	return s.error()
+}
+
+// flusher syncs and unmaps full chunks and maps new ones.
+func (s *MmapSink) flusher() {
+	defer close(s.done)
+	for b := range s.full {
+		s.release(b)
+		s.prepare()
+	}
+	s.unmapReady()
+}
+
+// unmapReady closes the ready channel and unmaps any unused chunks.
+func (s *MmapSink) unmapReady() {
+	if !s.mapped {
+		s.mapped = true
+		close(s.ready)
+	}
+	for b := range s.ready {
+		syscall.Munmap(b)
+	}
+}
+
+// prepare maps and prefaults the next chunk. Once the whole file has
+// been mapped the ready channel is closed, so the writer sees ErrFull.
+func (s *MmapSink) prepare() {
+	if s.mapped || s.error() != nil {
+		return
+	}
+	if s.next >= s.size {
+		s.mapped = true
+		close(s.ready)
+		return
+	}
+	n := s.chunk
+	if s.size-s.next < n {
+		n = s.size - s.next
+	}
+	b, err := syscall.Mmap(int(s.f.Fd()), s.next, int(n),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		s.setError(err)
+		return
+	}
+	// touch every page now so the writer never takes a page fault
+	page := os.Getpagesize()
+	for i := 0; i < len(b); i += page {
+		b[i] = 0
+	}
+	s.ready <- b
+	s.next += n
+}
+
+// release writes a chunk back to the file and unmaps it.
+func (s *MmapSink) release(b []byte) {
+	_, _, e := syscall.Syscall(syscall.SYS_MSYNC,
+		uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), syscall.MS_SYNC)
+	if e != 0 {
+		s.setError(e)
+	}
+	if err := syscall.Munmap(b); err != nil {
+		s.setError(err)
+	}
+}
+
+func (s *MmapSink) setError(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+}
+
+func (s *MmapSink) error() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package record
+
+import "errors"
+
+// MmapSink is only available on Linux and OS X.
+type MmapSink struct{}
+
+// NewMmapSink returns an error on this platform.
+func NewMmapSink(path string, size int64, chunkSize int) (*MmapSink, error) {
+	return nil, errors.New("memory-mapped recording not supported on this platform")
+}
+
+// Callback returns a no-op function.
+func (s *MmapSink) Callback() func([]byte) {
+	return func([]byte) {}
+}
+
+// Write always fails.
+func (s *MmapSink) Write(buf []byte) (int, error) {
+	return 0, errors.New("memory-mapped recording not supported on this platform")
+}
+
+// Written returns zero.
+func (s *MmapSink) Written() int64 { return 0 }
+
+// Dropped returns zero.
+func (s *MmapSink) Dropped() int64 { return 0 }
+
+// Close does nothing.
+func (s *MmapSink) Close() error { return nil }
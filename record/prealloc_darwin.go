@@ -0,0 +1,13 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package record
+
+import "os"
+
+// preallocate sizes the file; pages are allocated as the mapping is
+// prefaulted.
+func preallocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
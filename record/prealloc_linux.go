@@ -0,0 +1,20 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package record
+
+import (
+	"os"
+	"syscall"
+)
+
+// preallocate reserves disk blocks for the whole file so writes through
+// the mapping never wait on block allocation.
+func preallocate(f *os.File, size int64) error {
+	if err := syscall.Fallocate(int(f.Fd()), 0, 0, size); err != syscall.EOPNOTSUPP {
+		return err
+	}
+	// e.g. FAT formatted SD cards
+	return f.Truncate(size)
+}
@@ -0,0 +1,389 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package record provides IQ recording sinks that can be fed directly
+// from ReadAsync callbacks or ReadSync buffers.
+package record
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// TriggerConfig holds the triggered recorder settings.
+type TriggerConfig struct {
+	Dir        string // output directory, defaults to the working directory
+	Prefix     string // file name prefix, defaults to "event"
+	SampleRate int
+	CenterFreq int
+
+	// Start is the wall clock time of the first sample written to the
+	// recorder, defaults to the time of the first Write.
+	Start time.Time
+
+	// ThresholdDb is the trigger level in dBFS, or in dB above the
+	// tracked noise floor when Relative is set.
+	ThresholdDb float64
+	Relative    bool
+
+	// Offset and Bandwidth select the band, relative to the center
+	// frequency, whose power is measured. A zero Bandwidth measures
+	// the full captured band.
+	Offset    int
+	Bandwidth int
+
+	Window      time.Duration // power averaging window, default 1ms
+	NoiseTime   time.Duration // noise floor time constant, default 1s
+	PreTrigger  time.Duration // IQ kept ahead of the trigger
+	PostTrigger time.Duration // IQ recorded once power drops below threshold
+	HoldOff     time.Duration // dead time after an event ends
+	MaxEvent    time.Duration // event length cap including pre-trigger IQ, zero means no cap
+
+	// OnEvent, when set, is called after each event file is closed.
+	OnEvent func(Event)
+}
+
+// Event describes a recorded trigger event. It's written next to the
+// IQ file as a JSON sidecar.
+type Event struct {
+	File         string    `json:"file"`
+	Time         time.Time `json:"time"`
+	LevelDb      float64   `json:"level_db"`
+	PeakDb       float64   `json:"peak_db"`
+	ThresholdDb  float64   `json:"threshold_db"`
+	NoiseFloorDb float64   `json:"noise_floor_db"`
+	SampleRate   int       `json:"sample_rate"`
+	CenterFreq   int       `json:"center_freq"`
+	PreSamples   int       `json:"pre_samples"`
+	Samples      int       `json:"samples"`
+	Truncated    bool      `json:"truncated"`
+}
+
+type triggerState int
+
+const (
+	stateIdle triggerState = iota
+	stateRecording
+	stateHoldOff
+)
+
+// TriggerRecorder watches in-band power and records cu8 IQ around
+// transmissions that exceed a threshold. It implements io.Writer, so
+// it can be fed ReadSync buffers or wrapped with Callback for ReadAsync.
+type TriggerRecorder struct {
+	cfg TriggerConfig
+
+	// band filter
+	rot, ph complex128
+	alpha   float64
+	y       complex128
+
+	// power window
+	winLen int
+	win    []byte
+	acc    float64
+
+	noise     float64
+	noiseA    float64
+	haveNoise bool
+
+	ring     []byte
+	ringPos  int
+	ringFull bool
+
+	state     triggerState
+	n         int64 // samples processed
+	holdUntil int64
+	lastAbove int64
+	postLen   int64
+	maxLen    int64
+	holdLen   int64
+
+	f     io.WriteCloser
+	event Event
+	peak  float64
+	err   error
+
+	// create and writeFile make the IQ and sidecar files
+	create    func(path string) (io.WriteCloser, error)
+	writeFile func(path string, b []byte) error
+}
+
+// NewTriggerRecorder returns a recorder for the given configuration.
+func NewTriggerRecorder(cfg TriggerConfig) (*TriggerRecorder, error) {
+	if cfg.SampleRate <= 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+	if cfg.Bandwidth < 0 || cfg.Bandwidth > cfg.SampleRate {
+		return nil, errors.New("invalid bandwidth")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "event"
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Millisecond
+	}
+	if cfg.NoiseTime <= 0 {
+		cfg.NoiseTime = time.Second
+	}
+	fs := float64(cfg.SampleRate)
+	r := &TriggerRecorder{
+		cfg:     cfg,
+		ph:      1,
+		winLen:  durSamples(cfg.Window, fs),
+		postLen: int64(durSamples(cfg.PostTrigger, fs)),
+		maxLen:  int64(durSamples(cfg.MaxEvent, fs)),
+		holdLen: int64(durSamples(cfg.HoldOff, fs)),
+		create: func(path string) (io.WriteCloser, error) {
+			return os.Create(path)
+		},
+		writeFile: func(path string, b []byte) error {
+			return ioutil.WriteFile(path, b, 0644)
+		},
+	}
+	if r.winLen < 1 {
+		r.winLen = 1
+	}
+	r.win = make([]byte, 0, 2*r.winLen)
+	pre := durSamples(cfg.PreTrigger, fs)
+	if r.maxLen > 0 && int64(pre+r.winLen) > r.maxLen {
+		// the pre-trigger IQ and the triggering window count toward
+		// MaxEvent
+		pre = int(r.maxLen) - r.winLen
+		if pre < 0 {
+			pre = 0
+		}
+	}
+	r.ring = make([]byte, 2*pre)
+	r.noiseA = 1 - math.Exp(-cfg.Window.Seconds()/cfg.NoiseTime.Seconds())
+	if cfg.Offset != 0 {
+		w := -2 * math.Pi * float64(cfg.Offset) / fs
+		r.rot = complex(math.Cos(w), math.Sin(w))
+	}
+	if cfg.Bandwidth > 0 {
+		r.alpha = 1 - math.Exp(-math.Pi*float64(cfg.Bandwidth)/fs)
+	}
+	return r, nil
+}
+
+func durSamples(d time.Duration, fs float64) int {
+	return int(d.Seconds() * fs)
+}
+
+// Callback returns a function suitable for passing to ReadAsync. Write
+// errors stop recording and are returned by Close.
+func (r *TriggerRecorder) Callback() func([]byte) {
+	return func(buf []byte) {
+		r.Write(buf)
+	}
+}
+
+// NoiseFloorDb returns the current noise floor estimate in dBFS.
+func (r *TriggerRecorder) NoiseFloorDb() float64 {
+	return iq.DB(r.noise)
+}
+
+// Write processes interleaved cu8 samples.
+func (r *TriggerRecorder) Write(buf []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.cfg.Start.IsZero() {
+		r.cfg.Start = time.Now()
+	}
+	for i := 0; i+1 < len(buf); i += 2 {
+		x := complex(float64(buf[i])-127.5, float64(buf[i+1])-127.5) / 127.5
+		if r.rot != 0 {
+			x *= r.ph
+			r.ph *= r.rot
+		}
+		if r.alpha != 0 {
+			r.y += complex(r.alpha, 0) * (x - r.y)
+			x = r.y
+		}
+		r.acc += real(x)*real(x) + imag(x)*imag(x)
+		r.win = append(r.win, buf[i], buf[i+1])
+		if len(r.win) == cap(r.win) {
+			r.window(r.acc / float64(r.winLen))
+			if r.err != nil {
+				return i, r.err
+			}
+			r.acc = 0
+			r.win = r.win[:0]
+		}
+	}
+	if r.rot != 0 {
+		// keep the phasor on the unit circle
+		r.ph /= complex(math.Hypot(real(r.ph), imag(r.ph)), 0)
+	}
+	return len(buf), nil
+}
+
+// Close finishes any event in progress.
+func (r *TriggerRecorder) Close() error {
+	if r.state == stateRecording {
+		r.event.Truncated = true
+		r.finish()
+	}
+	return r.err
+}
+
+// threshold returns the linear trigger level.
+func (r *TriggerRecorder) threshold() float64 {
+	t := iq.FromDB(r.cfg.ThresholdDb)
+	if r.cfg.Relative {
+		t *= r.noise
+	}
+	return t
+}
+
+// window handles a complete power measurement window.
+func (r *TriggerRecorder) window(p float64) {
+	start := r.n
+	r.n += int64(r.winLen)
+	switch r.state {
+	case stateHoldOff:
+		if r.n >= r.holdUntil {
+			r.state = stateIdle
+		}
+		fallthrough
+	case stateIdle:
+		above := r.haveNoise && p > r.threshold()
+		if r.state == stateIdle && above {
+			r.trigger(start, p)
+			return
+		}
+		// a transmission held off isn't noise
+		if !above {
+			r.trackNoise(p)
+		}
+		r.push(r.win)
+	case stateRecording:
+		r.write(r.win)
+		r.event.Samples += r.winLen
+		if p > r.peak {
+			r.peak = p
+		}
+		if p > r.threshold() {
+			r.lastAbove = r.n
+		}
+		switch {
+		case r.maxLen > 0 && int64(r.event.Samples) >= r.maxLen:
+			r.event.Truncated = true
+			r.finish()
+		case r.lastAbove != r.n && r.n-r.lastAbove >= r.postLen:
+			// after a window below the threshold, even with no
+			// post-trigger IQ
+			r.finish()
+		}
+	}
+}
+
+func (r *TriggerRecorder) trackNoise(p float64) {
+	if !r.haveNoise {
+		r.noise = p
+		r.haveNoise = true
+		return
+	}
+	r.noise += r.noiseA * (p - r.noise)
+}
+
+// trigger starts an event at sample index start.
+func (r *TriggerRecorder) trigger(start int64, p float64) {
+	t := r.cfg.Start.Add(time.Duration(float64(start) / float64(r.cfg.SampleRate) * float64(time.Second)))
+	name := fmt.Sprintf("%s_%s_%dHz.cu8", r.cfg.Prefix,
+		t.UTC().Format("20060102T150405.000Z"), r.cfg.CenterFreq)
+	path := filepath.Join(r.cfg.Dir, name)
+	f, err := r.create(path)
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.f = f
+	r.state = stateRecording
+	r.lastAbove = r.n
+	r.peak = p
+	r.event = Event{
+		File:         path,
+		Time:         t,
+		LevelDb:      iq.DB(p),
+		ThresholdDb:  iq.DB(r.threshold()),
+		NoiseFloorDb: iq.DB(r.noise),
+		SampleRate:   r.cfg.SampleRate,
+		CenterFreq:   r.cfg.CenterFreq,
+	}
+	if r.ringFull {
+		r.write(r.ring[r.ringPos:])
+		r.event.PreSamples += (len(r.ring) - r.ringPos) / 2
+	}
+	r.write(r.ring[:r.ringPos])
+	r.event.PreSamples += r.ringPos / 2
+	r.ringPos = 0
+	r.ringFull = false
+	r.write(r.win)
+	r.event.Samples = r.event.PreSamples + r.winLen
+	if r.maxLen > 0 && int64(r.event.Samples) >= r.maxLen {
+		r.event.Truncated = true
+		r.finish()
+	}
+}
+
+// finish closes the event file and writes the sidecar.
+func (r *TriggerRecorder) finish() {
+	r.state = stateHoldOff
+	r.holdUntil = r.n + r.holdLen
+	if err := r.f.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	r.f = nil
+	if r.err != nil {
+		return
+	}
+	ev := r.event
+	ev.PeakDb = iq.DB(r.peak)
+	b, err := json.MarshalIndent(ev, "", "  ")
+	if err != nil {
+		r.err = err
+		return
+	}
+	path := ev.File[:len(ev.File)-len(filepath.Ext(ev.File))] + ".json"
+	if r.err = r.writeFile(path, append(b, '\n')); r.err != nil {
+		return
+	}
+	if r.cfg.OnEvent != nil {
+		r.cfg.OnEvent(ev)
+	}
+}
+
+func (r *TriggerRecorder) write(b []byte) {
+	if r.err != nil || len(b) == 0 {
+		return
+	}
+	_, r.err = r.f.Write(b)
+}
+
+// push adds bytes to the pre-trigger ring.
+func (r *TriggerRecorder) push(b []byte) {
+	if len(r.ring) == 0 {
+		return
+	}
+	for len(b) > 0 {
+		n := copy(r.ring[r.ringPos:], b)
+		b = b[n:]
+		r.ringPos += n
+		if r.ringPos == len(r.ring) {
+			r.ringPos = 0
+			r.ringFull = true
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type memFile struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func (f *memFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+// segment is a run of 1 ms windows of quiet or loud IQ.
+type segment struct {
+	loud    bool
+	windows int
+}
+
+// input returns cu8 IQ at 100 kS/s: quiet noise around -48 dBFS, or
+// loud noise around -2 dBFS.
+func input(r *rand.Rand, segs []segment) []byte {
+	var b []byte
+	for _, s := range segs {
+		for i := 0; i < s.windows*100*2; i++ {
+			if s.loud {
+				b = append(b, byte(r.Intn(256)))
+			} else {
+				b = append(b, byte(127+r.Intn(2)))
+			}
+		}
+	}
+	return b
+}
+
+func TestTriggerRecorder(t *testing.T) {
+	t0 := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+	type event struct {
+		start     int // trigger window's first sample
+		pre       int
+		samples   int
+		truncated bool
+	}
+	for _, c := range []struct {
+		name        string
+		preTrigger  time.Duration
+		postTrigger time.Duration
+		holdOff     time.Duration
+		maxEvent    time.Duration
+		segs        []segment
+		want        []event
+	}{
+		{
+			name:        "ring wrapped",
+			preTrigger:  5 * time.Millisecond,
+			postTrigger: 2 * time.Millisecond,
+			segs:        []segment{{false, 20}, {true, 3}, {false, 10}},
+			// 500 before, 300 loud and 200 post-trigger samples
+			want: []event{{2000, 500, 1000, false}},
+		},
+		{
+			name:        "ring partly filled",
+			preTrigger:  5 * time.Millisecond,
+			postTrigger: 2 * time.Millisecond,
+			segs:        []segment{{false, 3}, {true, 2}, {false, 10}},
+			want:        []event{{300, 300, 700, false}},
+		},
+		{
+			name:        "no pre-trigger",
+			postTrigger: 2 * time.Millisecond,
+			segs:        []segment{{false, 20}, {true, 1}, {false, 10}},
+			want:        []event{{2000, 0, 300, false}},
+		},
+		{
+			name:        "hold-off",
+			preTrigger:  5 * time.Millisecond,
+			postTrigger: 2 * time.Millisecond,
+			holdOff:     10 * time.Millisecond,
+			// the second burst is held off, the event ending at 2300,
+			// the third is 3 ms after the hold-off ends
+			segs: []segment{{false, 20}, {true, 1}, {false, 4}, {true, 1}, {false, 10}, {true, 1}, {false, 10}},
+			want: []event{{2000, 500, 800, false}, {3600, 500, 800, false}},
+		},
+		{
+			name:        "max event",
+			preTrigger:  5 * time.Millisecond,
+			postTrigger: 2 * time.Millisecond,
+			holdOff:     100 * time.Millisecond,
+			maxEvent:    8 * time.Millisecond,
+			segs:        []segment{{false, 20}, {true, 20}, {false, 5}},
+			want:        []event{{2000, 500, 800, true}},
+		},
+		{
+			name:        "max event shorter than the pre-trigger",
+			preTrigger:  5 * time.Millisecond,
+			postTrigger: 2 * time.Millisecond,
+			holdOff:     100 * time.Millisecond,
+			maxEvent:    3 * time.Millisecond,
+			segs:        []segment{{false, 20}, {true, 5}, {false, 5}},
+			want:        []event{{2000, 200, 300, true}},
+		},
+		{
+			// the event ends with the first window below the threshold
+			name:       "no post-trigger",
+			preTrigger: 5 * time.Millisecond,
+			segs:       []segment{{false, 20}, {true, 10}, {false, 5}},
+			want:       []event{{2000, 500, 1600, false}},
+		},
+		{
+			name:        "closed while recording",
+			preTrigger:  5 * time.Millisecond,
+			postTrigger: 2 * time.Millisecond,
+			segs:        []segment{{false, 20}, {true, 5}},
+			want:        []event{{2000, 500, 1000, true}},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			files := map[string]*memFile{}
+			sidecars := map[string][]byte{}
+			var events []Event
+			rec, err := NewTriggerRecorder(TriggerConfig{
+				Dir:         "rec",
+				SampleRate:  100000,
+				CenterFreq:  100000000,
+				Start:       t0,
+				ThresholdDb: -20,
+				PreTrigger:  c.preTrigger,
+				PostTrigger: c.postTrigger,
+				HoldOff:     c.holdOff,
+				MaxEvent:    c.maxEvent,
+				OnEvent:     func(e Event) { events = append(events, e) },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec.create = func(path string) (io.WriteCloser, error) {
+				f := &memFile{Buffer: &bytes.Buffer{}}
+				files[path] = f
+				return f, nil
+			}
+			rec.writeFile = func(path string, b []byte) error {
+				sidecars[path] = b
+				return nil
+			}
+
+			in := input(rand.New(rand.NewSource(1)), c.segs)
+			// in buffers straddling the windows
+			for i := 0; i < len(in); i += 1234 {
+				e := i + 1234
+				if e > len(in) {
+					e = len(in)
+				}
+				if _, err := rec.Write(in[i:e]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := rec.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(events) != len(c.want) || len(files) != len(c.want) {
+				t.Fatalf("got %d events and %d files, want %d", len(events), len(files), len(c.want))
+			}
+			for i, w := range c.want {
+				e := events[i]
+				tm := t0.Add(time.Duration(w.start) * 10 * time.Microsecond)
+				name := filepath.Join("rec", "event_"+tm.Format("20060102T150405.000Z")+"_100000000Hz.cu8")
+				if e.File != name || !e.Time.Equal(tm) || e.PreSamples != w.pre || e.Samples != w.samples ||
+					e.Truncated != w.truncated || e.SampleRate != 100000 || e.CenterFreq != 100000000 ||
+					e.ThresholdDb < -20.01 || e.ThresholdDb > -19.99 || e.LevelDb < -20 || e.PeakDb < e.LevelDb ||
+					e.NoiseFloorDb > -40 {
+					t.Errorf("event %d: got %+v, want %+v", i, e, w)
+				}
+
+				f := files[name]
+				if f == nil || !f.closed {
+					t.Errorf("event %d: %s not written and closed", i, name)
+					continue
+				}
+				want := in[2*(w.start-w.pre) : 2*(w.start-w.pre+w.samples)]
+				if !bytes.Equal(f.Bytes(), want) {
+					t.Errorf("event %d: got %d bytes of IQ, not the %d from sample %d", i, f.Len(), len(want), w.start-w.pre)
+				}
+
+				var side Event
+				b := sidecars[name[:len(name)-len(".cu8")]+".json"]
+				if err := json.Unmarshal(b, &side); err != nil {
+					t.Errorf("event %d: sidecar %q: %v", i, b, err)
+				} else if !reflect.DeepEqual(side, e) {
+					t.Errorf("event %d: got sidecar %+v, want %+v", i, side, e)
+				}
+			}
+		})
+	}
+}
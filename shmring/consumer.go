@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package shmring
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// DefaultPollInterval is how often a blocked Read checks for new
+// samples.
+const DefaultPollInterval = time.Millisecond
+
+// Consumer reads the sample stream from a ring.
+type Consumer struct {
+	// PollInterval is how often a blocked Read checks for new samples.
+	PollInterval time.Duration
+
+	mem     []byte
+	hdr     *header
+	data    []byte
+	r       uint64
+	dropped uint64
+}
+
+// Open attaches to the ring /dev/shm/<name>, read-only so consumers
+// needn't run as the producer's user. Reading starts at the current
+// write position.
+func Open(name string) (*Consumer, error) {
+	path, err := shmPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if st.Size() < HeaderSize {
+		return nil, errors.New("shared memory ring too small")
+	}
+	mem, hdr, err := mapFile(f, int(st.Size()), syscall.PROT_READ)
+	if err != nil {
+		return nil, err
+	}
+	c := &Consumer{PollInterval: DefaultPollInterval, mem: mem, hdr: hdr}
+	switch {
+	case atomic.LoadUint32(&hdr.Magic) != Magic:
+		err = errors.New("not a shared memory ring, or not ready")
+	case hdr.Version != Version:
+		err = errors.New("unsupported shared memory ring version")
+	case int64(hdr.HeaderSize)+int64(hdr.DataSize) > st.Size():
+		err = errors.New("shared memory ring truncated")
+	}
+	if err != nil {
+		syscall.Munmap(mem)
+		return nil, err
+	}
+	c.data = mem[hdr.HeaderSize : uint64(hdr.HeaderSize)+hdr.DataSize]
+	c.r = atomic.LoadUint64(&hdr.WriteIndex)
+	return c, nil
+}
+
+// Meta returns a consistent snapshot of the sample rate and center
+// frequency published by the producer.
+func (c *Consumer) Meta() (sampleRate, centerFreq int) {
+	for {
+		seq := atomic.LoadUint64(&c.hdr.MetaSeq)
+		if seq&1 == 0 {
+			sampleRate = int(atomic.LoadUint64(&c.hdr.SampleRate))
+			centerFreq = int(atomic.LoadUint64(&c.hdr.CenterFreq))
+			if atomic.LoadUint64(&c.hdr.MetaSeq) == seq {
+				return
+			}
+		}
+		time.Sleep(time.Microsecond)
+	}
+}
+
+// Active reports whether the producer is still running.
+func (c *Consumer) Active() bool {
+	return atomic.LoadUint32(&c.hdr.Flags)&FlagActive != 0
+}
+
+// Dropped returns the number of bytes lost to overruns.
+func (c *Consumer) Dropped() uint64 {
+	return c.dropped
+}
+
+// Read copies available samples into p, blocking until some are
+// available. After an overrun it skips to the current write position
+// and returns ErrOverrun; reading may continue. It returns io.EOF once
+// the producer has closed and all samples have been read.
+func (c *Consumer) Read(p []byte) (int, error) {
+	size := uint64(len(c.data))
+	var w uint64
+	for {
+		w = atomic.LoadUint64(&c.hdr.WriteIndex)
+		if w != c.r || len(p) == 0 {
+			break
+		}
+		if !c.Active() {
+			return 0, io.EOF
+		}
+		time.Sleep(c.PollInterval)
+	}
+	if w-c.r > size {
+		return 0, c.resync(w)
+	}
+	n := w - c.r
+	if n > uint64(len(p)) {
+		n = uint64(len(p))
+	}
+	off := c.r % size
+	k := copy(p[:n], c.data[off:])
+	copy(p[k:n], c.data)
+	// a copy begun since may have overwritten what we read
+	if l := atomic.LoadUint64(&c.hdr.WriteLimit); l-c.r > size {
+		return 0, c.resync(atomic.LoadUint64(&c.hdr.WriteIndex))
+	}
+	c.r += n
+	return int(n), nil
+}
+
+func (c *Consumer) resync(w uint64) error {
+	c.dropped += w - c.r
+	c.r = w
+	return ErrOverrun
+}
+
+// Close unmaps the ring.
+func (c *Consumer) Close() error {
+	return syscall.Munmap(c.mem)
+}
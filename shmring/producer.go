@@ -0,0 +1,117 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package shmring
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Producer writes the sample stream into the ring.
+type Producer struct {
+	path string
+	mem  []byte
+	hdr  *header
+	data []byte
+	w    uint64
+}
+
+// Create creates the ring /dev/shm/<name> holding at least size bytes
+// of samples. It fails if the name exists, as another producer may be
+// using it; a stale ring left by a producer that didn't close must be
+// removed first.
+func Create(name string, size, sampleRate, centerFreq int) (*Producer, error) {
+	path, err := shmPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, errors.New("invalid ring size")
+	}
+	page := os.Getpagesize()
+	size = (size + page - 1) / page * page
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err = f.Truncate(int64(HeaderSize + size)); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	mem, hdr, err := mapFile(f, HeaderSize+size, syscall.PROT_READ|syscall.PROT_WRITE)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	p := &Producer{path: path, mem: mem, hdr: hdr, data: mem[HeaderSize:]}
+	hdr.Version = Version
+	hdr.HeaderSize = HeaderSize
+	hdr.Format = FormatCU8
+	hdr.DataSize = uint64(size)
+	hdr.SampleRate = uint64(sampleRate)
+	hdr.CenterFreq = uint64(centerFreq)
+	hdr.Flags = FlagActive
+	// magic last, consumers treat a ring without it as not ready
+	atomic.StoreUint32(&hdr.Magic, Magic)
+	return p, nil
+}
+
+// Callback returns a function suitable for passing to ReadAsync.
+func (p *Producer) Callback() func([]byte) {
+	return func(buf []byte) {
+		p.Write(buf)
+	}
+}
+
+// Write copies buf into the ring and publishes it. It never blocks;
+// slow consumers are overrun.
+func (p *Producer) Write(buf []byte) (int, error) {
+	n := len(buf)
+	size := uint64(len(p.data))
+	if uint64(len(buf)) > size {
+		// only the newest samples fit
+		p.w += uint64(len(buf)) - size
+		buf = buf[uint64(len(buf))-size:]
+	}
+	// readers of the bytes about to be overwritten must see they're
+	// torn before the copy begins, a swap keeps the copy after it
+	atomic.SwapUint64(&p.hdr.WriteLimit, p.w+uint64(len(buf)))
+	off := p.w % size
+	k := copy(p.data[off:], buf)
+	copy(p.data, buf[k:])
+	p.w += uint64(len(buf))
+	atomic.StoreInt64(&p.hdr.LastWrite, time.Now().UnixNano())
+	atomic.AddUint64(&p.hdr.BlockCount, 1)
+	atomic.StoreUint64(&p.hdr.WriteIndex, p.w)
+	return n, nil
+}
+
+// SetMeta publishes a new sample rate and center frequency, e.g. after
+// retuning the device.
+func (p *Producer) SetMeta(sampleRate, centerFreq int) {
+	atomic.AddUint64(&p.hdr.MetaSeq, 1)
+	atomic.StoreUint64(&p.hdr.SampleRate, uint64(sampleRate))
+	atomic.StoreUint64(&p.hdr.CenterFreq, uint64(centerFreq))
+	atomic.AddUint64(&p.hdr.MetaSeq, 1)
+}
+
+// Close marks the ring inactive, unmaps it and removes its name.
+// Consumers that still have it open keep their mapping and see io.EOF
+// once they've read the remaining samples.
+func (p *Producer) Close() error {
+	atomic.StoreUint32(&p.hdr.Flags, 0)
+	err := syscall.Munmap(p.mem)
+	if e := os.Remove(p.path); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+// Package shmring publishes a device's sample stream through a POSIX
+// shared-memory ring buffer so several local processes, in any
+// language, can read the same samples without TCP overhead.
+//
+// The ring lives in /dev/shm/<name> and starts with a HeaderSize byte
+// header, followed by DataSize bytes of cu8 samples. Header fields are
+// in host byte order at these offsets (see also shmring.h):
+//
+//	 0  uint32  magic, "GRSR" (0x52535247)
+//	 4  uint32  version, currently 1
+//	 8  uint32  header size, offset of the sample data
+//	12  uint32  sample format, 1 = cu8
+//	16  uint64  data size, ring capacity in bytes
+//	24  uint64  write index, total bytes ever written
+//	32  uint64  sample rate, Hz
+//	40  uint64  center frequency, Hz
+//	48  uint64  metadata sequence, odd while rate/frequency are updated
+//	56  uint64  block count, buffers written
+//	64  int64   last write time, Unix nanoseconds
+//	72  uint32  flags, bit 0 set while the producer is running
+//	80  uint64  write limit, the write index once the current copy ends
+//
+// Byte i of the stream is at data[i % data size]. For each buffer the
+// producer first stores the write limit, overwriting the bytes more
+// than data size behind it, then copies the buffer into the ring and
+// stores the new write index. A reader may copy bytes up to the write
+// index it loaded. Afterwards it loads the write limit: if its position
+// is more than data size behind it, a copy in progress may have torn
+// the bytes read, the reader has been overrun and must resynchronise.
+package shmring
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Header layout constants.
+const (
+	Magic      = 0x52535247
+	Version    = 1
+	HeaderSize = 4096
+	FormatCU8  = 1
+	FlagActive = 1
+)
+
+// ErrOverrun is returned by Consumer.Read when the producer has
+// overwritten samples the consumer hadn't read yet.
+var ErrOverrun = errors.New("consumer overrun, samples lost")
+
+// shmDir is where Linux exposes POSIX shared memory objects.
+const shmDir = "/dev/shm"
+
+type header struct {
+	Magic      uint32
+	Version    uint32
+	HeaderSize uint32
+	Format     uint32
+	DataSize   uint64
+	WriteIndex uint64
+	SampleRate uint64
+	CenterFreq uint64
+	MetaSeq    uint64
+	BlockCount uint64
+	LastWrite  int64
+	Flags      uint32
+	_          uint32
+	WriteLimit uint64
+}
+
+func shmPath(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name {
+		return "", errors.New("invalid shared memory name")
+	}
+	return filepath.Join(shmDir, name), nil
+}
+
+func mapFile(f *os.File, size, prot int) ([]byte, *header, error) {
+	mem, err := syscall.Mmap(int(f.Fd()), 0, size, prot, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mem, (*header)(unsafe.Pointer(&mem[0])), nil
+}
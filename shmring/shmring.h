@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2018 Joseph D Poirier
+ * Distributable under the terms of The New BSD License
+ * that can be found in the LICENSE file.
+ *
+ * Layout of the gortlsdr shared-memory IQ ring, for consumers not
+ * written in Go. Map /dev/shm/<name>, check magic and version, then read
+ * samples from (uint8_t *)hdr + header_size as described in the shmring
+ * package documentation. Load write_index with acquire semantics before
+ * copying. After copying, issue an acquire fence and load write_limit:
+ * if the read position is more than data_size behind it the producer
+ * may have torn the bytes copied, which must be discarded.
+ */
+#ifndef GORTLSDR_SHMRING_H
+#define GORTLSDR_SHMRING_H
+
+#include <stdint.h>
+
+#define SHMRING_MAGIC       0x52535247u /* "GRSR" */
+#define SHMRING_VERSION     1
+#define SHMRING_FORMAT_CU8  1
+#define SHMRING_FLAG_ACTIVE 1u
+
+struct shmring_header {
+	uint32_t magic;
+	uint32_t version;
+	uint32_t header_size;
+	uint32_t format;
+	uint64_t data_size;
+	uint64_t write_index;
+	uint64_t sample_rate;
+	uint64_t center_freq;
+	uint64_t meta_seq;
+	uint64_t block_count;
+	int64_t  last_write_ns;
+	uint32_t flags;
+	uint32_t reserved;
+	uint64_t write_limit;
+};
+
+#endif
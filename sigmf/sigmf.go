@@ -0,0 +1,196 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package sigmf reads and writes SigMF (Signal Metadata Format)
+// metadata files, see https://github.com/gnuradio/SigMF.
+package sigmf
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Version is the SigMF specification version written to new files.
+const Version = "1.0.0"
+
+// File extensions of a SigMF recording.
+const (
+	MetaExt = ".sigmf-meta"
+	DataExt = ".sigmf-data"
+)
+
+// SigMF datatypes for the sample formats used by this package.
+const (
+	DatatypeCU8  = "cu8"
+	DatatypeCI8  = "ci8"
+	DatatypeCI16 = "ci16_le"
+	DatatypeCF32 = "cf32_le"
+)
+
+// Global holds the global object of a metadata file.
+type Global struct {
+	Datatype    string  `json:"core:datatype"`
+	SampleRate  float64 `json:"core:sample_rate,omitempty"`
+	Version     string  `json:"core:version"`
+	Description string  `json:"core:description,omitempty"`
+	Author      string  `json:"core:author,omitempty"`
+	Recorder    string  `json:"core:recorder,omitempty"`
+	HW          string  `json:"core:hw,omitempty"`
+}
+
+// Capture holds a captures segment.
+type Capture struct {
+	SampleStart int64   `json:"core:sample_start"`
+	Frequency   float64 `json:"core:frequency,omitempty"`
+	Datetime    string  `json:"core:datetime,omitempty"`
+}
+
+// Annotation holds an annotations segment. Frequency edges are
+// absolute frequencies in Hz.
+type Annotation struct {
+	SampleStart   int64   `json:"core:sample_start"`
+	SampleCount   int64   `json:"core:sample_count"`
+	FreqLowerEdge float64 `json:"core:freq_lower_edge,omitempty"`
+	FreqUpperEdge float64 `json:"core:freq_upper_edge,omitempty"`
+	Label         string  `json:"core:label,omitempty"`
+	Comment       string  `json:"core:comment,omitempty"`
+	Generator     string  `json:"core:generator,omitempty"`
+}
+
+// Meta is the top level metadata object.
+type Meta struct {
+	Global      Global       `json:"global"`
+	Captures    []Capture    `json:"captures"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+// New returns metadata for a single capture segment.
+func New(datatype string, sampleRate, centerFreq float64, datetime string) *Meta {
+	return &Meta{
+		Global: Global{
+			Datatype:   datatype,
+			SampleRate: sampleRate,
+			Version:    Version,
+			Recorder:   "gortlsdr",
+		},
+		Captures:    []Capture{{Frequency: centerFreq, Datetime: datetime}},
+		Annotations: []Annotation{},
+	}
+}
+
+// CenterFreq returns the frequency of the first capture segment,
+// or zero when there is none.
+func (m *Meta) CenterFreq() float64 {
+	if len(m.Captures) == 0 {
+		return 0
+	}
+	return m.Captures[0].Frequency
+}
+
+// MetaPath returns the metadata file path for a recording path with
+// either SigMF extension or no extension.
+func MetaPath(path string) string {
+	return basePath(path) + MetaExt
+}
+
+// DataPath returns the dataset file path for a recording path with
+// either SigMF extension or no extension.
+func DataPath(path string) string {
+	return basePath(path) + DataExt
+}
+
+func basePath(path string) string {
+	for _, ext := range []string{MetaExt, DataExt} {
+		if strings.HasSuffix(path, ext) {
+			return strings.TrimSuffix(path, ext)
+		}
+	}
+	return path
+}
+
+// ReadMeta reads a metadata file.
+func ReadMeta(path string) (*Meta, error) {
+	b, err := ioutil.ReadFile(MetaPath(path))
+	if err != nil {
+		return nil, err
+	}
+	m := &Meta{}
+	if err = json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	if m.Global.Datatype == "" {
+		return nil, errors.New("missing core:datatype")
+	}
+	return m, nil
+}
+
+// WriteMeta writes a metadata file.
+func WriteMeta(path string, m *Meta) error {
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(MetaPath(path), append(b, '\n'), 0644)
+}
+
+// AddAnnotations appends annotations to an existing metadata file.
+// Fields this package doesn't know about are preserved.
+func AddAnnotations(path string, a []Annotation) error {
+	path = MetaPath(path)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var m map[string]json.RawMessage
+	if err = json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	var list []json.RawMessage
+	if raw, ok := m["annotations"]; ok {
+		if err = json.Unmarshal(raw, &list); err != nil {
+			return err
+		}
+	}
+	for _, v := range a {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		list = append(list, raw)
+	}
+	// the spec requires annotations sorted by sample_start
+	start := make([]int64, len(list))
+	for i, raw := range list {
+		var v struct {
+			SampleStart int64 `json:"core:sample_start"`
+		}
+		if err = json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		start[i] = v.SampleStart
+	}
+	sort.Stable(byStart{list, start})
+	if m["annotations"], err = json.Marshal(list); err != nil {
+		return err
+	}
+	if b, err = json.MarshalIndent(m, "", "    "); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+type byStart struct {
+	list  []json.RawMessage
+	start []int64
+}
+
+func (b byStart) Len() int           { return len(b.list) }
+func (b byStart) Less(i, j int) bool { return b.start[i] < b.start[j] }
+func (b byStart) Swap(i, j int) {
+	b.list[i], b.list[j] = b.list[j], b.list[i]
+	b.start[i], b.start[j] = b.start[j], b.start[i]
+}
@@ -0,0 +1,324 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package spectrum
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// CFARConfig holds the detector settings. Zero values select the
+// defaults.
+type CFARConfig struct {
+	Guard       int     // guard bins either side of the cell under test, default 2
+	Train       int     // training bins either side, default 16
+	ThresholdDb float64 // detection level above the local noise, default 10 dB
+
+	MergeBins int // gaps up to this many bins are bridged, default 1
+	MinBins   int // narrower clusters are ignored, default 1
+
+	// MinFrames is the number of consecutive frames an emission must
+	// be seen in before it's reported, default 2. HoldFrames is the
+	// number of frames it may go missing before it ends, default 3.
+	MinFrames  int
+	HoldFrames int
+
+	// OnEvent, when set, is called as emissions start and end.
+	OnEvent func(Event)
+}
+
+// EventType says whether an emission started or ended.
+type EventType int
+
+// Event types.
+const (
+	EmissionStart EventType = iota
+	EmissionEnd
+)
+
+func (t EventType) String() string {
+	if t == EmissionStart {
+		return "start"
+	}
+	return "end"
+}
+
+// Emission is a signal tracked across frames.
+type Emission struct {
+	ID         int
+	Start      time.Time
+	Stop       time.Time // last frame seen in, zero while active
+	CenterFreq float64   // Hz
+	Bandwidth  float64   // Hz
+	PeakDb     float64   // highest bin power, dBFS
+	SNRDb      float64   // peak above the local noise floor
+}
+
+// Event reports an emission starting or ending. End events carry the
+// last frame it was seen in as Stop, and the range it occupied then.
+type Event struct {
+	Type EventType
+	Emission
+}
+
+// track is an emission being followed.
+type track struct {
+	Emission
+	lo, hi    float64 // occupied frequency range
+	last      time.Time
+	seen      int
+	missed    int
+	confirmed bool
+}
+
+// CFAR finds emissions in spectrum frames with a cell averaging
+// constant false alarm rate threshold and follows them from frame to
+// frame. Feed it from an Estimator's OnFrame callback; averaging a few
+// segments per frame steadies the noise estimate.
+type CFAR struct {
+	cfg    CFARConfig
+	noise  []float64
+	lin    []float64
+	tmp    []float64
+	floor  float64
+	tracks []*track
+	nextID int
+}
+
+// NewCFAR returns a detector for the given configuration.
+func NewCFAR(cfg CFARConfig) *CFAR {
+	if cfg.Guard == 0 {
+		cfg.Guard = 2
+	}
+	if cfg.Train == 0 {
+		cfg.Train = 16
+	}
+	if cfg.ThresholdDb == 0 {
+		cfg.ThresholdDb = 10
+	}
+	if cfg.MergeBins == 0 {
+		cfg.MergeBins = 1
+	}
+	if cfg.MinBins == 0 {
+		cfg.MinBins = 1
+	}
+	if cfg.MinFrames == 0 {
+		cfg.MinFrames = 2
+	}
+	if cfg.HoldFrames == 0 {
+		cfg.HoldFrames = 3
+	}
+	return &CFAR{cfg: cfg, nextID: 1}
+}
+
+// NoiseFloorDb returns the overall noise floor of the last frame, the
+// median bin power.
+func (c *CFAR) NoiseFloorDb() float64 {
+	return iq.DB(c.floor)
+}
+
+// Active returns the confirmed emissions currently being tracked.
+func (c *CFAR) Active() []Emission {
+	var e []Emission
+	for _, t := range c.tracks {
+		if t.confirmed {
+			e = append(e, t.Emission)
+		}
+	}
+	return e
+}
+
+// Process detects emissions in frame s, taken at time t, and updates
+// the tracks, returning the emissions found in this frame alone.
+func (c *CFAR) Process(s *Spectrum, t time.Time) []Emission {
+	found := c.detect(s)
+	c.update(found, t)
+	return found
+}
+
+// Flush ends every track, e.g. when the stream stops.
+func (c *CFAR) Flush() {
+	for _, t := range c.tracks {
+		c.end(t)
+	}
+	c.tracks = c.tracks[:0]
+}
+
+// estimate fills c.noise with the local noise estimate of each bin.
+// Each side's training cells are averaged and the smaller side used, so
+// a signal edge doesn't mask a neighbour. The estimate is capped at the
+// median, which keeps emissions wider than the training window from
+// hiding themselves.
+func (c *CFAR) estimate(p []float64) {
+	n := len(p)
+	if cap(c.noise) < n {
+		c.noise = make([]float64, n)
+		c.tmp = make([]float64, n, n+1)
+	}
+	c.noise, c.tmp = c.noise[:n], c.tmp[:n]
+	copy(c.tmp, p)
+	sort.Float64s(c.tmp)
+	c.floor = c.tmp[n/2]
+
+	// prefix sums make each side's mean O(1)
+	sum := c.tmp[:0]
+	sum = append(sum, 0)
+	for _, v := range p {
+		sum = append(sum, sum[len(sum)-1]+v)
+	}
+	g, tr := c.cfg.Guard, c.cfg.Train
+	mean := func(lo, hi int) (float64, bool) {
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > n {
+			hi = n
+		}
+		if hi <= lo {
+			return 0, false
+		}
+		return (sum[hi] - sum[lo]) / float64(hi-lo), true
+	}
+	for i := range p {
+		l, okl := mean(i-g-tr, i-g)
+		r, okr := mean(i+g+1, i+g+1+tr)
+		v := c.floor
+		switch {
+		case okl && okr:
+			v = math.Min(l, r)
+		case okl:
+			v = l
+		case okr:
+			v = r
+		}
+		c.noise[i] = math.Min(v, c.floor)
+	}
+}
+
+// detect thresholds and clusters the bins of one frame.
+func (c *CFAR) detect(s *Spectrum) []Emission {
+	n := len(s.Power)
+	if n == 0 {
+		return nil
+	}
+	if cap(c.lin) < n {
+		c.lin = make([]float64, n)
+	}
+	p := c.lin[:n]
+	for i, v := range s.Power {
+		p[i] = iq.FromDB(v)
+	}
+	c.estimate(p)
+	thr := iq.FromDB(c.cfg.ThresholdDb)
+
+	var found []Emission
+	bw := s.BinWidth()
+	for i := 0; i < n; {
+		if p[i] <= c.noise[i]*thr {
+			i++
+			continue
+		}
+		lo, hi, pk := i, i, i
+		for j := i + 1; j < n && j <= hi+c.cfg.MergeBins+1; j++ {
+			if p[j] > c.noise[j]*thr {
+				hi = j
+				if p[j] > p[pk] {
+					pk = j
+				}
+			}
+		}
+		i = hi + 1
+		if hi-lo+1 < c.cfg.MinBins {
+			continue
+		}
+		f0, f1 := s.Freq(lo)-bw/2, s.Freq(hi)+bw/2
+		found = append(found, Emission{
+			CenterFreq: (f0 + f1) / 2,
+			Bandwidth:  f1 - f0,
+			PeakDb:     s.Power[pk],
+			SNRDb:      s.Power[pk] - iq.DB(c.noise[pk]),
+		})
+	}
+	return found
+}
+
+// trackGain is the share of the way a track's frequency range moves
+// to each frame's detection, so it follows an emission drifting or
+// narrowing rather than growing to everything it ever covered.
+const trackGain = 0.5
+
+// update matches this frame's emissions to the tracks. An emission
+// continues the track whose frequency range it overlaps.
+func (c *CFAR) update(found []Emission, now time.Time) {
+	matched := make([]bool, len(c.tracks))
+	for _, e := range found {
+		lo, hi := e.CenterFreq-e.Bandwidth/2, e.CenterFreq+e.Bandwidth/2
+		var t *track
+		for i, tr := range c.tracks {
+			if lo <= tr.hi && hi >= tr.lo {
+				t = tr
+				matched[i] = true
+				break
+			}
+		}
+		if t == nil {
+			t = &track{Emission: Emission{Start: now, PeakDb: e.PeakDb, SNRDb: e.SNRDb}, lo: lo, hi: hi}
+			c.tracks = append(c.tracks, t)
+			matched = append(matched, true)
+		}
+		if t.seen > 0 {
+			t.lo += trackGain * (lo - t.lo)
+			t.hi += trackGain * (hi - t.hi)
+			if e.PeakDb > t.PeakDb {
+				t.PeakDb, t.SNRDb = e.PeakDb, e.SNRDb
+			}
+		}
+		t.CenterFreq = (t.lo + t.hi) / 2
+		t.Bandwidth = t.hi - t.lo
+		t.last = now
+		t.seen++
+		t.missed = 0
+	}
+
+	live := c.tracks[:0]
+	for i, t := range c.tracks {
+		if !matched[i] {
+			t.missed++
+			if !t.confirmed {
+				// a candidate has to be seen in consecutive frames
+				continue
+			}
+			if t.missed > c.cfg.HoldFrames {
+				c.end(t)
+				continue
+			}
+		} else if !t.confirmed && t.seen >= c.cfg.MinFrames {
+			t.confirmed = true
+			t.ID = c.nextID
+			c.nextID++
+			c.event(EmissionStart, t.Emission)
+		}
+		live = append(live, t)
+	}
+	c.tracks = live
+}
+
+// end reports a confirmed track as ended.
+func (c *CFAR) end(t *track) {
+	if t.confirmed {
+		e := t.Emission
+		e.Stop = t.last
+		c.event(EmissionEnd, e)
+	}
+}
+
+func (c *CFAR) event(typ EventType, e Emission) {
+	if c.cfg.OnEvent != nil {
+		c.cfg.OnEvent(Event{Type: typ, Emission: e})
+	}
+}
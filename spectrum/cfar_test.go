@@ -0,0 +1,83 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package spectrum
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testFrame returns a 1 kHz bin spectrum of noise at -60 dBFS with
+// emissions 30 dB above it over bins [lo, hi] of each pair in occupied.
+func testFrame(r *rand.Rand, occupied ...[2]int) *Spectrum {
+	s := &Spectrum{CenterFreq: 100e6, SampleRate: 1.024e6, Power: make([]float64, 1024)}
+	for i := range s.Power {
+		s.Power[i] = -60 + r.NormFloat64()
+	}
+	for _, o := range occupied {
+		for i := o[0]; i <= o[1]; i++ {
+			s.Power[i] = -30 + r.NormFloat64()
+		}
+	}
+	return s
+}
+
+func TestCFARTracks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var events []Event
+	c := NewCFAR(CFARConfig{OnEvent: func(e Event) { events = append(events, e) }})
+	t0 := time.Unix(1500000000, 0)
+	var s *Spectrum
+	for k := 0; k < 40; k++ {
+		var occ [][2]int
+		// an emission 8 bins wide drifting up 2 bins a frame
+		occ = append(occ, [2]int{200 + 2*k, 207 + 2*k})
+		// a steady one, missing from two frames
+		if k != 20 && k != 21 {
+			occ = append(occ, [2]int{700, 719})
+		}
+		// a blip in one frame only
+		if k == 10 {
+			occ = append(occ, [2]int{500, 503})
+		}
+		s = testFrame(r, occ...)
+		c.Process(s, t0.Add(time.Duration(k)*time.Second))
+	}
+	c.Flush()
+
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4: %+v", len(events), events)
+	}
+	bw := s.BinWidth()
+	for _, c := range []struct {
+		e      Event
+		typ    EventType
+		id     int
+		center float64 // Hz
+		width  float64 // Hz
+		stop   time.Time
+	}{
+		{events[0], EmissionStart, 1, s.Freq(203) + bw/2, 8 * bw, time.Time{}},
+		{events[1], EmissionStart, 2, s.Freq(709) + bw/2, 20 * bw, time.Time{}},
+		// the drifting emission's range ends close to its last frame's
+		{events[2], EmissionEnd, 1, s.Freq(281) + bw/2, 8 * bw, t0.Add(39 * time.Second)},
+		{events[3], EmissionEnd, 2, s.Freq(709) + bw/2, 20 * bw, t0.Add(39 * time.Second)},
+	} {
+		e := c.e
+		if e.Type != c.typ || e.ID != c.id || !e.Stop.Equal(c.stop) || !e.Start.Equal(t0) {
+			t.Errorf("got %v %d from %v to %v, want %v %d to %v", e.Type, e.ID, e.Start, e.Stop, c.typ, c.id, c.stop)
+		}
+		// the range trails a drift of 2 bins a frame by 2 bins
+		if math.Abs(e.CenterFreq-c.center) > 2.5*bw || math.Abs(e.Bandwidth-c.width) > bw/2 {
+			t.Errorf("%v %d: got %.0f Hz, %.0f Hz wide, want %.0f Hz, %.0f Hz wide",
+				e.Type, e.ID, e.CenterFreq, e.Bandwidth, c.center, c.width)
+		}
+		if e.SNRDb < 25 || e.SNRDb > 35 {
+			t.Errorf("%v %d: got SNR %.1f dB, want 30", e.Type, e.ID, e.SNRDb)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package spectrum
+
+import (
+	"errors"
+	"math"
+)
+
+// FFT is a mixed-radix fast Fourier transform of a fixed length. Lengths
+// with factors of 2, 3, 4 and 5 are fastest, other prime factors fall
+// back to a generic O(p^2) butterfly.
+type FFT struct {
+	n       int
+	tw      []complex128
+	factors []int // radix, remaining length pairs
+	scratch []complex128
+	tmp     []complex128
+}
+
+// NewFFT returns a transform of length n.
+func NewFFT(n int) (*FFT, error) {
+	if n < 1 {
+		return nil, errors.New("invalid fft length")
+	}
+	f := &FFT{n: n, tw: make([]complex128, n), tmp: make([]complex128, n)}
+	for i := range f.tw {
+		s, c := math.Sincos(-2 * math.Pi * float64(i) / float64(n))
+		f.tw[i] = complex(c, s)
+	}
+	maxp := 1
+	for m, p := n, 4; m > 1; {
+		for m%p != 0 {
+			switch p {
+			case 4:
+				p = 2
+			case 2:
+				p = 3
+			default:
+				p += 2
+			}
+			if p*p > m {
+				p = m
+			}
+		}
+		m /= p
+		f.factors = append(f.factors, p, m)
+		if p > maxp {
+			maxp = p
+		}
+	}
+	f.scratch = make([]complex128, maxp)
+	return f, nil
+}
+
+// Len returns the transform length.
+func (f *FFT) Len() int {
+	return f.n
+}
+
+// Transform computes the forward DFT of src into dst, both of which
+// must have the transform's length. dst and src may be the same slice.
+func (f *FFT) Transform(dst, src []complex128) {
+	if f.n == 1 {
+		dst[0] = src[0]
+		return
+	}
+	if &dst[0] == &src[0] {
+		copy(f.tmp, src)
+		src = f.tmp
+	}
+	f.work(dst, src, 0, 1, f.factors)
+}
+
+// Inverse computes the unnormalised inverse DFT of src into dst; divide
+// by Len to undo Transform.
+func (f *FFT) Inverse(dst, src []complex128) {
+	for i, v := range src {
+		dst[i] = complex(imag(v), real(v))
+	}
+	f.Transform(dst, dst)
+	for i, v := range dst {
+		dst[i] = complex(imag(v), real(v))
+	}
+}
+
+// work is a recursive decimation in time step, out receives p
+// transforms of length m taken from in with stride fstride.
+func (f *FFT) work(out, in []complex128, off, fstride int, fac []int) {
+	p, m := fac[0], fac[1]
+	if m == 1 {
+		for i := 0; i < p; i++ {
+			out[i] = in[off+i*fstride]
+		}
+	} else {
+		for i := 0; i < p; i++ {
+			f.work(out[i*m:], in, off+i*fstride, fstride*p, fac[2:])
+		}
+	}
+	switch p {
+	case 2:
+		f.bfly2(out, fstride, m)
+	case 3:
+		f.bfly3(out, fstride, m)
+	case 4:
+		f.bfly4(out, fstride, m)
+	case 5:
+		f.bfly5(out, fstride, m)
+	default:
+		f.bflyGeneric(out, fstride, m, p)
+	}
+}
+
+func (f *FFT) bfly2(out []complex128, fstride, m int) {
+	for k := 0; k < m; k++ {
+		t := out[k+m] * f.tw[k*fstride]
+		out[k+m] = out[k] - t
+		out[k] += t
+	}
+}
+
+func (f *FFT) bfly3(out []complex128, fstride, m int) {
+	h := imag(f.tw[fstride*m])
+	for k := 0; k < m; k++ {
+		s1 := out[k+m] * f.tw[k*fstride]
+		s2 := out[k+2*m] * f.tw[2*k*fstride]
+		s3 := s1 + s2
+		s0 := s1 - s2
+		v := out[k] - s3*0.5
+		s0 *= complex(h, 0)
+		out[k] += s3
+		out[k+2*m] = complex(real(v)+imag(s0), imag(v)-real(s0))
+		out[k+m] = complex(real(v)-imag(s0), imag(v)+real(s0))
+	}
+}
+
+func (f *FFT) bfly4(out []complex128, fstride, m int) {
+	for k := 0; k < m; k++ {
+		s0 := out[k+m] * f.tw[k*fstride]
+		s1 := out[k+2*m] * f.tw[2*k*fstride]
+		s2 := out[k+3*m] * f.tw[3*k*fstride]
+		s5 := out[k] - s1
+		out[k] += s1
+		s3 := s0 + s2
+		s4 := s0 - s2
+		out[k+2*m] = out[k] - s3
+		out[k] += s3
+		out[k+m] = complex(real(s5)+imag(s4), imag(s5)-real(s4))
+		out[k+3*m] = complex(real(s5)-imag(s4), imag(s5)+real(s4))
+	}
+}
+
+func (f *FFT) bfly5(out []complex128, fstride, m int) {
+	ya := f.tw[fstride*m]
+	yb := f.tw[2*fstride*m]
+	for u := 0; u < m; u++ {
+		s0 := out[u]
+		s1 := out[u+m] * f.tw[u*fstride]
+		s2 := out[u+2*m] * f.tw[2*u*fstride]
+		s3 := out[u+3*m] * f.tw[3*u*fstride]
+		s4 := out[u+4*m] * f.tw[4*u*fstride]
+		s7 := s1 + s4
+		s10 := s1 - s4
+		s8 := s2 + s3
+		s9 := s2 - s3
+		out[u] = s0 + s7 + s8
+
+		s5 := s0 + complex(real(s7)*real(ya)+real(s8)*real(yb), imag(s7)*real(ya)+imag(s8)*real(yb))
+		s6 := complex(imag(s10)*imag(ya)+imag(s9)*imag(yb), -real(s10)*imag(ya)-real(s9)*imag(yb))
+		out[u+m] = s5 - s6
+		out[u+4*m] = s5 + s6
+
+		s11 := s0 + complex(real(s7)*real(yb)+real(s8)*real(ya), imag(s7)*real(yb)+imag(s8)*real(ya))
+		s12 := complex(-imag(s10)*imag(yb)+imag(s9)*imag(ya), real(s10)*imag(yb)-real(s9)*imag(ya))
+		out[u+2*m] = s11 + s12
+		out[u+3*m] = s11 - s12
+	}
+}
+
+func (f *FFT) bflyGeneric(out []complex128, fstride, m, p int) {
+	scratch := f.scratch[:p]
+	for u := 0; u < m; u++ {
+		for q, k := 0, u; q < p; q, k = q+1, k+m {
+			scratch[q] = out[k]
+		}
+		for q1, k := 0, u; q1 < p; q1, k = q1+1, k+m {
+			idx := 0
+			out[k] = scratch[0]
+			for q := 1; q < p; q++ {
+				idx += fstride * k
+				if idx >= f.n {
+					idx -= f.n
+				}
+				out[k] += scratch[q] * f.tw[idx]
+			}
+		}
+	}
+}
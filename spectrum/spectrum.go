@@ -0,0 +1,220 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package spectrum estimates power spectra of the device sample stream
+// using Welch's averaged, overlapped periodogram method.
+package spectrum
+
+import (
+	"errors"
+
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// Tuner is implemented by *rtlsdr.Context and supplies the values used
+// to map bins to absolute frequencies.
+type Tuner interface {
+	GetCenterFreq() int
+	GetSampleRate() int
+}
+
+// Mode selects how successive estimates are combined.
+type Mode int
+
+// Estimator modes.
+const (
+	Average  Mode = iota // each frame stands alone
+	PeakHold             // per bin maximum since the last Reset
+	MinHold              // per bin minimum since the last Reset
+)
+
+// Config holds the estimator settings.
+type Config struct {
+	Size     int     // FFT length, any length works, powers of two are fastest
+	Window   Window  // default Hann
+	Overlap  float64 // fraction of a segment shared with the next, default 0.5, negative for none
+	Averages int     // segments averaged per frame, default 1
+	Mode     Mode
+
+	// Frequencies come from Tuner when set, read as each frame is
+	// produced, otherwise from CenterFreq and SampleRate.
+	Tuner      Tuner
+	CenterFreq float64
+	SampleRate float64
+
+	// OnFrame, when set, is called with each new frame.
+	OnFrame func(*Spectrum)
+}
+
+// Spectrum is a power spectrum in dBFS per bin, ordered from the lowest
+// to the highest frequency. A full scale complex tone centered in a bin
+// reads 0 dBFS.
+type Spectrum struct {
+	CenterFreq float64
+	SampleRate float64
+	Power      []float64
+}
+
+// BinWidth returns the bin spacing in Hz.
+func (s *Spectrum) BinWidth() float64 {
+	return s.SampleRate / float64(len(s.Power))
+}
+
+// Freq returns the absolute center frequency of bin i.
+func (s *Spectrum) Freq(i int) float64 {
+	return s.CenterFreq + float64(i-len(s.Power)/2)*s.BinWidth()
+}
+
+// Bin returns the bin nearest freq, clipped to the valid range.
+func (s *Spectrum) Bin(freq float64) int {
+	i := int((freq-s.CenterFreq)/s.BinWidth()+0.5) + len(s.Power)/2
+	switch {
+	case i < 0:
+		return 0
+	case i >= len(s.Power):
+		return len(s.Power) - 1
+	}
+	return i
+}
+
+// Estimator turns a sample stream into power spectrum frames.
+type Estimator struct {
+	cfg   Config
+	fft   *FFT
+	win   []float64
+	scale float64
+	hop   int
+
+	in   []complex64
+	tmp  []complex64
+	seg  []complex128
+	acc  []float64
+	held []float64
+	segs int
+	hold bool
+
+	last *Spectrum
+}
+
+// New returns an estimator for the given configuration.
+func New(cfg Config) (*Estimator, error) {
+	if cfg.Size < 2 {
+		return nil, errors.New("invalid fft size")
+	}
+	if cfg.Overlap >= 1 {
+		return nil, errors.New("overlap must be less than 1")
+	}
+	switch {
+	case cfg.Overlap == 0:
+		cfg.Overlap = 0.5
+	case cfg.Overlap < 0:
+		cfg.Overlap = 0
+	}
+	if cfg.Averages < 1 {
+		cfg.Averages = 1
+	}
+	fft, err := NewFFT(cfg.Size)
+	if err != nil {
+		return nil, err
+	}
+	e := &Estimator{
+		cfg:  cfg,
+		fft:  fft,
+		win:  cfg.Window.Coefficients(cfg.Size),
+		hop:  int(float64(cfg.Size)*(1-cfg.Overlap) + 0.5),
+		seg:  make([]complex128, cfg.Size),
+		acc:  make([]float64, cfg.Size),
+		held: make([]float64, cfg.Size),
+	}
+	if e.hop < 1 {
+		e.hop = 1
+	}
+	sum := 0.0
+	for _, v := range e.win {
+		sum += v
+	}
+	e.scale = 1 / (sum * sum)
+	return e, nil
+}
+
+// Write processes interleaved cu8 samples, e.g. ReadSync buffers.
+func (e *Estimator) Write(buf []byte) (int, error) {
+	e.tmp = iq.FromCU8(e.tmp, buf)
+	return len(buf), e.WriteSamples(e.tmp)
+}
+
+// WriteSamples processes complex samples.
+func (e *Estimator) WriteSamples(x []complex64) error {
+	e.in = append(e.in, x...)
+	n := e.cfg.Size
+	i := 0
+	for ; i+n <= len(e.in); i += e.hop {
+		e.segment(e.in[i : i+n])
+	}
+	e.in = e.in[:copy(e.in, e.in[i:])]
+	return nil
+}
+
+// segment adds the periodogram of one windowed segment.
+func (e *Estimator) segment(x []complex64) {
+	for i, v := range x {
+		e.seg[i] = complex128(v) * complex(e.win[i], 0)
+	}
+	e.fft.Transform(e.seg, e.seg)
+	for i, v := range e.seg {
+		e.acc[i] += real(v)*real(v) + imag(v)*imag(v)
+	}
+	e.segs++
+	if e.segs == e.cfg.Averages {
+		e.frame()
+	}
+}
+
+// frame completes an averaged estimate.
+func (e *Estimator) frame() {
+	n := e.cfg.Size
+	k := e.scale / float64(e.segs)
+	for i := range e.acc {
+		p := e.acc[i] * k
+		switch {
+		case !e.hold, e.cfg.Mode == Average:
+			e.held[i] = p
+		case e.cfg.Mode == PeakHold && p > e.held[i]:
+			e.held[i] = p
+		case e.cfg.Mode == MinHold && p < e.held[i]:
+			e.held[i] = p
+		}
+		e.acc[i] = 0
+	}
+	e.hold = true
+	e.segs = 0
+
+	s := &Spectrum{
+		CenterFreq: e.cfg.CenterFreq,
+		SampleRate: e.cfg.SampleRate,
+		Power:      make([]float64, n),
+	}
+	if e.cfg.Tuner != nil {
+		s.CenterFreq = float64(e.cfg.Tuner.GetCenterFreq())
+		s.SampleRate = float64(e.cfg.Tuner.GetSampleRate())
+	}
+	// reorder so negative frequencies come first
+	for i := range s.Power {
+		s.Power[i] = iq.DB(e.held[(i+n-n/2)%n])
+	}
+	e.last = s
+	if e.cfg.OnFrame != nil {
+		e.cfg.OnFrame(s)
+	}
+}
+
+// Latest returns the most recent frame, or nil before the first one.
+func (e *Estimator) Latest() *Spectrum {
+	return e.last
+}
+
+// Reset clears the peak or min hold state.
+func (e *Estimator) Reset() {
+	e.hold = false
+}
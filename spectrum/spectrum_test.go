@@ -0,0 +1,32 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package spectrum
+
+import "testing"
+
+func TestOverlap(t *testing.T) {
+	for _, c := range []struct {
+		overlap float64
+		hop     int // 0 for an invalid overlap
+	}{
+		{0, 512},
+		{-1, 1024},
+		{0.75, 256},
+		{0.999, 1},
+		{1, 0},
+	} {
+		e, err := New(Config{Size: 1024, Overlap: c.overlap})
+		switch {
+		case c.hop == 0:
+			if err == nil {
+				t.Errorf("overlap %v: got no error", c.overlap)
+			}
+		case err != nil:
+			t.Errorf("overlap %v: %v", c.overlap, err)
+		case e.hop != c.hop:
+			t.Errorf("overlap %v: got a hop of %d, want %d", c.overlap, e.hop, c.hop)
+		}
+	}
+}
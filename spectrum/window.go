@@ -0,0 +1,69 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package spectrum
+
+import "math"
+
+// Window is a spectral analysis window function.
+type Window int
+
+// Window functions.
+const (
+	Hann           Window = iota
+	BlackmanHarris        // 4-term, -92 dB sidelobes
+	FlatTop               // accurate tone amplitudes, wide main lobe
+	Rectangular
+)
+
+var windowNames = map[Window]string{
+	Rectangular:    "rectangular",
+	Hann:           "hann",
+	BlackmanHarris: "blackman-harris",
+	FlatTop:        "flat-top",
+}
+
+func (w Window) String() string {
+	if n, ok := windowNames[w]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// cosine sum coefficients, w[i] = a0 - a1 cos(x) + a2 cos(2x) - ...
+var windowCoefs = map[Window][]float64{
+	Rectangular:    {1},
+	Hann:           {0.5, 0.5},
+	BlackmanHarris: {0.35875, 0.48829, 0.14128, 0.01168},
+	FlatTop:        {0.21557895, 0.41663158, 0.277263158, 0.083578947, 0.006947368},
+}
+
+// Coefficients returns the periodic (DFT-even) window of length n.
+func (w Window) Coefficients(n int) []float64 {
+	a, ok := windowCoefs[w]
+	if !ok {
+		a = windowCoefs[Rectangular]
+	}
+	c := make([]float64, n)
+	for i := range c {
+		x := 2 * math.Pi * float64(i) / float64(n)
+		sign := 1.0
+		for k, v := range a {
+			c[i] += sign * v * math.Cos(float64(k)*x)
+			sign = -sign
+		}
+	}
+	return c
+}
+
+// ENBW returns the equivalent noise bandwidth of window coefficients c
+// in bins.
+func ENBW(c []float64) float64 {
+	var sum, sq float64
+	for _, v := range c {
+		sum += v
+		sq += v * v
+	}
+	return float64(len(c)) * sq / (sum * sum)
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package tune implements software offset tuning. The hardware is
+// tuned a fixed offset away from the requested frequency and the stream
+// is shifted back in software, so the signal of interest stays clear of
+// the DC spike. Unlike SetOffsetTuning, which only the E4000 supports,
+// it works with every tuner.
+package tune
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jpoirier/gortlsdr/dsp"
+	"github.com/jpoirier/gortlsdr/iq"
+)
+
+// Device is implemented by *rtlsdr.Context.
+type Device interface {
+	SetCenterFreq(freqHz int) error
+	GetCenterFreq() int
+	GetSampleRate() int
+	GetTunerType() string
+}
+
+// Config holds the offset tuning settings.
+type Config struct {
+	// Offset is how far above the requested frequency the hardware is
+	// tuned, negative values tune below, default a quarter of the
+	// sample rate.
+	Offset int
+
+	// Bandwidth is the width, in Hz, kept around the requested
+	// frequency, default Offset. Everything from Offset away is
+	// filtered out, which takes the DC spike with it.
+	Bandwidth float64
+
+	// DCTime is the time constant of the DC blocker run before the
+	// shift, default 100ms, negative disables it.
+	DCTime time.Duration
+}
+
+// Tuner wraps a Device in offset tuning mode. It implements Device
+// itself, reporting the requested frequency, so it can stand in for the
+// device wherever frequencies are read, e.g. a spectrum.Estimator.
+type Tuner struct {
+	dev    Device
+	offset int
+	freq   int
+
+	dc  *dsp.DCBlocker
+	nco *dsp.NCO
+	fir *dsp.FIRDecimator
+	tmp []complex64
+}
+
+// New returns an offset tuner for dev at its current sample rate. The
+// hardware is retuned on the next SetCenterFreq; make a new Tuner after
+// changing the sample rate.
+func New(dev Device, cfg Config) (*Tuner, error) {
+	fs := float64(dev.GetSampleRate())
+	if fs <= 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+	if cfg.Offset == 0 {
+		cfg.Offset = int(fs / 4)
+	}
+	off := math.Abs(float64(cfg.Offset))
+	if cfg.Bandwidth == 0 {
+		cfg.Bandwidth = off
+	}
+	if cfg.Bandwidth <= 0 || cfg.Bandwidth/2 >= off || off+cfg.Bandwidth/2 >= fs/2 {
+		return nil, errors.New("offset and bandwidth don't fit the sample rate")
+	}
+	if cfg.DCTime == 0 {
+		cfg.DCTime = 100 * time.Millisecond
+	}
+	t := &Tuner{
+		dev:    dev,
+		offset: cfg.Offset,
+		freq:   dev.GetCenterFreq() - cfg.Offset,
+		nco:    dsp.NewNCO(fs, float64(cfg.Offset)),
+		fir:    dsp.NewFIRDecimator(dsp.LowpassKaiser(cfg.Bandwidth/2/fs, off/fs, 60), 1),
+	}
+	if cfg.DCTime > 0 {
+		t.dc = dsp.NewDCBlocker(fs, cfg.DCTime)
+	}
+	return t, nil
+}
+
+// SetCenterFreq tunes the hardware to freqHz plus the offset.
+func (t *Tuner) SetCenterFreq(freqHz int) error {
+	if err := t.dev.SetCenterFreq(freqHz + t.offset); err != nil {
+		return err
+	}
+	t.freq = freqHz
+	return nil
+}
+
+// GetCenterFreq returns the requested frequency, which is the center of
+// the processed stream.
+func (t *Tuner) GetCenterFreq() int {
+	return t.freq
+}
+
+// GetSampleRate returns the device sample rate.
+func (t *Tuner) GetSampleRate() int {
+	return t.dev.GetSampleRate()
+}
+
+// GetTunerType returns the device tuner type.
+func (t *Tuner) GetTunerType() string {
+	return t.dev.GetTunerType()
+}
+
+// Offset returns the hardware tuning offset in Hz.
+func (t *Tuner) Offset() int {
+	return t.offset
+}
+
+// Process shifts the requested frequency from the hardware stream in
+// src to 0 Hz and filters out the DC region, writing the result to
+// dst.
+func (t *Tuner) Process(dst, src []complex64) []complex64 {
+	x := src
+	if t.dc != nil {
+		t.tmp = t.dc.Process(t.tmp, x)
+		x = t.tmp
+	}
+	t.tmp = t.nco.Mix(t.tmp, x)
+	return t.fir.Process(dst, t.tmp)
+}
+
+// Callback returns a ReadAsync callback that converts cu8 buffers and
+// passes the processed samples to f. The slice passed to f is reused.
+func (t *Tuner) Callback(f func([]complex64)) func([]byte) {
+	var in, out []complex64
+	return func(buf []byte) {
+		in = iq.FromCU8(in, buf)
+		out = t.Process(out, in)
+		f(out)
+	}
+}
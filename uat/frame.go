@@ -0,0 +1,67 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package uat
+
+import (
+	"encoding/hex"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Frame is a received UAT frame after error correction.
+type Frame struct {
+	Time   time.Time
+	Uplink bool   // a ground uplink, else an ADS-B message
+	Data   []byte // 18 or 34 bytes of ADS-B payload, 432 of uplink
+	Errors int    // bytes corrected
+}
+
+// PayloadType returns the payload type code of an ADS-B message, 0 for
+// basic messages, or -1 for an uplink.
+func (f *Frame) PayloadType() int {
+	if f.Uplink {
+		return -1
+	}
+	return int(f.Data[0] >> 3)
+}
+
+// Address returns the address of an ADS-B message and its qualifier,
+// 0 for an ICAO address, or a qualifier of -1 for an uplink.
+func (f *Frame) Address() (addr uint32, qualifier int) {
+	if f.Uplink {
+		return 0, -1
+	}
+	return uint32(f.Data[1])<<16 | uint32(f.Data[2])<<8 | uint32(f.Data[3]), int(f.Data[0] & 7)
+}
+
+// String formats f as dump978 does, "-" for ADS-B or "+" for uplink
+// frames followed by the data in hex and ";", with the count of
+// corrected bytes as "rs=N;" when there were any.
+func (f *Frame) String() string {
+	b := make([]byte, 0, 2*len(f.Data)+10)
+	if f.Uplink {
+		b = append(b, '+')
+	} else {
+		b = append(b, '-')
+	}
+	b = append(b, hex.EncodeToString(f.Data)...)
+	b = append(b, ';')
+	if f.Errors > 0 {
+		b = append(b, "rs="...)
+		b = strconv.AppendInt(b, int64(f.Errors), 10)
+		b = append(b, ';')
+	}
+	return string(b)
+}
+
+// Lines returns a frame handler writing each frame to w in dump978's
+// format, one line per frame, for uat2json, uat2text and other dump978
+// tools to read.
+func Lines(w io.Writer) func(Frame) {
+	return func(f Frame) {
+		io.WriteString(w, f.String()+"\n")
+	}
+}
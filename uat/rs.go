@@ -0,0 +1,149 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package uat
+
+// UAT frames are protected by shortened Reed-Solomon codes over GF(256)
+// with the field polynomial x^8+x^7+x^2+x+1 and the generator roots
+// starting at alpha^120, the parameters dump978 passes to libfec.
+
+const (
+	gfPoly = 0x187
+	fcr    = 120
+)
+
+var (
+	gfExp [512]byte // doubled so products need no reduction
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPoly
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+255-gfLog[b]]
+}
+
+// gfPow returns alpha^e.
+func gfPow(e int) byte {
+	e %= 255
+	if e < 0 {
+		e += 255
+	}
+	return gfExp[e]
+}
+
+// rsDecode corrects the codeword c in place, the data followed by
+// nroots parity bytes, returning the number of bytes corrected or -1
+// when there are more errors than the code can correct.
+func rsDecode(c []byte, nroots int) int {
+	n := len(c)
+
+	// syndromes, c[0] being the coefficient of x^(n-1)
+	s := make([]byte, nroots)
+	var bad bool
+	for i := range s {
+		r := gfPow(fcr + i)
+		var v byte
+		for _, b := range c {
+			v = gfMul(v, r) ^ b
+		}
+		s[i] = v
+		bad = bad || v != 0
+	}
+	if !bad {
+		return 0
+	}
+
+	// Berlekamp-Massey for the error locator
+	lambda := make([]byte, nroots+1)
+	prev := make([]byte, nroots+1)
+	lambda[0], prev[0] = 1, 1
+	l, m, b := 0, 1, byte(1)
+	for k := 0; k < nroots; k++ {
+		d := s[k]
+		for i := 1; i <= l; i++ {
+			d ^= gfMul(lambda[i], s[k-i])
+		}
+		if d == 0 {
+			m++
+			continue
+		}
+		coef := gfDiv(d, b)
+		t := append([]byte(nil), lambda...)
+		for i := m; i <= nroots; i++ {
+			lambda[i] ^= gfMul(coef, prev[i-m])
+		}
+		if 2*l <= k {
+			l, prev, b, m = k+1-l, t, d, 1
+		} else {
+			m++
+		}
+	}
+	if 2*l > nroots {
+		return -1
+	}
+
+	// error evaluator, omega = s*lambda mod x^nroots
+	omega := make([]byte, nroots)
+	for i := range omega {
+		for j := 0; j <= i && j <= l; j++ {
+			omega[i] ^= gfMul(s[i-j], lambda[j])
+		}
+	}
+
+	// Chien search over the unshortened positions and Forney
+	found := 0
+	for j := 0; j < n; j++ {
+		e := n - 1 - j
+		xinv := gfPow(-e)
+		var lv, dv, ov byte
+		for i := l; i >= 0; i-- {
+			lv = gfMul(lv, xinv) ^ lambda[i]
+		}
+		if lv != 0 {
+			continue
+		}
+		// the formal derivative keeps the odd terms
+		x2 := gfMul(xinv, xinv)
+		for i := l | 1; i >= 1; i -= 2 {
+			dv = gfMul(dv, x2) ^ lambda[i]
+		}
+		for i := nroots - 1; i >= 0; i-- {
+			ov = gfMul(ov, xinv) ^ omega[i]
+		}
+		if dv == 0 {
+			return -1
+		}
+		// e = X^(1-fcr) omega(1/X) / lambda'(1/X)
+		c[j] ^= gfMul(gfPow(e*(1-fcr)), gfDiv(ov, dv))
+		found++
+	}
+	if found != l {
+		return -1
+	}
+	return found
+}
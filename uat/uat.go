@@ -0,0 +1,308 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package uat decodes Universal Access Transceiver frames on 978 MHz,
+// the ADS-B messages sent by aircraft and the uplinks from ground
+// stations, from samples at 2083334 S/s, two samples per bit.
+//
+// The signal is continuous phase FSK at 1.041667 Mbit/s with a
+// modulation index of 0.6. Each bit is the sign of the phase change
+// over it, so the samples are reduced to their phase and every sample
+// position checked for the 36-bit sync word of either frame type, the
+// decision threshold taken from the sync word to allow for frequency
+// offset. The bits that follow are checked and corrected with the
+// Reed-Solomon codes of DO-282B, and the frames written in dump978's
+// text format.
+package uat
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// SampleRate is the sample rate the decoder works at, two samples per
+// bit.
+const SampleRate = 2083334
+
+// Sync words, the uplink's being the complement of the ADS-B one.
+const (
+	syncBits   = 36
+	syncADSB   = 0xeacdda4e2
+	syncUplink = 0x153225b1d
+	syncMask   = 1<<syncBits - 1
+
+	// sync bits that may differ from the word, before and after
+	// allowing for the frequency offset
+	syncCoarse = 8
+	syncErrors = 4
+)
+
+// Frame sizes in bytes, the data before the Reed-Solomon parity.
+const (
+	shortData  = 18
+	shortBytes = 30
+	longData   = 34
+	longBytes  = 48
+
+	// uplinks are 6 interleaved blocks
+	uplinkBlocks     = 6
+	uplinkBlockData  = 72
+	uplinkBlockBytes = 92
+	uplinkData       = uplinkBlocks * uplinkBlockData
+	uplinkBytes      = uplinkBlocks * uplinkBlockBytes
+)
+
+// Config holds the decoder settings.
+type Config struct {
+	// OnFrame is called with each frame decoded, see Lines.
+	OnFrame func(Frame)
+}
+
+// Decoder finds and decodes UAT frames.
+type Decoder struct {
+	cfg Config
+
+	buf   []float32 // phases, the tail of the last block first
+	start time.Time // time of buf[0]
+	dphi  []float32 // phase change over a bit at each sample
+	reg   [2]uint64 // sliced bits on either sample phase
+	raw   []byte    // sliced frame before correction
+}
+
+// NewDecoder returns a decoder for the given configuration.
+func NewDecoder(cfg Config) *Decoder {
+	return &Decoder{cfg: cfg, raw: make([]byte, uplinkBytes)}
+}
+
+// Phase computes the phase of each sample into dst.
+func Phase(dst []float32, src []complex64) []float32 {
+	if cap(dst) < len(src) {
+		dst = make([]float32, len(src))
+	}
+	dst = dst[:len(src)]
+	for i, v := range src {
+		dst[i] = float32(math.Atan2(float64(imag(v)), float64(real(v))))
+	}
+	return dst
+}
+
+var cu8Phase [1 << 16]float32
+
+func init() {
+	for i := range cu8Phase {
+		re := float64(i>>8) - 127.5
+		im := float64(i&0xff) - 127.5
+		cu8Phase[i] = float32(math.Atan2(im, re))
+	}
+}
+
+// PhaseCU8 computes the phase of each sample of an unsigned 8-bit
+// interleaved buffer, as read from the device, into dst.
+func PhaseCU8(dst []float32, buf []byte) []float32 {
+	n := len(buf) / 2
+	if cap(dst) < n {
+		dst = make([]float32, n)
+	}
+	dst = dst[:n]
+	for i := range dst {
+		dst[i] = cu8Phase[int(buf[2*i])<<8|int(buf[2*i+1])]
+	}
+	return dst
+}
+
+// Process decodes the phase samples in phase, t being the time of the
+// first sample.
+func (d *Decoder) Process(phase []float32, t time.Time) {
+	if len(d.buf) == 0 {
+		d.start = t
+	} else {
+		d.start = t.Add(-time.Duration(float64(len(d.buf)) / SampleRate * 1e9))
+	}
+	d.buf = append(d.buf, phase...)
+
+	// the phase change over each bit length, a bit being ±0.6π
+	n := len(d.buf) - 2
+	if n < 0 {
+		n = 0
+	}
+	if cap(d.dphi) < n {
+		d.dphi = make([]float32, n)
+	}
+	d.dphi = d.dphi[:n]
+	for k := range d.dphi {
+		v := d.buf[k+2] - d.buf[k]
+		if v > math.Pi {
+			v -= 2 * math.Pi
+		} else if v < -math.Pi {
+			v += 2 * math.Pi
+		}
+		d.dphi[k] = v
+	}
+
+	// the sync word and the longest frame after it
+	need := 2 * (syncBits + uplinkBytes*8)
+	fill := true
+	k := 0
+	for ; k+need <= len(d.dphi); k++ {
+		if fill {
+			// all but the last bit of the sync word on both phases
+			d.reg = [2]uint64{}
+			for j := k; j < k+2*(syncBits-1); j++ {
+				d.push(j)
+			}
+			fill = false
+		}
+		last := k + 2*(syncBits-1)
+		d.push(last)
+		reg := d.reg[last&1]
+		for _, up := range [...]bool{false, true} {
+			word := uint64(syncADSB)
+			if up {
+				word = syncUplink
+			}
+			if bits.OnesCount64(reg^word) > syncCoarse {
+				continue
+			}
+			f, ok := d.frame(k, word, up)
+			if !ok {
+				continue
+			}
+			f.Time = d.start.Add(time.Duration(float64(k) / SampleRate * 1e9))
+			if d.cfg.OnFrame != nil {
+				d.cfg.OnFrame(f)
+			}
+			n := uplinkBytes
+			switch {
+			case !up && len(f.Data) == longData:
+				n = longBytes
+			case !up:
+				n = shortBytes
+			}
+			k += 2*(syncBits+n*8) - 1
+			fill = true
+			break
+		}
+	}
+	d.buf = d.buf[:copy(d.buf, d.buf[k:])]
+}
+
+// push shifts the bit sliced at sample j into the register of its
+// sample phase.
+func (d *Decoder) push(j int) {
+	p := j & 1
+	d.reg[p] = d.reg[p] << 1 & syncMask
+	if d.dphi[j] > 0 {
+		d.reg[p] |= 1
+	}
+}
+
+// frame checks the sync word starting at sample start for the word
+// and decodes the frame following it, trying the neighbouring sample
+// as well since a bit spans two.
+func (d *Decoder) frame(start int, word uint64, uplink bool) (Frame, bool) {
+	var off [2]int
+	var sep [2]float32
+	var center [2]float32
+	for j := range off {
+		off[j] = start + j
+		var one, zero float32
+		var nOne, nZero int
+		for b := 0; b < syncBits; b++ {
+			v := d.dphi[off[j]+2*b]
+			if word>>(syncBits-1-uint(b))&1 != 0 {
+				one += v
+				nOne++
+			} else {
+				zero += v
+				nZero++
+			}
+		}
+		one /= float32(nOne)
+		zero /= float32(nZero)
+		center[j], sep[j] = (one+zero)/2, one-zero
+	}
+	if sep[1] > sep[0] {
+		off[0], off[1] = off[1], off[0]
+		center[0], center[1] = center[1], center[0]
+		sep[0], sep[1] = sep[1], sep[0]
+	}
+	for j := range off {
+		if sep[j] <= 0 {
+			break
+		}
+		var w uint64
+		for b := 0; b < syncBits; b++ {
+			w <<= 1
+			if d.dphi[off[j]+2*b] > center[j] {
+				w |= 1
+			}
+		}
+		if bits.OnesCount64(w^word) > syncErrors {
+			continue
+		}
+		data := off[j] + 2*syncBits
+		if uplink {
+			if f, ok := d.uplink(data, center[j]); ok {
+				return f, true
+			}
+		} else if f, ok := d.adsb(data, center[j]); ok {
+			return f, true
+		}
+	}
+	return Frame{}, false
+}
+
+// slice fills d.raw with n bytes of bits starting at sample i.
+func (d *Decoder) slice(i, n int, center float32) []byte {
+	raw := d.raw[:n]
+	for k := range raw {
+		var v byte
+		for b := 0; b < 8; b++ {
+			v <<= 1
+			if d.dphi[i] > center {
+				v |= 1
+			}
+			i += 2
+		}
+		raw[k] = v
+	}
+	return raw
+}
+
+// adsb decodes an ADS-B frame, long or basic, at sample i. The payload
+// type code in the first 5 bits tells them apart, 0 for basic frames.
+func (d *Decoder) adsb(i int, center float32) (Frame, bool) {
+	raw := d.slice(i, longBytes, center)
+	c := append([]byte(nil), raw...)
+	if n := rsDecode(c, longBytes-longData); n >= 0 && c[0]>>3 != 0 {
+		return Frame{Data: c[:longData], Errors: n}, true
+	}
+	c = append(c[:0], raw[:shortBytes]...)
+	if n := rsDecode(c, shortBytes-shortData); n >= 0 && c[0]>>3 == 0 {
+		return Frame{Data: c[:shortData], Errors: n}, true
+	}
+	return Frame{}, false
+}
+
+// uplink decodes a ground uplink frame at sample i, whose blocks are
+// interleaved byte by byte.
+func (d *Decoder) uplink(i int, center float32) (Frame, bool) {
+	raw := d.slice(i, uplinkBytes, center)
+	data := make([]byte, 0, uplinkData)
+	block := make([]byte, uplinkBlockBytes)
+	errs := 0
+	for b := 0; b < uplinkBlocks; b++ {
+		for k := range block {
+			block[k] = raw[k*uplinkBlocks+b]
+		}
+		n := rsDecode(block, uplinkBlockBytes-uplinkBlockData)
+		if n < 0 {
+			return Frame{}, false
+		}
+		errs += n
+		data = append(data, block[:uplinkBlockData]...)
+	}
+	return Frame{Uplink: true, Data: data, Errors: errs}, true
+}
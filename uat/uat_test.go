@@ -0,0 +1,168 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package uat
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// rsEncode returns data followed by its nroots systematic parity bytes.
+func rsEncode(data []byte, nroots int) []byte {
+	// the generator polynomial, highest power first
+	g := []byte{1}
+	for i := 0; i < nroots; i++ {
+		r := gfPow(fcr + i)
+		next := make([]byte, len(g)+1)
+		for j, v := range g {
+			next[j] ^= v
+			next[j+1] ^= gfMul(v, r)
+		}
+		g = next
+	}
+	rem := make([]byte, nroots)
+	for _, v := range data {
+		fb := v ^ rem[0]
+		copy(rem, rem[1:])
+		rem[nroots-1] = 0
+		for j := range rem {
+			rem[j] ^= gfMul(fb, g[j+1])
+		}
+	}
+	return append(append([]byte(nil), data...), rem...)
+}
+
+func TestRSDecode(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range []struct{ n, k int }{
+		{shortBytes, shortData},
+		{longBytes, longData},
+		{uplinkBlockBytes, uplinkBlockData},
+	} {
+		nroots := c.n - c.k
+		for errs := 0; errs <= nroots/2; errs++ {
+			for trial := 0; trial < 20; trial++ {
+				data := make([]byte, c.k)
+				r.Read(data)
+				want := rsEncode(data, nroots)
+				got := append([]byte(nil), want...)
+				for _, p := range r.Perm(c.n)[:errs] {
+					got[p] ^= byte(1 + r.Intn(255))
+				}
+				if n := rsDecode(got, nroots); n != errs || !bytes.Equal(got, want) {
+					t.Fatalf("(%d,%d) with %d errors: corrected %d, %x, want %x", c.n, c.k, errs, n, got, want)
+				}
+			}
+		}
+	}
+}
+
+// bitsOf returns the bits of the sync word followed by those of b,
+// most significant first.
+func bitsOf(sync uint64, b []byte) []byte {
+	var v []byte
+	for i := syncBits - 1; i >= 0; i-- {
+		v = append(v, byte(sync>>uint(i)&1))
+	}
+	for _, x := range b {
+		for i := 7; i >= 0; i-- {
+			v = append(v, x>>uint(i)&1)
+		}
+	}
+	return v
+}
+
+// modulate returns the bits as CPFSK with a modulation index of 0.6 at
+// two samples per bit, offset by f Hz, with idle gaps of silence
+// between them and small noise.
+func modulate(frames [][]byte, f float64, r *rand.Rand) []complex64 {
+	const gap = 600 // samples
+	var x []complex64
+	var phase float64
+	noise := func() float32 { return float32(0.02 * r.NormFloat64()) }
+	for _, b := range append(frames, nil) {
+		for i := 0; i < gap; i++ {
+			x = append(x, complex(noise(), noise()))
+		}
+		for _, v := range b {
+			for s := 0; s < 2; s++ {
+				if v == 1 {
+					phase += 0.3 * math.Pi
+				} else {
+					phase -= 0.3 * math.Pi
+				}
+				p := phase + 2*math.Pi*f*float64(len(x))/SampleRate
+				x = append(x, complex(float32(0.5*math.Cos(p))+noise(), float32(0.5*math.Sin(p))+noise()))
+			}
+		}
+	}
+	return x
+}
+
+func TestDecode(t *testing.T) {
+	short, _ := hex.DecodeString("00a9c84e21e5c3a5a0f1b2350b8818000000")
+	long, _ := hex.DecodeString("0ba1b2c3358d2a7c5e4210d9e000c0ffee3e2805160a000000000000000000000012")
+	up := make([]byte, uplinkData)
+	for i := range up {
+		up[i] = byte(i * 7)
+	}
+
+	// two bytes of the short frame and three of the long one in error
+	sc := rsEncode(short, shortBytes-shortData)
+	sc[3] ^= 0x55
+	sc[29] ^= 0x01
+	lc := rsEncode(long, longBytes-longData)
+	lc[0] ^= 0x80
+	lc[20] ^= 0xff
+	lc[40] ^= 0x10
+	uc := make([]byte, uplinkBytes)
+	for b := 0; b < uplinkBlocks; b++ {
+		c := rsEncode(up[b*uplinkBlockData:(b+1)*uplinkBlockData], uplinkBlockBytes-uplinkBlockData)
+		for k, v := range c {
+			uc[k*uplinkBlocks+b] = v
+		}
+	}
+	frames := [][]byte{
+		bitsOf(syncADSB, lc),
+		bitsOf(syncADSB, sc),
+		bitsOf(syncUplink, uc),
+	}
+	want := []string{
+		"-0ba1b2c3358d2a7c5e4210d9e000c0ffee3e2805160a000000000000000000000012;rs=3;",
+		"-00a9c84e21e5c3a5a0f1b2350b8818000000;rs=2;",
+		"+" + hex.EncodeToString(up) + ";",
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for _, f := range []float64{0, 30e3, -50e3} {
+		var got []string
+		d := NewDecoder(Config{OnFrame: func(f Frame) { got = append(got, f.String()) }})
+		ph := Phase(nil, modulate(frames, f, r))
+		// in uneven blocks, so frames straddle them
+		for i := 0; i < len(ph); i += 3001 {
+			e := i + 3001
+			if e > len(ph) {
+				e = len(ph)
+			}
+			d.Process(ph[i:e], time.Time{})
+		}
+		// the decoder holds back the length of an uplink
+		d.Process(make([]float32, 2*(syncBits+uplinkBytes*8)), time.Time{})
+
+		if len(got) != len(want) {
+			t.Errorf("%v Hz: got %d frames, want %d", f, len(got), len(want))
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%v Hz: got %s, want %s", f, got[i], want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+// Package wav reads and writes RIFF WAVE files holding 8-bit unsigned,
+// 16-bit signed or 32-bit float samples, e.g. two channel I/Q captures
+// or PCM audio.
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+const (
+	formatPCM        = 1
+	formatFloat      = 3
+	formatExtensible = 0xfffe
+)
+
+// maxSize is written to the header when the output can't be rewound
+// to patch in the real data size.
+const maxSize = 0xffffffff
+
+// Format describes the sample layout of a WAVE file.
+type Format struct {
+	Channels      int
+	SampleRate    int
+	BitsPerSample int  // 8, 16 or 32
+	Float         bool // IEEE float, BitsPerSample must be 32
+}
+
+func (f Format) valid() error {
+	switch {
+	case f.Channels < 1:
+		return errors.New("invalid channel count")
+	case f.SampleRate < 1:
+		return errors.New("invalid sample rate")
+	case f.Float && f.BitsPerSample != 32:
+		return errors.New("float samples must be 32 bits")
+	case !f.Float && f.BitsPerSample != 8 && f.BitsPerSample != 16:
+		return errors.New("unsupported bits per sample")
+	}
+	return nil
+}
+
+// FrameSize returns the size in bytes of one sample for all channels.
+func (f Format) FrameSize() int {
+	return f.Channels * f.BitsPerSample / 8
+}
+
+// Reader reads the sample data of a WAVE file.
+type Reader struct {
+	Format
+	r         io.Reader
+	remaining int64
+}
+
+// NewReader parses the WAVE header and returns a reader positioned at
+// the start of the sample data.
+func NewReader(r io.Reader) (*Reader, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAVE file")
+	}
+	rd := &Reader{r: r}
+	haveFmt := false
+	for {
+		var ch [8]byte
+		if _, err := io.ReadFull(r, ch[:]); err != nil {
+			return nil, err
+		}
+		size := int64(binary.LittleEndian.Uint32(ch[4:]))
+		switch string(ch[0:4]) {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("invalid fmt chunk")
+			}
+			b := make([]byte, size+size&1)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, err
+			}
+			tag := binary.LittleEndian.Uint16(b[0:])
+			if tag == formatExtensible && size >= 26 {
+				tag = binary.LittleEndian.Uint16(b[24:])
+			}
+			rd.Channels = int(binary.LittleEndian.Uint16(b[2:]))
+			rd.SampleRate = int(binary.LittleEndian.Uint32(b[4:]))
+			rd.BitsPerSample = int(binary.LittleEndian.Uint16(b[14:]))
+			switch tag {
+			case formatPCM:
+			case formatFloat:
+				rd.Float = true
+			default:
+				return nil, errors.New("unsupported WAVE format")
+			}
+			if err := rd.valid(); err != nil {
+				return nil, err
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			rd.remaining = size
+			if size == maxSize {
+				rd.remaining = math.MaxInt64
+			}
+			return rd, nil
+		default:
+			if _, err := io.CopyN(ioutil.Discard, r, size+size&1); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Read reads raw little endian sample data.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// Writer writes sample data to a WAVE file. When the underlying writer
+// is an io.WriteSeeker the header sizes are patched by Close, otherwise
+// they are set to the maximum so streaming readers keep going.
+type Writer struct {
+	Format
+	w    io.Writer
+	size int64
+}
+
+// NewWriter writes a WAVE header for format f and returns a writer for
+// the sample data.
+func NewWriter(w io.Writer, f Format) (*Writer, error) {
+	if err := f.valid(); err != nil {
+		return nil, err
+	}
+	wr := &Writer{Format: f, w: w}
+	if err := wr.header(maxSize); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+func (w *Writer) header(dataSize uint32) error {
+	tag := uint16(formatPCM)
+	if w.Float {
+		tag = formatFloat
+	}
+	riffSize := uint32(maxSize)
+	if dataSize != maxSize {
+		// the data chunk is padded to an even length
+		riffSize = 36 + dataSize + dataSize&1
+	}
+	var b [44]byte
+	copy(b[0:], "RIFF")
+	binary.LittleEndian.PutUint32(b[4:], riffSize)
+	copy(b[8:], "WAVE")
+	copy(b[12:], "fmt ")
+	binary.LittleEndian.PutUint32(b[16:], 16)
+	binary.LittleEndian.PutUint16(b[20:], tag)
+	binary.LittleEndian.PutUint16(b[22:], uint16(w.Channels))
+	binary.LittleEndian.PutUint32(b[24:], uint32(w.SampleRate))
+	binary.LittleEndian.PutUint32(b[28:], uint32(w.SampleRate*w.FrameSize()))
+	binary.LittleEndian.PutUint16(b[32:], uint16(w.FrameSize()))
+	binary.LittleEndian.PutUint16(b[34:], uint16(w.BitsPerSample))
+	copy(b[36:], "data")
+	binary.LittleEndian.PutUint32(b[40:], dataSize)
+	_, err := w.w.Write(b[:])
+	return err
+}
+
+// Write writes raw little endian sample data.
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close pads the data chunk and, when possible, patches the header
+// sizes. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if w.size&1 != 0 {
+		if _, err := w.w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	ws, ok := w.w.(io.WriteSeeker)
+	if !ok || w.size >= maxSize-36 {
+		return nil
+	}
+	if _, err := ws.Seek(0, io.SeekStart); err != nil {
+		// not seekable after all, e.g. a pipe
+		return nil
+	}
+	if err := w.header(uint32(w.size)); err != nil {
+		return err
+	}
+	_, err := ws.Seek(0, io.SeekEnd)
+	return err
+}
+
+// PCM16 appends samples in the range [-1, 1] to dst as 16-bit little
+// endian PCM, clipping those outside it.
+func PCM16(dst []byte, x []float32) []byte {
+	for _, v := range x {
+		s := math.Floor(float64(v)*32767 + 0.5)
+		if s > 32767 {
+			s = 32767
+		} else if s < -32768 {
+			s = -32768
+		}
+		u := uint16(int16(s))
+		dst = append(dst, byte(u), byte(u>>8))
+	}
+	return dst
+}
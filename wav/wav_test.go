@@ -0,0 +1,57 @@
+// Copyright (c) 2018 Joseph D Poirier
+// Distributable under the terms of The New BSD License
+// that can be found in the LICENSE file.
+
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	for _, data := range [][]byte{{1, 2, 3}, {1, 2, 3, 4}} {
+		f, err := ioutil.TempFile("", "wav")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		w, err := NewWriter(f, Format{Channels: 1, SampleRate: 8000, BitsPerSample: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the RIFF size counts the pad byte, the data size doesn't
+		n := len(data)
+		if len(b) != 44+n+n&1 ||
+			binary.LittleEndian.Uint32(b[4:]) != uint32(len(b)-8) ||
+			binary.LittleEndian.Uint32(b[40:]) != uint32(n) {
+			t.Errorf("%d bytes: got a %d byte file, RIFF size %d, data size %d", n, len(b),
+				binary.LittleEndian.Uint32(b[4:]), binary.LittleEndian.Uint32(b[40:]))
+		}
+
+		r, err := NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%d bytes: read %v, %v", n, got, err)
+		}
+	}
+}